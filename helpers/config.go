@@ -10,29 +10,117 @@ import (
 	"github.com/knadh/koanf/v2"
 )
 
+// StreamRendition describes a single rung of the adaptive bitrate HLS ladder
+type StreamRendition struct {
+	Name         string `yaml:"name" koanf:"name" json:"name"`
+	Width        int    `yaml:"width" koanf:"width" json:"width"`
+	Height       int    `yaml:"height" koanf:"height" json:"height"`
+	VideoBitrate string `yaml:"video_bitrate" koanf:"video_bitrate" json:"video_bitrate"`
+	AudioBitrate string `yaml:"audio_bitrate" koanf:"audio_bitrate" json:"audio_bitrate"`
+}
+
+// NormalizeConfig describes the canonical MPEG-TS profile every file is
+// remuxed or transcoded to before it is fed into the persistent pipeline
+type NormalizeConfig struct {
+	Mode            string `yaml:"mode" koanf:"mode" json:"mode"`
+	CacheDir        string `yaml:"cache_dir" koanf:"cache_dir" json:"cache_dir"`
+	VideoCodec      string `yaml:"video_codec" koanf:"video_codec" json:"video_codec"`
+	AudioCodec      string `yaml:"audio_codec" koanf:"audio_codec" json:"audio_codec"`
+	Width           int    `yaml:"width" koanf:"width" json:"width"`
+	Height          int    `yaml:"height" koanf:"height" json:"height"`
+	FrameRate       int    `yaml:"frame_rate" koanf:"frame_rate" json:"frame_rate"`
+	AudioSampleRate int    `yaml:"audio_sample_rate" koanf:"audio_sample_rate" json:"audio_sample_rate"`
+	AudioChannels   int    `yaml:"audio_channels" koanf:"audio_channels" json:"audio_channels"`
+}
+
+// UploadPolicyConfig is the validation policy uploads are checked against.
+// Actions maps a rule (video_codec, audio_codec, resolution, frame_rate,
+// duration, audio, interlaced, loudness) to reject, warn or conform, rules
+// without an action reject. Zero values disable a check.
+type UploadPolicyConfig struct {
+	VideoCodecs         []string          `yaml:"video_codecs" koanf:"video_codecs"`
+	AudioCodecs         []string          `yaml:"audio_codecs" koanf:"audio_codecs"`
+	MinWidth            int               `yaml:"min_width" koanf:"min_width"`
+	MaxWidth            int               `yaml:"max_width" koanf:"max_width"`
+	MinHeight           int               `yaml:"min_height" koanf:"min_height"`
+	MaxHeight           int               `yaml:"max_height" koanf:"max_height"`
+	MinFrameRate        int               `yaml:"min_fps" koanf:"min_fps"`
+	MaxFrameRate        int               `yaml:"max_fps" koanf:"max_fps"`
+	MinDurationSeconds  int               `yaml:"min_duration_seconds" koanf:"min_duration_seconds"`
+	MaxDurationSeconds  int               `yaml:"max_duration_seconds" koanf:"max_duration_seconds"`
+	RequireAudio        bool              `yaml:"require_audio" koanf:"require_audio"`
+	RequireProgressive  bool              `yaml:"require_progressive" koanf:"require_progressive"`
+	LoudnessTargetLUFS  float64           `yaml:"loudness_target_lufs" koanf:"loudness_target_lufs"`
+	LoudnessToleranceLU float64           `yaml:"loudness_tolerance_lu" koanf:"loudness_tolerance_lu"`
+	Actions             map[string]string `yaml:"actions" koanf:"actions"`
+}
+
+// StreamingConfig is the streaming profile of the persistent pipeline. The
+// profile from config.yaml is used at startup and can be replaced at runtime.
+type StreamingConfig struct {
+	OutputDir        string            `yaml:"output_dir" koanf:"output_dir" json:"output_dir"`
+	HlsSegmentTime   int               `yaml:"hls_segment_time" koanf:"hls_segment_time" json:"hls_segment_time"`
+	HlsListSize      int               `yaml:"hls_list_size" koanf:"hls_list_size" json:"hls_list_size"`
+	FFmpegPreset     string            `yaml:"ffmpeg_preset" koanf:"ffmpeg_preset" json:"ffmpeg_preset"`
+	VideoBitrate     string            `yaml:"video_bitrate" koanf:"video_bitrate" json:"video_bitrate"`
+	AudioBitrate     string            `yaml:"audio_bitrate" koanf:"audio_bitrate" json:"audio_bitrate"`
+	FeedStallTimeout int               `yaml:"feed_stall_timeout" koanf:"feed_stall_timeout" json:"feed_stall_timeout"`
+	ResumePolicy     string            `yaml:"resume_policy" koanf:"resume_policy" json:"resume_policy"`
+	ShutdownMarker   string            `yaml:"shutdown_marker" koanf:"shutdown_marker" json:"shutdown_marker"`
+	AdMarkers        string            `yaml:"ad_markers" koanf:"ad_markers" json:"ad_markers"`
+	Mode             string            `yaml:"mode" koanf:"mode" json:"mode"`
+	Renditions       []StreamRendition `yaml:"renditions" koanf:"renditions" json:"renditions"`
+	Normalize        NormalizeConfig   `yaml:"normalize" koanf:"normalize" json:"normalize"`
+}
+
 type myConfig2 struct {
 	App struct {
-		WebPort        int    `yaml:"web_port" koanf:"web_port"`
-		VideoFilesPath string `yaml:"video_files_path" koanf:"video_files_path"`
+		WebPort        int      `yaml:"web_port" koanf:"web_port"`
+		VideoFilesPath string   `yaml:"video_files_path" koanf:"video_files_path"`
+		AllowedOrigins []string `yaml:"allowed_origins" koanf:"allowed_origins"`
 	} `yaml:"app" koanf:"app"`
+	Auth struct {
+		Enabled         bool   `yaml:"enabled" koanf:"enabled"`
+		TokenSecret     string `yaml:"token_secret" koanf:"token_secret"`
+		TokenTTLMinutes int    `yaml:"token_ttl_minutes" koanf:"token_ttl_minutes"`
+	} `yaml:"auth" koanf:"auth"`
 	Database struct {
 		DBPath string `yaml:"db_path" koanf:"db_path"`
 	} `yaml:"database" koanf:"database"`
-	Streaming struct {
-		OutputDir      string `yaml:"output_dir" koanf:"output_dir"`
-		HlsSegmentTime int    `yaml:"hls_segment_time" koanf:"hls_segment_time"`
-		HlsListSize    int    `yaml:"hls_list_size" koanf:"hls_list_size"`
-		FFmpegPreset   string `yaml:"ffmpeg_preset" koanf:"ffmpeg_preset"`
-		VideoBitrate   string `yaml:"video_bitrate" koanf:"video_bitrate"`
-		AudioBitrate   string `yaml:"audio_bitrate" koanf:"audio_bitrate"`
-	} `yaml:"streaming" koanf:"streaming"`
+	Streaming StreamingConfig `yaml:"streaming" koanf:"streaming"`
+	EPG       struct {
+		ChannelID    string `yaml:"channel_id" koanf:"channel_id"`
+		ChannelName  string `yaml:"channel_name" koanf:"channel_name"`
+		HorizonHours int    `yaml:"horizon_hours" koanf:"horizon_hours"`
+	} `yaml:"epg" koanf:"epg"`
+	Ads struct {
+		BreakIntervalMinutes int `yaml:"break_interval_minutes" koanf:"break_interval_minutes"`
+		BreakMaxAds          int `yaml:"break_max_ads" koanf:"break_max_ads"`
+		BreakMaxSeconds      int `yaml:"break_max_seconds" koanf:"break_max_seconds"`
+	} `yaml:"ads" koanf:"ads"`
+	Library struct {
+		Watch                bool     `yaml:"watch" koanf:"watch"`
+		WatchFolders         []string `yaml:"watch_folders" koanf:"watch_folders"`
+		SettleSeconds        int      `yaml:"settle_seconds" koanf:"settle_seconds"`
+		CheckIntervalMinutes int      `yaml:"check_interval_minutes" koanf:"check_interval_minutes"`
+		CheckAutoFix         bool     `yaml:"check_auto_fix" koanf:"check_auto_fix"`
+		AutoApprove          bool     `yaml:"auto_approve" koanf:"auto_approve"`
+	} `yaml:"library" koanf:"library"`
+	AsRun struct {
+		RetentionDays int `yaml:"retention_days" koanf:"retention_days"`
+	} `yaml:"as_run" koanf:"as_run"`
 	Upload struct {
-		UploadDir        string   `yaml:"upload_dir" koanf:"upload_dir"`
-		MaxFileSizeMB    int      `yaml:"max_file_size_mb" koanf:"max_file_size_mb"`
-		ChunkSizeBytes   int      `yaml:"chunk_size_bytes" koanf:"chunk_size_bytes"`
-		AllowedFormats   []string `yaml:"allowed_formats" koanf:"allowed_formats"`
-		RequiredWidth    int      `yaml:"required_width" koanf:"required_width"`
-		RequiredHeight   int      `yaml:"required_height" koanf:"required_height"`
+		UploadDir         string   `yaml:"upload_dir" koanf:"upload_dir"`
+		MaxFileSizeMB     int      `yaml:"max_file_size_mb" koanf:"max_file_size_mb"`
+		ChunkSizeBytes    int      `yaml:"chunk_size_bytes" koanf:"chunk_size_bytes"`
+		AllowedFormats    []string `yaml:"allowed_formats" koanf:"allowed_formats"`
+		RequiredWidth     int      `yaml:"required_width" koanf:"required_width"`
+		RequiredHeight    int      `yaml:"required_height" koanf:"required_height"`
+		SessionTTLMinutes int      `yaml:"session_ttl_minutes" koanf:"session_ttl_minutes"`
+		ChunkWindow       int      `yaml:"chunk_window" koanf:"chunk_window"`
+		// Policy replaces RequiredWidth and RequiredHeight, which are used
+		// as the resolution range when the policy sets none
+		Policy UploadPolicyConfig `yaml:"policy" koanf:"policy"`
 	} `yaml:"upload" koanf:"upload"`
 }
 
@@ -45,3 +45,14 @@ func GetXORM() *xorm.Engine {
 	}
 	return engine
 }
+
+// CloseXORM checkpoints the WAL and closes the database
+func CloseXORM() error {
+	if engine == nil {
+		return nil
+	}
+	engine.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	err := engine.Close()
+	engine = nil
+	return err
+}
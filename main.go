@@ -1,11 +1,24 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"tv_streamer/helpers"
 	"tv_streamer/helpers/logs"
 	"tv_streamer/modules/streamer"
 	"tv_streamer/modules/web"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Upper bounds of the shutdown steps
+const (
+	webDrainTimeout   = 10 * time.Second
+	streamStopTimeout = 20 * time.Second
+	dbCloseTimeout    = 5 * time.Second
 )
 
 func init() {
@@ -14,18 +27,101 @@ func init() {
 		os.Exit(1)
 	}
 
+	// Fail early with a readable message instead of a broken pipeline
+	streaming := helpers.GetConfig().Streaming
+	if err := streamer.ValidateStreamingProfile(&streaming); err != nil {
+		logs.GetLogger().WithError(err).Error(`streaming configuration is invalid`)
+		os.Exit(1)
+	}
+	if _, err := streamer.UploadPolicy(); err != nil {
+		logs.GetLogger().WithError(err).Error(`upload policy is invalid`)
+		os.Exit(1)
+	}
+
 	logs.GetLogger().Info(`Starting ...`)
 	helpers.GetXORM()
 }
 
 func main() {
+	logger := logs.GetLogger().WithField("module", "main")
 
-	// close properly
-	defer helpers.GetXORM().Close()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		streamer.StartStream()
 	}()
 
-	web.Run()
+	webDone := make(chan struct{})
+	go func() {
+		web.Run()
+		close(webDone)
+	}()
+
+	select {
+	case sig := <-signals:
+		logger.WithField("signal", sig.String()).Info("Shutdown signal received")
+	case <-webDone:
+		logger.Error("Web server stopped unexpectedly, shutting down")
+	}
+
+	// A second signal skips the remaining steps
+	go func() {
+		sig := <-signals
+		logger.WithField("signal", sig.String()).Warn("Second signal received, exiting immediately")
+		os.Exit(1)
+	}()
+
+	if !shutdown() {
+		os.Exit(1)
+	}
+}
+
+// shutdown drains the web server, stops the channels and closes the database.
+// Every step is bounded so a stuck component can't block the exit. Returns
+// false if a step failed.
+func shutdown() bool {
+	logger := logs.GetLogger().WithField("module", "main")
+	logger.Info("========================================")
+	logger.Info("Shutting down TV Streamer...")
+	logger.Info("========================================")
+
+	clean := true
+	step := func(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- fn(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				clean = false
+				logger.WithError(err).WithField("step", name).Error("Shutdown step failed")
+				return
+			}
+			logger.WithField("step", name).Info("✓ Shutdown step completed")
+		case <-ctx.Done():
+			clean = false
+			logger.WithFields(logrus.Fields{
+				"step":    name,
+				"timeout": timeout.String(),
+			}).Error("Shutdown step timed out")
+		}
+	}
+
+	// Stop taking requests first so nothing changes the queue while channels stop
+	step("web", webDrainTimeout, web.Shutdown)
+
+	step("stream", streamStopTimeout, streamer.StopStream)
+
+	step("database", dbCloseTimeout, func(ctx context.Context) error {
+		return helpers.CloseXORM()
+	})
+
+	logger.Info("✓ TV Streamer stopped")
+	return clean
 }
@@ -0,0 +1,6 @@
+-- Remove time-slot programming tables
+
+DROP INDEX IF EXISTS "idx_schedule_slot_items_slot";
+DROP INDEX IF EXISTS "idx_schedule_slots_enabled";
+DROP TABLE IF EXISTS "schedule_slot_items";
+DROP TABLE IF EXISTS "schedule_slots";
@@ -0,0 +1,27 @@
+-- Create schedule_slots table for time-slot programming (EPG-style grid)
+-- Slots are consulted before the endless loop in the schedule table
+CREATE TABLE IF NOT EXISTS "schedule_slots" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "name" VARCHAR(100) NOT NULL,
+    "start_time" VARCHAR(5) NOT NULL,
+    "end_time" VARCHAR(5) NOT NULL DEFAULT '',
+    "days" VARCHAR(50) NOT NULL,
+    "priority" INTEGER NOT NULL DEFAULT 0,
+    "enabled" INTEGER NOT NULL DEFAULT 1,
+    "last_run_date" VARCHAR(10) NOT NULL DEFAULT '',
+    "next_position" INTEGER NOT NULL DEFAULT 0,
+    "created_at" INTEGER NOT NULL
+);
+
+-- Block playlist for each slot
+CREATE TABLE IF NOT EXISTS "schedule_slot_items" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "slot_id" INTEGER NOT NULL,
+    "file_id" VARCHAR(50) NOT NULL,
+    "position" INTEGER NOT NULL,
+    FOREIGN KEY ("slot_id") REFERENCES "schedule_slots"("id") ON DELETE CASCADE,
+    FOREIGN KEY ("file_id") REFERENCES "availible_files"("file_id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_schedule_slots_enabled" ON "schedule_slots"("enabled");
+CREATE INDEX IF NOT EXISTS "idx_schedule_slot_items_slot" ON "schedule_slot_items"("slot_id", "position");
@@ -0,0 +1,13 @@
+-- Remove multi-channel support
+
+DROP INDEX IF EXISTS "idx_play_history_channel";
+DROP INDEX IF EXISTS "idx_schedule_slots_channel";
+DROP INDEX IF EXISTS "idx_schedule_channel";
+DROP INDEX IF EXISTS "idx_video_queue_channel";
+
+ALTER TABLE "play_history" DROP COLUMN "channel_id";
+ALTER TABLE "schedule_slots" DROP COLUMN "channel_id";
+ALTER TABLE "schedule" DROP COLUMN "channel_id";
+ALTER TABLE "video_queue" DROP COLUMN "channel_id";
+
+DROP TABLE IF EXISTS "channels";
@@ -0,0 +1,25 @@
+-- Create channels table so several channels can run in one process
+-- Each channel has its own queue, schedule, output directory and FFmpeg pipeline
+CREATE TABLE IF NOT EXISTS "channels" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "name" VARCHAR(100) NOT NULL,
+    "output_dir" VARCHAR(250) NOT NULL DEFAULT '',
+    "enabled" INTEGER NOT NULL DEFAULT 1,
+    "created_at" INTEGER NOT NULL
+);
+
+-- Existing content belongs to the default channel
+INSERT OR IGNORE INTO "channels" ("id", "name", "output_dir", "enabled", "created_at")
+VALUES (1, 'Default', '', 1, unixepoch());
+
+-- Scope queue, schedule, slots and history by channel
+-- (SQLite can't add a REFERENCES column with a non-NULL default, cleanup is done in code)
+ALTER TABLE "video_queue" ADD COLUMN "channel_id" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "schedule" ADD COLUMN "channel_id" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "schedule_slots" ADD COLUMN "channel_id" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "play_history" ADD COLUMN "channel_id" INTEGER NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS "idx_video_queue_channel" ON "video_queue"("channel_id", "played");
+CREATE INDEX IF NOT EXISTS "idx_schedule_channel" ON "schedule"("channel_id", "schedule_position");
+CREATE INDEX IF NOT EXISTS "idx_schedule_slots_channel" ON "schedule_slots"("channel_id");
+CREATE INDEX IF NOT EXISTS "idx_play_history_channel" ON "play_history"("channel_id", "started_at");
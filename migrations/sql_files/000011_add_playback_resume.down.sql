@@ -0,0 +1,8 @@
+-- Remove playback resume support
+
+ALTER TABLE "play_history" DROP COLUMN "interrupted";
+ALTER TABLE "play_history" DROP COLUMN "position_seconds";
+
+ALTER TABLE "video_queue" DROP COLUMN "resume_saved_at";
+ALTER TABLE "video_queue" DROP COLUMN "resume_position_seconds";
+ALTER TABLE "video_queue" DROP COLUMN "resume_byte_offset";
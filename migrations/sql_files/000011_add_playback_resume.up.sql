@@ -0,0 +1,10 @@
+-- Persist the playback position so an interrupted video can be resumed after a restart
+-- The byte offset refers to the MPEG-TS file fed to FFmpeg
+ALTER TABLE "video_queue" ADD COLUMN "resume_byte_offset" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "video_queue" ADD COLUMN "resume_position_seconds" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "video_queue" ADD COLUMN "resume_saved_at" INTEGER NOT NULL DEFAULT 0;
+
+-- Seconds aired so far, used to close rows left open by a crash
+ALTER TABLE "play_history" ADD COLUMN "position_seconds" INTEGER NOT NULL DEFAULT 0;
+-- 1 if playback ended before the file finished (restart, crash or stop), not by a skip
+ALTER TABLE "play_history" ADD COLUMN "interrupted" INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+-- Remove ad pods
+
+ALTER TABLE "play_history" DROP COLUMN "pod_id";
+ALTER TABLE "video_queue" DROP COLUMN "pod_id";
+
+DROP INDEX IF EXISTS "idx_ad_pods_channel";
+DROP TABLE IF EXISTS "ad_pods";
@@ -0,0 +1,17 @@
+-- Create ad_pods table, an ad break made of one or more consecutive ads
+-- started_at/ended_at place the pod on the stream timeline for cue markers
+CREATE TABLE IF NOT EXISTS "ad_pods" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "channel_id" INTEGER NOT NULL DEFAULT 1,
+    "planned_duration_seconds" INTEGER NOT NULL DEFAULT 0,
+    "ad_count" INTEGER NOT NULL DEFAULT 0,
+    "created_at" INTEGER NOT NULL,
+    "started_at" INTEGER NOT NULL DEFAULT 0,
+    "ended_at" INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS "idx_ad_pods_channel" ON "ad_pods"("channel_id", "started_at");
+
+-- Ads in the queue and in the history belong to a pod (0 for regular content)
+ALTER TABLE "video_queue" ADD COLUMN "pod_id" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "play_history" ADD COLUMN "pod_id" INTEGER NOT NULL DEFAULT 0;
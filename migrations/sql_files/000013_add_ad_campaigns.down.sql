@@ -0,0 +1,11 @@
+-- Remove ad campaigns
+
+DROP INDEX IF EXISTS "idx_play_history_campaign";
+
+ALTER TABLE "play_history" DROP COLUMN "campaign_id";
+ALTER TABLE "video_queue" DROP COLUMN "campaign_id";
+
+DROP INDEX IF EXISTS "idx_ad_campaign_creatives_campaign";
+DROP INDEX IF EXISTS "idx_ad_campaigns_channel";
+DROP TABLE IF EXISTS "ad_campaign_creatives";
+DROP TABLE IF EXISTS "ad_campaigns";
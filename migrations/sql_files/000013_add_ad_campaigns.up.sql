@@ -0,0 +1,33 @@
+-- Create ad_campaigns table, campaigns fill the automatic ad breaks
+-- Flight dates are YYYY-MM-DD (local time), an empty end_date never ends
+CREATE TABLE IF NOT EXISTS "ad_campaigns" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "channel_id" INTEGER NOT NULL DEFAULT 1,
+    "name" VARCHAR(100) NOT NULL,
+    "start_date" VARCHAR(10) NOT NULL,
+    "end_date" VARCHAR(10) NOT NULL DEFAULT '',
+    "daily_cap" INTEGER NOT NULL DEFAULT 0,
+    "min_spacing_minutes" INTEGER NOT NULL DEFAULT 0,
+    "priority" INTEGER NOT NULL DEFAULT 0,
+    "enabled" INTEGER NOT NULL DEFAULT 1,
+    "created_at" INTEGER NOT NULL
+);
+
+-- Creatives (ad files) of each campaign
+CREATE TABLE IF NOT EXISTS "ad_campaign_creatives" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "campaign_id" INTEGER NOT NULL,
+    "file_id" VARCHAR(50) NOT NULL,
+    FOREIGN KEY ("campaign_id") REFERENCES "ad_campaigns"("id") ON DELETE CASCADE,
+    FOREIGN KEY ("file_id") REFERENCES "availible_files"("file_id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_ad_campaigns_channel" ON "ad_campaigns"("channel_id", "enabled");
+CREATE INDEX IF NOT EXISTS "idx_ad_campaign_creatives_campaign" ON "ad_campaign_creatives"("campaign_id");
+
+-- Ads booked by a campaign carry it into the queue and the history,
+-- impressions are counted from the history
+ALTER TABLE "video_queue" ADD COLUMN "campaign_id" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "play_history" ADD COLUMN "campaign_id" INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS "idx_play_history_campaign" ON "play_history"("campaign_id", "started_at");
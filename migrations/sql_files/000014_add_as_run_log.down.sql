@@ -0,0 +1,5 @@
+-- Remove as-run log
+
+DROP INDEX IF EXISTS "idx_as_run_log_history";
+DROP INDEX IF EXISTS "idx_as_run_log_channel_start";
+DROP TABLE IF EXISTS "as_run_log";
@@ -0,0 +1,22 @@
+-- Create as_run_log table, the durable record of what went on air
+-- play_history is cleaned up after one day, as-run rows are kept for
+-- as_run.retention_days and removed by the application
+CREATE TABLE IF NOT EXISTS "as_run_log" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "channel_id" INTEGER NOT NULL DEFAULT 1,
+    "history_id" INTEGER NOT NULL,
+    "file_id" VARCHAR(50) NOT NULL,
+    "filepath" TEXT NOT NULL,
+    "is_ad" INTEGER NOT NULL DEFAULT 0,
+    "pod_id" INTEGER NOT NULL DEFAULT 0,
+    "campaign_id" INTEGER NOT NULL DEFAULT 0,
+    "planned_start" INTEGER NOT NULL,
+    "planned_duration_seconds" INTEGER NOT NULL DEFAULT 0,
+    "actual_start" INTEGER NOT NULL,
+    "actual_end" INTEGER NOT NULL DEFAULT 0,
+    "duration_seconds" INTEGER NOT NULL DEFAULT 0,
+    "skip_reason" VARCHAR(20) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS "idx_as_run_log_channel_start" ON "as_run_log"("channel_id", "actual_start");
+CREATE INDEX IF NOT EXISTS "idx_as_run_log_history" ON "as_run_log"("history_id");
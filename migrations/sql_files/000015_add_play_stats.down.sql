@@ -0,0 +1,6 @@
+-- Remove playback summary
+
+DROP TRIGGER IF EXISTS aggregate_play_stats;
+
+DROP INDEX IF EXISTS "idx_play_stats_daily_file";
+DROP TABLE IF EXISTS "play_stats_daily";
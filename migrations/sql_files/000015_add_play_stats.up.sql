@@ -0,0 +1,57 @@
+-- Create play_stats_daily table, the per file and day playback summary
+-- play_history is cleaned up after one day, the summary is kept
+-- Days are YYYY-MM-DD (local time) of the start of the playback
+CREATE TABLE IF NOT EXISTS "play_stats_daily" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "channel_id" INTEGER NOT NULL DEFAULT 1,
+    "day" VARCHAR(10) NOT NULL,
+    "file_id" VARCHAR(50) NOT NULL,
+    "is_ad" INTEGER NOT NULL DEFAULT 0,
+    "plays" INTEGER NOT NULL DEFAULT 0,
+    "skips" INTEGER NOT NULL DEFAULT 0,
+    "interruptions" INTEGER NOT NULL DEFAULT 0,
+    "airtime_seconds" INTEGER NOT NULL DEFAULT 0,
+    UNIQUE ("channel_id", "day", "file_id", "is_ad")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_play_stats_daily_file" ON "play_stats_daily"("file_id");
+
+-- Summarize the history that is still there
+INSERT INTO "play_stats_daily" ("channel_id", "day", "file_id", "is_ad", "plays", "skips", "interruptions", "airtime_seconds")
+SELECT channel_id,
+       date(started_at, 'unixepoch', 'localtime'),
+       file_id,
+       is_ad,
+       SUM(CASE WHEN interrupted = 1 THEN 0 ELSE 1 END),
+       SUM(skip_requested),
+       SUM(interrupted),
+       SUM(COALESCE(duration_seconds, 0))
+FROM play_history
+WHERE finished_at > 0
+GROUP BY channel_id, date(started_at, 'unixepoch', 'localtime'), file_id, is_ad;
+
+-- Add every playback to the summary when its history record is closed
+-- An interrupted playback adds its airtime but is not counted as a play,
+-- the video is played again afterwards
+CREATE TRIGGER IF NOT EXISTS aggregate_play_stats
+AFTER UPDATE OF finished_at ON play_history
+WHEN NEW.finished_at > 0 AND COALESCE(OLD.finished_at, 0) = 0
+BEGIN
+INSERT INTO play_stats_daily (channel_id, day, file_id, is_ad, plays, skips, interruptions, airtime_seconds)
+VALUES (
+    NEW.channel_id,
+    date(NEW.started_at, 'unixepoch', 'localtime'),
+    NEW.file_id,
+    NEW.is_ad,
+    CASE WHEN NEW.interrupted = 1 THEN 0 ELSE 1 END,
+    NEW.skip_requested,
+    NEW.interrupted,
+    COALESCE(NEW.duration_seconds, 0)
+)
+ON CONFLICT (channel_id, day, file_id, is_ad) DO UPDATE SET
+    plays = plays + excluded.plays,
+    skips = skips + excluded.skips,
+    interruptions = interruptions + excluded.interruptions,
+    airtime_seconds = airtime_seconds + excluded.airtime_seconds;
+
+END;
@@ -0,0 +1,3 @@
+-- Remove missing file marker
+
+ALTER TABLE "availible_files" DROP COLUMN "missing_since";
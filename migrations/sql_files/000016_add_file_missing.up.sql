@@ -0,0 +1,4 @@
+-- Files deleted from a watched library folder are kept and marked missing
+-- so the queue, schedule and history keep their references
+-- 0 while the file exists, otherwise the Unix time it was found missing
+ALTER TABLE "availible_files" ADD COLUMN "missing_since" INTEGER NOT NULL DEFAULT 0;
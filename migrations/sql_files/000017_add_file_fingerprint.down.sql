@@ -0,0 +1,5 @@
+-- Remove file content fingerprint
+
+DROP INDEX IF EXISTS "idx_availible_files_fingerprint";
+
+ALTER TABLE "availible_files" DROP COLUMN "fingerprint";
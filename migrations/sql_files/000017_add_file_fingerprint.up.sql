@@ -0,0 +1,7 @@
+-- Content fingerprint of every file, a hash of samples of the content and
+-- the size, so a file moved or renamed outside the application is
+-- recognized and keeps its file_id, queue, schedule and history
+-- Empty until the file was hashed, existing files are hashed on startup
+ALTER TABLE "availible_files" ADD COLUMN "fingerprint" VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS "idx_availible_files_fingerprint" ON "availible_files"("fingerprint");
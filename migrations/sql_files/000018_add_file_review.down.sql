@@ -0,0 +1,8 @@
+-- Remove content approval workflow
+
+DROP INDEX IF EXISTS "idx_availible_files_review";
+
+ALTER TABLE "availible_files" DROP COLUMN "review_note";
+ALTER TABLE "availible_files" DROP COLUMN "reviewed_at";
+ALTER TABLE "availible_files" DROP COLUMN "reviewed_by";
+ALTER TABLE "availible_files" DROP COLUMN "review_status";
@@ -0,0 +1,17 @@
+-- Content approval workflow: only approved files go on air
+-- is_active is 1 for approved files, review_status is 'pending', 'approved'
+-- or 'rejected', the reviewer, time and note of the last decision are kept
+ALTER TABLE "availible_files" ADD COLUMN "review_status" VARCHAR(20) NOT NULL DEFAULT 'pending';
+ALTER TABLE "availible_files" ADD COLUMN "reviewed_by" VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE "availible_files" ADD COLUMN "reviewed_at" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "availible_files" ADD COLUMN "review_note" VARCHAR(500) NOT NULL DEFAULT '';
+
+-- Existing files were on air without review, keep them on air
+UPDATE "availible_files"
+SET "is_active" = 1,
+    "review_status" = 'approved',
+    "reviewed_by" = 'system',
+    "reviewed_at" = CAST(strftime('%s', 'now') AS INTEGER),
+    "review_note" = 'Added before the approval workflow';
+
+CREATE INDEX IF NOT EXISTS "idx_availible_files_review" ON "availible_files"("review_status");
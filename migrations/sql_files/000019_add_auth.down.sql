@@ -0,0 +1,5 @@
+-- Remove users and API keys
+
+DROP INDEX IF EXISTS "idx_api_keys_user";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,26 @@
+-- Create users table, every API request is made on behalf of a user
+-- role is 'viewer', 'operator' or 'admin'
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "username" VARCHAR(100) NOT NULL UNIQUE,
+    "role" VARCHAR(20) NOT NULL DEFAULT 'viewer',
+    "disabled" INTEGER NOT NULL DEFAULT 0,
+    "created_at" INTEGER NOT NULL
+);
+
+-- API keys of the users, only the SHA-256 hash of a key is stored
+-- expires_at and revoked_at are 0 while the key is valid
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "user_id" INTEGER NOT NULL,
+    "name" VARCHAR(100) NOT NULL DEFAULT '',
+    "key_prefix" VARCHAR(20) NOT NULL,
+    "key_hash" VARCHAR(64) NOT NULL UNIQUE,
+    "created_at" INTEGER NOT NULL,
+    "last_used_at" INTEGER NOT NULL DEFAULT 0,
+    "expires_at" INTEGER NOT NULL DEFAULT 0,
+    "revoked_at" INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user" ON "api_keys"("user_id");
@@ -0,0 +1,4 @@
+-- Remove upload sessions
+
+DROP INDEX IF EXISTS "idx_upload_sessions_updated";
+DROP TABLE IF EXISTS "upload_sessions";
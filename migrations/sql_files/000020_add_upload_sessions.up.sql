@@ -0,0 +1,17 @@
+-- Create upload_sessions table, uploads survive dropped connections and
+-- restarts and can be resumed
+-- received_ranges is a JSON list of the received [start, end) byte ranges,
+-- received_size is the end of the first one (the offset to resume at)
+CREATE TABLE IF NOT EXISTS "upload_sessions" (
+    "session_id" VARCHAR(64) PRIMARY KEY,
+    "user_id" INTEGER NOT NULL DEFAULT 0,
+    "filename" VARCHAR(255) NOT NULL,
+    "total_size" INTEGER NOT NULL,
+    "received_size" INTEGER NOT NULL DEFAULT 0,
+    "received_ranges" TEXT NOT NULL DEFAULT '[]',
+    "temp_path" TEXT NOT NULL,
+    "created_at" INTEGER NOT NULL,
+    "updated_at" INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "idx_upload_sessions_updated" ON "upload_sessions"("updated_at");
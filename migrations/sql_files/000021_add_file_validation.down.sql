@@ -0,0 +1,4 @@
+-- Remove upload validation results
+
+ALTER TABLE "availible_files" DROP COLUMN "validation_report";
+ALTER TABLE "availible_files" DROP COLUMN "validation_status";
@@ -0,0 +1,5 @@
+-- Results of the upload validation policy, validation_status is 'passed',
+-- 'warned' or 'conformed' for uploads and empty for files added by scans,
+-- validation_report is the JSON report with the measured values and findings
+ALTER TABLE "availible_files" ADD COLUMN "validation_status" VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE "availible_files" ADD COLUMN "validation_report" TEXT NOT NULL DEFAULT '';
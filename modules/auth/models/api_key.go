@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// APIKey is a credential of a user. Only the SHA-256 hash of the key is
+// stored, the prefix identifies the key in listings.
+type APIKey struct {
+	ID         int64  `xorm:"pk autoincr 'id'"`
+	UserID     int64  `xorm:"not null 'user_id'"`
+	Name       string `xorm:"varchar(100) not null default '' 'name'"`
+	KeyPrefix  string `xorm:"varchar(20) not null 'key_prefix'"`
+	KeyHash    string `xorm:"varchar(64) not null unique 'key_hash'"`
+	CreatedAt  int64  `xorm:"not null 'created_at'"`
+	LastUsedAt int64  `xorm:"not null default 0 'last_used_at'"`
+	ExpiresAt  int64  `xorm:"not null default 0 'expires_at'"`
+	RevokedAt  int64  `xorm:"not null default 0 'revoked_at'"`
+}
+
+// TableName sets the table name for XORM
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsValid returns true if the key is neither revoked nor expired at now
+func (k *APIKey) IsValid(now time.Time) bool {
+	if k.RevokedAt != 0 {
+		return false
+	}
+	return k.ExpiresAt == 0 || now.Unix() < k.ExpiresAt
+}
@@ -0,0 +1,20 @@
+package models
+
+// User is an account the API is used with. Its role decides what it may do.
+type User struct {
+	ID        int64  `xorm:"pk autoincr 'id'"`
+	Username  string `xorm:"varchar(100) not null unique 'username'"`
+	Role      string `xorm:"varchar(20) not null default 'viewer' 'role'"`
+	Disabled  int    `xorm:"not null default 0 'disabled'"`
+	CreatedAt int64  `xorm:"not null 'created_at'"`
+}
+
+// TableName sets the table name for XORM
+func (User) TableName() string {
+	return "users"
+}
+
+// IsDisabled returns true if the user may not sign in
+func (u *User) IsDisabled() bool {
+	return u.Disabled == 1
+}
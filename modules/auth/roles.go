@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Roles of the users, each one may do everything the previous one may
+const (
+	// RoleViewer may read the queue, schedule, library, reports and logs
+	RoleViewer = "viewer"
+	// RoleOperator may also control playback, the schedule, campaigns and
+	// the library and upload files
+	RoleOperator = "operator"
+	// RoleAdmin may also manage users, channels and the streaming profile
+	// and delete files
+	RoleAdmin = "admin"
+)
+
+// Authentication methods of an Identity
+const (
+	MethodAPIKey   = "api_key"
+	MethodToken    = "token"
+	MethodDisabled = "disabled"
+)
+
+var (
+	// ErrInvalidCredentials is returned for unknown, revoked or expired keys
+	// and tokens and for credentials of disabled users
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUserNotFound is returned for unknown user IDs
+	ErrUserNotFound = errors.New("user not found")
+	// ErrAPIKeyNotFound is returned for unknown API key IDs
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrLastAdmin is returned when a change would leave no enabled admin
+	ErrLastAdmin = errors.New("at least one enabled admin is required")
+)
+
+// roleLevels orders the roles
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidateRole returns an error if role is not a known role
+func ValidateRole(role string) error {
+	if _, ok := roleLevels[role]; !ok {
+		return fmt.Errorf("invalid role %q: use %s, %s or %s", role, RoleViewer, RoleOperator, RoleAdmin)
+	}
+	return nil
+}
+
+// Identity is the user a request is made on behalf of
+type Identity struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Method   string `json:"method"`
+	KeyID    int64  `json:"key_id,omitempty"`
+}
+
+// HasRole returns true if the identity has role or a higher one
+func (i *Identity) HasRole(role string) bool {
+	return roleLevels[i.Role] >= roleLevels[role]
+}
+
+// DisabledIdentity is used for every request while authentication is off
+func DisabledIdentity() *Identity {
+	return &Identity{
+		Username: "anonymous",
+		Role:     RoleAdmin,
+		Method:   MethodDisabled,
+	}
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTokenTTL is used when auth.token_ttl_minutes is not set
+const defaultTokenTTL = time.Hour
+
+// tokenHeader is the encoded JWT header of every token (HS256)
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// tokenClaims are the JWT claims of an access token. A token belongs to the
+// API key it was issued for, revoking the key revokes the token.
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	KeyID     int64  `json:"key"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var (
+	secret     []byte
+	secretOnce sync.Once
+)
+
+// tokenSecret returns the key tokens are signed with: auth.token_secret, or
+// a random one that is lost on restart
+func tokenSecret() []byte {
+	secretOnce.Do(func() {
+		if configured := helpers.GetConfig().Auth.TokenSecret; configured != "" {
+			secret = []byte(configured)
+			return
+		}
+
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to generate token secret: %v", err))
+		}
+		logs.GetLogger().WithField("module", "auth").
+			Warn("auth.token_secret is not set, access tokens become invalid on restart")
+	})
+	return secret
+}
+
+// tokenTTL returns the lifetime of new access tokens
+func tokenTTL() time.Duration {
+	if minutes := helpers.GetConfig().Auth.TokenTTLMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultTokenTTL
+}
+
+// sign returns the encoded signature of the header and payload
+func sign(headerAndPayload string) string {
+	mac := hmac.New(sha256.New, tokenSecret())
+	mac.Write([]byte(headerAndPayload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IssueToken returns a short-lived access token (JWT) for an identity that
+// signed in with an API key, and the time it expires at. Tokens suit
+// browsers and WebSocket handshakes, which should not hold the key itself.
+func IssueToken(identity *Identity) (string, int64, error) {
+	if identity.Method != MethodAPIKey {
+		return "", 0, fmt.Errorf("access tokens are only issued for api keys")
+	}
+
+	now := time.Now()
+	claims := tokenClaims{
+		Subject:   strconv.FormatInt(identity.UserID, 10),
+		KeyID:     identity.KeyID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(tokenTTL()).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to encode token: %w", err)
+	}
+
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "auth",
+		"function":   "IssueToken",
+		"user_id":    identity.UserID,
+		"key_id":     identity.KeyID,
+		"expires_at": claims.ExpiresAt,
+	}).Info("✓ Access token issued")
+
+	return unsigned + "." + sign(unsigned), claims.ExpiresAt, nil
+}
+
+// authenticateToken verifies an access token and returns its identity. The
+// role is that of the user now, not when the token was issued.
+func authenticateToken(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, ErrInvalidCredentials
+	}
+	if !hmac.Equal([]byte(sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return nil, ErrInvalidCredentials
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidCredentials
+	}
+
+	apiKey, user, err := lookupAPIKey("id = ?", claims.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if strconv.FormatInt(user.ID, 10) != claims.Subject {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Identity{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		Method:   MethodToken,
+		KeyID:    apiKey.ID,
+	}, nil
+}
+
+// Authenticate returns the identity of an API key or access token
+func Authenticate(credential string) (*Identity, error) {
+	if strings.HasPrefix(credential, apiKeyPrefix) {
+		return authenticateAPIKey(credential)
+	}
+	return authenticateToken(credential)
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"tv_streamer/helpers"
+)
+
+// useTestConfig loads a config with a fixed token secret. The config is read
+// once per test binary, every test loads the same one.
+func useTestConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	config := "auth:\n  enabled: true\n  token_secret: \"test-secret\"\n  token_ttl_minutes: 60\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	helpers.GetConfig()
+}
+
+// useTestDB points the database at a fresh directory for the test
+func useTestDB(t *testing.T) {
+	t.Helper()
+	helpers.CloseXORM()
+	t.Setenv("DB_PATH", t.TempDir())
+	helpers.GetXORM()
+	t.Cleanup(func() { helpers.CloseXORM() })
+}
+
+// signedToken signs a token with the given header and claims
+func signedToken(t *testing.T, header string, claims tokenClaims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sign(unsigned)
+}
+
+// newTestKey creates a user with the given role and an API key for it
+func newTestKey(t *testing.T, username, role string) (string, *Identity) {
+	t.Helper()
+	user, err := CreateUser(username, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, apiKey, err := CreateAPIKey(user.ID, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, &Identity{UserID: user.ID, Username: user.Username, Role: role, Method: MethodAPIKey, KeyID: apiKey.ID}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	useTestConfig(t)
+	useTestDB(t)
+
+	_, identity := newTestKey(t, "operator", RoleOperator)
+	_, other := newTestKey(t, "viewer", RoleViewer)
+
+	now := time.Now()
+	claims := tokenClaims{
+		Subject:   strconv.FormatInt(identity.UserID, 10),
+		KeyID:     identity.KeyID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+	valid := signedToken(t, tokenHeader, claims)
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{
+			name: "tampered payload",
+			token: func() string {
+				tampered := claims
+				tampered.ExpiresAt = now.Add(24 * time.Hour).Unix()
+				payload, _ := json.Marshal(tampered)
+				return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+			},
+		},
+		{
+			name:  "tampered signature",
+			token: func() string { return parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])) },
+		},
+		{
+			name:  "missing signature",
+			token: func() string { return parts[0] + "." + parts[1] },
+		},
+		{
+			name: "unsigned algorithm",
+			token: func() string {
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+				return header + "." + parts[1] + "."
+			},
+		},
+		{
+			name: "other header signed with the secret",
+			token: func() string {
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"HS256"}`))
+				return signedToken(t, header, claims)
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				expired := claims
+				expired.IssuedAt = now.Add(-2 * time.Hour).Unix()
+				expired.ExpiresAt = now.Add(-time.Hour).Unix()
+				return signedToken(t, tokenHeader, expired)
+			},
+		},
+		{
+			name: "key of another user",
+			token: func() string {
+				stolen := claims
+				stolen.KeyID = other.KeyID
+				return signedToken(t, tokenHeader, stolen)
+			},
+		},
+		{
+			name: "unknown key",
+			token: func() string {
+				unknown := claims
+				unknown.KeyID = 9999
+				return signedToken(t, tokenHeader, unknown)
+			},
+		},
+		{
+			name:  "garbage",
+			token: func() string { return "not-a-token" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := authenticateToken(tt.token()); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("authenticateToken() error = %v, want %v", err, ErrInvalidCredentials)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		got, err := authenticateToken(valid)
+		if err != nil {
+			t.Fatalf("authenticateToken() error = %v", err)
+		}
+		want := &Identity{UserID: identity.UserID, Username: "operator", Role: RoleOperator, Method: MethodToken, KeyID: identity.KeyID}
+		if *got != *want {
+			t.Errorf("authenticateToken() = %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	useTestConfig(t)
+	useTestDB(t)
+
+	// Users can only be disabled while an enabled admin remains
+	newTestKey(t, "admin", RoleAdmin)
+
+	key, identity := newTestKey(t, "operator", RoleOperator)
+	token, _, err := IssueToken(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens are only issued for API keys
+	if _, _, err := IssueToken(&Identity{UserID: identity.UserID, Role: RoleOperator, Method: MethodToken}); err == nil {
+		t.Error("IssueToken() for a token identity returned no error")
+	}
+
+	for _, credential := range []string{key, token} {
+		got, err := Authenticate(credential)
+		if err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+		if got.UserID != identity.UserID || got.KeyID != identity.KeyID || got.Role != RoleOperator {
+			t.Errorf("Authenticate() = %+v, want user %d with key %d", got, identity.UserID, identity.KeyID)
+		}
+	}
+
+	// The role is that of the user now
+	role := RoleViewer
+	if _, err := UpdateUser(identity.UserID, &role, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Authenticate(token); err != nil || got.Role != RoleViewer {
+		t.Errorf("Authenticate() after demotion = %+v, %v, want role %s", got, err, RoleViewer)
+	}
+
+	// Disabling the user or revoking the key invalidates key and token
+	disabled := true
+	if _, err := UpdateUser(identity.UserID, nil, &disabled); err != nil {
+		t.Fatal(err)
+	}
+	for _, credential := range []string{key, token} {
+		if _, err := Authenticate(credential); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate() of a disabled user error = %v, want %v", err, ErrInvalidCredentials)
+		}
+	}
+
+	disabled = false
+	if _, err := UpdateUser(identity.UserID, nil, &disabled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RevokeAPIKey(identity.KeyID); err != nil {
+		t.Fatal(err)
+	}
+	for _, credential := range []string{key, token} {
+		if _, err := Authenticate(credential); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate() with a revoked key error = %v, want %v", err, ErrInvalidCredentials)
+		}
+	}
+
+	if _, err := Authenticate(apiKeyPrefix + "unknown"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Authenticate() of an unknown key error = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
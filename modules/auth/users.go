@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// bootstrapUsername is the admin created when there are no users yet
+const bootstrapUsername = "admin"
+
+// CreateUser stores a new user with the given role
+func CreateUser(username, role string) (*models.User, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "auth",
+		"function": "CreateUser",
+		"username": username,
+		"role":     role,
+	})
+
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if len(username) > 100 {
+		return nil, fmt.Errorf("username must be at most 100 characters")
+	}
+	if err := ValidateRole(role); err != nil {
+		return nil, err
+	}
+
+	exists, err := helpers.GetXORM().Where("username = ?", username).Exist(&models.User{})
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("username %q is already taken", username)
+	}
+
+	user := &models.User{
+		Username:  username,
+		Role:      role,
+		CreatedAt: time.Now().Unix(),
+	}
+	if _, err := helpers.GetXORM().Insert(user); err != nil {
+		logger.WithError(err).Error("Failed to insert user")
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	logger.WithField("user_id", user.ID).Info("✓ User created")
+
+	return user, nil
+}
+
+// GetUsers returns all users ordered by ID
+func GetUsers() ([]models.User, error) {
+	var users []models.User
+	if err := helpers.GetXORM().OrderBy("id ASC").Find(&users); err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	return users, nil
+}
+
+// GetUser returns a single user
+func GetUser(userID int64) (*models.User, error) {
+	var user models.User
+	has, err := helpers.GetXORM().ID(userID).Get(&user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if !has {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// UpdateUser changes the role of a user and disables or enables it. Nil
+// arguments are left unchanged. The last enabled admin can't be demoted or
+// disabled.
+func UpdateUser(userID int64, role *string, disabled *bool) (*models.User, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "auth",
+		"function": "UpdateUser",
+		"user_id":  userID,
+	})
+
+	user, err := GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != nil {
+		if err := ValidateRole(*role); err != nil {
+			return nil, err
+		}
+		user.Role = *role
+	}
+	if disabled != nil {
+		user.Disabled = 0
+		if *disabled {
+			user.Disabled = 1
+		}
+	}
+
+	if user.Role != RoleAdmin || user.IsDisabled() {
+		if err := checkOtherAdmin(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := helpers.GetXORM().ID(userID).Cols("role", "disabled").Update(user); err != nil {
+		logger.WithError(err).Error("Failed to update user")
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"role":     user.Role,
+		"disabled": user.IsDisabled(),
+	}).Info("✓ User updated")
+
+	return user, nil
+}
+
+// DeleteUser removes a user and its API keys. The last enabled admin can't
+// be deleted.
+func DeleteUser(userID int64) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "auth",
+		"function": "DeleteUser",
+		"user_id":  userID,
+	})
+
+	if _, err := GetUser(userID); err != nil {
+		return err
+	}
+	if err := checkOtherAdmin(userID); err != nil {
+		return err
+	}
+
+	session := helpers.GetXORM().NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if _, err := session.Where("user_id = ?", userID).Delete(&models.APIKey{}); err != nil {
+		session.Rollback()
+		return fmt.Errorf("failed to delete api keys: %w", err)
+	}
+	if _, err := session.ID(userID).Delete(&models.User{}); err != nil {
+		session.Rollback()
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if err := session.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	logger.Info("✓ User deleted")
+
+	return nil
+}
+
+// checkOtherAdmin returns ErrLastAdmin unless an enabled admin other than
+// userID exists
+func checkOtherAdmin(userID int64) error {
+	others, err := helpers.GetXORM().
+		Where("role = ? AND disabled = 0 AND id != ?", RoleAdmin, userID).
+		Count(&models.User{})
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// EnsureAdmin creates an admin with an API key when there are no users yet,
+// so a fresh installation can be set up through the API. The key is only
+// shown in the log.
+func EnsureAdmin() error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "auth",
+		"function": "EnsureAdmin",
+	})
+
+	count, err := helpers.GetXORM().Count(&models.User{})
+	if err != nil {
+		return fmt.Errorf("failed to count users: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	user, err := CreateUser(bootstrapUsername, RoleAdmin)
+	if err != nil {
+		return err
+	}
+	key, _, err := CreateAPIKey(user.ID, "bootstrap", 0)
+	if err != nil {
+		return err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"username": user.Username,
+		"api_key":  key,
+	}).Warn("No users yet: created an admin, store its API key now, it is not shown again")
+
+	return nil
+}
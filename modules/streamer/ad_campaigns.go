@@ -0,0 +1,471 @@
+package streamer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// campaignDateLayout is the layout of campaign flight dates (local time)
+const campaignDateLayout = "2006-01-02"
+
+// defaultBreakMaxAds is the number of ads per automatic break when
+// ads.break_max_ads is not set
+const defaultBreakMaxAds = 3
+
+// AdCampaignStats is the delivery of a campaign on a given day
+type AdCampaignStats struct {
+	ImpressionsToday int64 `json:"impressions_today"`
+	QueuedAds        int64 `json:"queued_ads"`
+	LastImpressionAt int64 `json:"last_impression_at"`
+}
+
+// validateAdCampaign checks the campaign fields and its creatives
+func validateAdCampaign(campaign *models.AdCampaign, fileIDs []string) error {
+	if campaign.Name == "" {
+		return fmt.Errorf("campaign name is required")
+	}
+
+	if _, err := time.Parse(campaignDateLayout, campaign.StartDate); err != nil {
+		return fmt.Errorf("invalid start_date '%s', expected YYYY-MM-DD", campaign.StartDate)
+	}
+	if campaign.EndDate != "" {
+		if _, err := time.Parse(campaignDateLayout, campaign.EndDate); err != nil {
+			return fmt.Errorf("invalid end_date '%s', expected YYYY-MM-DD", campaign.EndDate)
+		}
+		if campaign.EndDate < campaign.StartDate {
+			return fmt.Errorf("end_date must not be before start_date")
+		}
+	}
+
+	if campaign.DailyCap < 0 {
+		return fmt.Errorf("daily_cap can't be negative")
+	}
+	if campaign.MinSpacingMinutes < 0 {
+		return fmt.Errorf("min_spacing_minutes can't be negative")
+	}
+
+	if len(fileIDs) == 0 {
+		return fmt.Errorf("campaign must have at least one creative")
+	}
+
+	// All creatives must be in the library
+	for _, fileID := range fileIDs {
+		has, err := helpers.GetXORM().Where("file_id = ?", fileID).Exist(&models.AvailableFiles{})
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if !has {
+			return fmt.Errorf("file not found in available files (file_id: %s)", fileID)
+		}
+	}
+
+	return nil
+}
+
+// CreateAdCampaign validates and stores a new campaign with its creatives.
+// The campaign belongs to the channel set in campaign.ChannelID.
+func CreateAdCampaign(campaign *models.AdCampaign, fileIDs []string) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "CreateAdCampaign",
+		"channel_id": campaign.ChannelID,
+		"name":       campaign.Name,
+	})
+
+	logger.Info("Creating ad campaign...")
+
+	if err := validateAdCampaign(campaign, fileIDs); err != nil {
+		return err
+	}
+
+	campaign.CreatedAt = time.Now().Unix()
+
+	session := helpers.GetXORM().NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := session.Insert(campaign); err != nil {
+		session.Rollback()
+		logger.WithError(err).Error("Failed to insert ad campaign")
+		return fmt.Errorf("failed to create campaign: %w", err)
+	}
+
+	for _, fileID := range fileIDs {
+		if _, err := session.Insert(&models.AdCampaignCreative{CampaignID: campaign.ID, FileID: fileID}); err != nil {
+			session.Rollback()
+			logger.WithError(err).Error("Failed to insert campaign creative")
+			return fmt.Errorf("failed to add creative to campaign: %w", err)
+		}
+	}
+
+	if err := session.Commit(); err != nil {
+		return fmt.Errorf("failed to commit campaign: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"campaign_id": campaign.ID,
+		"start_date":  campaign.StartDate,
+		"end_date":    campaign.EndDate,
+		"creatives":   len(fileIDs),
+	}).Info("✓ Ad campaign created successfully")
+
+	return nil
+}
+
+// UpdateAdCampaign replaces the settings and creatives of a campaign of a
+// channel. Impressions already delivered are kept.
+func UpdateAdCampaign(channelID int64, campaignID int64, update *models.AdCampaign, fileIDs []string) (*models.AdCampaign, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":      "streamer",
+		"function":    "UpdateAdCampaign",
+		"channel_id":  channelID,
+		"campaign_id": campaignID,
+	})
+
+	logger.Info("Updating ad campaign...")
+
+	campaign, err := GetAdCampaign(channelID, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateAdCampaign(update, fileIDs); err != nil {
+		return nil, err
+	}
+
+	campaign.Name = update.Name
+	campaign.StartDate = update.StartDate
+	campaign.EndDate = update.EndDate
+	campaign.DailyCap = update.DailyCap
+	campaign.MinSpacingMinutes = update.MinSpacingMinutes
+	campaign.Priority = update.Priority
+	campaign.Enabled = update.Enabled
+
+	session := helpers.GetXORM().NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := session.ID(campaign.ID).
+		Cols("name", "start_date", "end_date", "daily_cap", "min_spacing_minutes", "priority", "enabled").
+		Update(campaign); err != nil {
+		session.Rollback()
+		logger.WithError(err).Error("Failed to update ad campaign")
+		return nil, fmt.Errorf("failed to update campaign: %w", err)
+	}
+
+	if _, err := session.Where("campaign_id = ?", campaign.ID).Delete(&models.AdCampaignCreative{}); err != nil {
+		session.Rollback()
+		logger.WithError(err).Error("Failed to delete campaign creatives")
+		return nil, fmt.Errorf("failed to replace creatives: %w", err)
+	}
+
+	for _, fileID := range fileIDs {
+		if _, err := session.Insert(&models.AdCampaignCreative{CampaignID: campaign.ID, FileID: fileID}); err != nil {
+			session.Rollback()
+			logger.WithError(err).Error("Failed to insert campaign creative")
+			return nil, fmt.Errorf("failed to add creative to campaign: %w", err)
+		}
+	}
+
+	if err := session.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit campaign: %w", err)
+	}
+
+	logger.WithField("creatives", len(fileIDs)).Info("✓ Ad campaign updated")
+	return campaign, nil
+}
+
+// GetAdCampaigns returns all campaigns of a channel, highest priority first
+func GetAdCampaigns(channelID int64) ([]models.AdCampaign, error) {
+	var campaigns []models.AdCampaign
+	if err := helpers.GetXORM().
+		Where("channel_id = ?", channelID).
+		OrderBy("priority DESC, id ASC").
+		Find(&campaigns); err != nil {
+		return nil, fmt.Errorf("failed to fetch campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+// GetAdCampaign returns a single campaign of a channel
+func GetAdCampaign(channelID int64, campaignID int64) (*models.AdCampaign, error) {
+	var campaign models.AdCampaign
+	has, err := helpers.GetXORM().Where("id = ? AND channel_id = ?", campaignID, channelID).Get(&campaign)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if !has {
+		return nil, fmt.Errorf("campaign not found")
+	}
+	return &campaign, nil
+}
+
+// GetAdCampaignCreatives returns the creatives of a campaign
+func GetAdCampaignCreatives(campaignID int64) ([]models.AdCampaignCreative, error) {
+	var creatives []models.AdCampaignCreative
+	if err := helpers.GetXORM().Where("campaign_id = ?", campaignID).OrderBy("id ASC").Find(&creatives); err != nil {
+		return nil, fmt.Errorf("failed to fetch creatives: %w", err)
+	}
+	return creatives, nil
+}
+
+// DeleteAdCampaign removes a campaign of a channel and its creatives. Ads the
+// campaign already booked stay in the queue.
+func DeleteAdCampaign(channelID int64, campaignID int64) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":      "streamer",
+		"function":    "DeleteAdCampaign",
+		"channel_id":  channelID,
+		"campaign_id": campaignID,
+	})
+
+	logger.Info("Deleting ad campaign...")
+
+	if _, err := GetAdCampaign(channelID, campaignID); err != nil {
+		return err
+	}
+
+	if _, err := helpers.GetXORM().Where("campaign_id = ?", campaignID).Delete(&models.AdCampaignCreative{}); err != nil {
+		logger.WithError(err).Error("Failed to delete campaign creatives")
+		return fmt.Errorf("failed to delete creatives: %w", err)
+	}
+
+	if _, err := helpers.GetXORM().ID(campaignID).Delete(&models.AdCampaign{}); err != nil {
+		logger.WithError(err).Error("Failed to delete ad campaign")
+		return fmt.Errorf("failed to delete campaign: %w", err)
+	}
+
+	logger.Info("✓ Ad campaign deleted")
+	return nil
+}
+
+// GetAdCampaignStats returns the delivery of a campaign for the day of now.
+// Impressions are the ad rows of the play history booked by the campaign.
+func GetAdCampaignStats(campaignID int64, now time.Time) (AdCampaignStats, error) {
+	var stats AdCampaignStats
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	impressions, err := helpers.GetXORM().
+		Where("campaign_id = ? AND is_ad = ? AND started_at >= ?", campaignID, 1, dayStart.Unix()).
+		Count(&models.PlayHistory{})
+	if err != nil {
+		return stats, fmt.Errorf("failed to count impressions: %w", err)
+	}
+	stats.ImpressionsToday = impressions
+
+	queued, err := helpers.GetXORM().
+		Where("campaign_id = ? AND played = ?", campaignID, 0).
+		Count(&models.VideoQueue{})
+	if err != nil {
+		return stats, fmt.Errorf("failed to count queued ads: %w", err)
+	}
+	stats.QueuedAds = queued
+
+	if _, err := helpers.GetXORM().
+		SQL("SELECT COALESCE(MAX(started_at), 0) FROM play_history WHERE campaign_id = ? AND is_ad = 1", campaignID).
+		Get(&stats.LastImpressionAt); err != nil {
+		return stats, fmt.Errorf("failed to look up last impression: %w", err)
+	}
+
+	return stats, nil
+}
+
+// programmeSinceLastBreak returns the seconds of content aired on a channel
+// since its last ad
+func programmeSinceLastBreak(channelID int64) (int64, error) {
+	var seconds int64
+	_, err := helpers.GetXORM().SQL(`
+		SELECT COALESCE(SUM(duration_seconds), 0)
+		FROM play_history
+		WHERE channel_id = ? AND is_ad = 0 AND finished_at > 0
+		  AND started_at > (SELECT COALESCE(MAX(started_at), 0) FROM play_history WHERE channel_id = ? AND is_ad = 1)`,
+		channelID, channelID).Get(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum programme time: %w", err)
+	}
+	return seconds, nil
+}
+
+// campaignCandidate is an eligible campaign with the creative it airs next
+type campaignCandidate struct {
+	campaign    models.AdCampaign
+	stats       AdCampaignStats
+	fileID      string
+	videoLength int64
+}
+
+// selectCampaignAds picks the ads of an automatic break: at most one creative
+// per campaign, from the campaigns that are in flight, below their daily cap
+// and past their minimum spacing. Higher priority wins, campaigns with fewer
+// impressions today go first among equals.
+func selectCampaignAds(channelID int64, now time.Time, maxAds int, maxSeconds int64) ([]adPodItem, error) {
+	var campaigns []models.AdCampaign
+	if err := helpers.GetXORM().Where("channel_id = ? AND enabled = ?", channelID, 1).Find(&campaigns); err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+
+	today := now.Format(campaignDateLayout)
+
+	var candidates []campaignCandidate
+	for _, campaign := range campaigns {
+		if !campaign.InFlight(today) {
+			continue
+		}
+
+		stats, err := GetAdCampaignStats(campaign.ID, now)
+		if err != nil {
+			return nil, err
+		}
+
+		// A booked ad counts against the cap and the spacing until it aired
+		if stats.QueuedAds > 0 {
+			continue
+		}
+		if campaign.HasDailyCap() && stats.ImpressionsToday >= int64(campaign.DailyCap) {
+			continue
+		}
+		spacing := int64(campaign.MinSpacingMinutes) * 60
+		if stats.LastImpressionAt > 0 && now.Unix()-stats.LastImpressionAt < spacing {
+			continue
+		}
+
+		fileID, videoLength, err := nextCampaignCreative(campaign.ID)
+		if err != nil {
+			return nil, err
+		}
+		if fileID == "" {
+			continue
+		}
+
+		candidates = append(candidates, campaignCandidate{
+			campaign:    campaign,
+			stats:       stats,
+			fileID:      fileID,
+			videoLength: videoLength,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.campaign.Priority != b.campaign.Priority {
+			return a.campaign.Priority > b.campaign.Priority
+		}
+		if a.stats.ImpressionsToday != b.stats.ImpressionsToday {
+			return a.stats.ImpressionsToday < b.stats.ImpressionsToday
+		}
+		return a.stats.LastImpressionAt < b.stats.LastImpressionAt
+	})
+
+	var items []adPodItem
+	var total int64
+	for _, candidate := range candidates {
+		if len(items) >= maxAds {
+			break
+		}
+		// Ads that don't fit leave room for shorter ones
+		if maxSeconds > 0 && total+candidate.videoLength > maxSeconds {
+			continue
+		}
+		items = append(items, adPodItem{FileID: candidate.fileID, CampaignID: candidate.campaign.ID})
+		total += candidate.videoLength
+	}
+
+	return items, nil
+}
+
+// nextCampaignCreative returns the creative of a campaign that aired least
+// recently, so creatives rotate. Creatives that are not approved or whose
+// file is missing are passed over.
+func nextCampaignCreative(campaignID int64) (string, int64, error) {
+	var rows []struct {
+		FileID      string `xorm:"file_id"`
+		VideoLength int64  `xorm:"video_length"`
+	}
+	err := helpers.GetXORM().SQL(`
+		SELECT c.file_id, f.video_length
+		FROM ad_campaign_creatives c
+		JOIN availible_files f ON f.file_id = c.file_id AND f.is_active = 1 AND f.missing_since = 0
+		LEFT JOIN play_history h ON h.file_id = c.file_id AND h.campaign_id = c.campaign_id
+		WHERE c.campaign_id = ?
+		GROUP BY c.id
+		ORDER BY COALESCE(MAX(h.started_at), 0) ASC, c.id ASC
+		LIMIT 1`, campaignID).Find(&rows)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to pick creative: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", 0, nil
+	}
+	return rows[0].FileID, rows[0].VideoLength, nil
+}
+
+// maybeScheduleAdBreak books an automatic ad break in front of the next video
+// once the configured programme time aired since the last ad. Breaks are only
+// placed between videos, a programme is never cut. Returns true if a break
+// was booked.
+func (p *PersistentPlayer) maybeScheduleAdBreak(next *models.VideoQueue) bool {
+	cfg := helpers.GetConfig().Ads
+	if cfg.BreakIntervalMinutes <= 0 {
+		return false
+	}
+
+	// An ad or a video to resume is up next, don't put a break in front of it
+	if next.IsAd == 1 || next.HasResumePosition() {
+		return false
+	}
+
+	programme, err := programmeSinceLastBreak(p.channelID)
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to compute programme time since last ad break")
+		return false
+	}
+	if programme < int64(cfg.BreakIntervalMinutes)*60 {
+		return false
+	}
+
+	maxAds := cfg.BreakMaxAds
+	if maxAds <= 0 {
+		maxAds = defaultBreakMaxAds
+	}
+
+	items, err := selectCampaignAds(p.channelID, time.Now(), maxAds, int64(cfg.BreakMaxSeconds))
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to select campaign ads")
+		return false
+	}
+
+	logger := p.logger.WithFields(logrus.Fields{
+		"programme_seconds": programme,
+		"interval_minutes":  cfg.BreakIntervalMinutes,
+	})
+
+	if len(items) == 0 {
+		logger.Debug("Ad break due, but no campaign is eligible")
+		return false
+	}
+
+	pod, err := createAdPod(p.channelID, items, 0)
+	if err != nil {
+		logger.WithError(err).Error("Failed to book automatic ad break")
+		return false
+	}
+
+	logger.WithFields(logrus.Fields{
+		"pod_id":   pod.ID,
+		"ad_count": len(items),
+	}).Info("✓ Automatic ad break booked")
+	return true
+}
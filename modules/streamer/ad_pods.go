@@ -0,0 +1,258 @@
+package streamer
+
+import (
+	"fmt"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// adPodItem is an ad of a pod together with the campaign that booked it
+type adPodItem struct {
+	FileID     string
+	CampaignID int64 // 0 for manually booked ads
+}
+
+// CreateAdPod puts an ad break at the front of the queue of a channel. The ads
+// air back to back in the given order. A planned duration of 0 uses the sum of
+// the ad durations.
+func CreateAdPod(channelID int64, fileIDs []string, plannedDuration int64) (*models.AdPod, error) {
+	items := make([]adPodItem, len(fileIDs))
+	for i, fileID := range fileIDs {
+		items[i] = adPodItem{FileID: fileID}
+	}
+	return createAdPod(channelID, items, plannedDuration)
+}
+
+func createAdPod(channelID int64, items []adPodItem, plannedDuration int64) (*models.AdPod, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "CreateAdPod",
+		"channel_id": channelID,
+		"ad_count":   len(items),
+	})
+
+	logger.Info("Creating ad pod...")
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("an ad pod needs at least one ad")
+	}
+	if plannedDuration < 0 {
+		return nil, fmt.Errorf("planned duration can't be negative")
+	}
+
+	var totalLength int64
+	for _, item := range items {
+		file, err := GetFileInfoByID(item.FileID)
+		if err != nil {
+			logger.WithError(err).WithField("file_id", item.FileID).Warn("Ad file not found in available files")
+			return nil, fmt.Errorf("ad file %s: %w", item.FileID, err)
+		}
+		totalLength += file.VideoLength
+	}
+
+	if plannedDuration == 0 {
+		plannedDuration = totalLength
+	}
+
+	now := time.Now().Unix()
+	pod := &models.AdPod{
+		ChannelID:              channelID,
+		PlannedDurationSeconds: plannedDuration,
+		AdCount:                len(items),
+		CreatedAt:              now,
+	}
+
+	session := helpers.GetXORM().NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := session.Insert(pod); err != nil {
+		session.Rollback()
+		logger.WithError(err).Error("Failed to insert ad pod")
+		return nil, fmt.Errorf("failed to create ad pod: %w", err)
+	}
+
+	// Make room at the front of the queue for the whole pod
+	if _, err := session.Exec("UPDATE video_queue SET queue_position = queue_position + ? WHERE played = 0 AND channel_id = ?", len(items), channelID); err != nil {
+		session.Rollback()
+		logger.WithError(err).Error("Failed to shift queue positions")
+		return nil, fmt.Errorf("failed to shift queue positions: %w", err)
+	}
+
+	for i, item := range items {
+		adItem := &models.VideoQueue{
+			FileID:        item.FileID,
+			AddedAt:       now,
+			Played:        0,
+			QueuePosition: i,
+			IsAd:          1,
+			ChannelID:     channelID,
+			PodID:         pod.ID,
+			CampaignID:    item.CampaignID,
+		}
+		if _, err := session.Insert(adItem); err != nil {
+			session.Rollback()
+			logger.WithError(err).WithField("file_id", item.FileID).Error("Failed to queue ad")
+			return nil, fmt.Errorf("failed to queue ad: %w", err)
+		}
+	}
+
+	if err := session.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit ad pod: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"pod_id":           pod.ID,
+		"planned_duration": plannedDuration,
+	}).Info("✓ Ad pod queued")
+
+	return pod, nil
+}
+
+// GetAdPods returns the most recent ad pods of a channel
+func GetAdPods(channelID int64, limit int) ([]models.AdPod, error) {
+	var pods []models.AdPod
+	if err := helpers.GetXORM().
+		Where("channel_id = ?", channelID).
+		OrderBy("id DESC").
+		Limit(limit).
+		Find(&pods); err != nil {
+		return nil, fmt.Errorf("failed to fetch ad pods: %w", err)
+	}
+	return pods, nil
+}
+
+// getAdPodsInWindow returns the pods of a channel that aired between from and
+// until, including pods still on air
+func getAdPodsInWindow(channelID int64, from, until int64) ([]models.AdPod, error) {
+	var pods []models.AdPod
+	if err := helpers.GetXORM().
+		Where("channel_id = ? AND started_at > 0 AND started_at <= ? AND (ended_at = 0 OR ended_at >= ?)", channelID, until, from).
+		OrderBy("started_at ASC").
+		Find(&pods); err != nil {
+		return nil, fmt.Errorf("failed to fetch ad pods: %w", err)
+	}
+	return pods, nil
+}
+
+// beginAdPod marks a pod as on air when the feed of its first ad starts, cue
+// markers are placed from that time on
+func (p *PersistentPlayer) beginAdPod(podID int64, startedAt time.Time) {
+	var pod models.AdPod
+	has, err := helpers.GetXORM().ID(podID).Get(&pod)
+	if err != nil || !has {
+		p.logger.WithError(err).WithField("pod_id", podID).Warn("Ad pod not found, ad airs without cue markers")
+		return
+	}
+
+	if !pod.IsStarted() {
+		pod.StartedAt = startedAt.Unix()
+		if _, err := helpers.GetXORM().ID(pod.ID).Cols("started_at").Update(&pod); err != nil {
+			p.logger.WithError(err).WithField("pod_id", pod.ID).Error("Failed to mark ad pod as started")
+		}
+
+		p.logger.WithFields(logrus.Fields{
+			"pod_id":           pod.ID,
+			"ad_count":         pod.AdCount,
+			"planned_duration": pod.PlannedDurationSeconds,
+		}).Info("📺 Ad pod started")
+
+		BroadcastAdPod(AdPodEvent{
+			Type:                   AdPodEventStart,
+			ChannelID:              p.channelID,
+			PodID:                  pod.ID,
+			AdCount:                pod.AdCount,
+			PlannedDurationSeconds: pod.PlannedDurationSeconds,
+			StartedAt:              pod.StartedAt,
+		})
+	}
+
+	p.mu.Lock()
+	p.adPod = &pod
+	p.mu.Unlock()
+}
+
+// finishAdPod ends a pod once none of its ads are left in the queue
+func (p *PersistentPlayer) finishAdPod(podID int64) {
+	remaining, err := helpers.GetXORM().Where("pod_id = ? AND played = ?", podID, 0).Count(&models.VideoQueue{})
+	if err != nil {
+		p.logger.WithError(err).WithField("pod_id", podID).Error("Failed to count remaining ads of pod")
+		return
+	}
+	if remaining > 0 {
+		return
+	}
+
+	var pod models.AdPod
+	has, err := helpers.GetXORM().ID(podID).Get(&pod)
+	if err != nil || !has || pod.IsEnded() {
+		return
+	}
+
+	pod.EndedAt = time.Now().Unix()
+	if _, err := helpers.GetXORM().ID(pod.ID).Cols("ended_at").Update(&pod); err != nil {
+		p.logger.WithError(err).WithField("pod_id", pod.ID).Error("Failed to mark ad pod as ended")
+	}
+
+	p.mu.Lock()
+	p.adPod = nil
+	p.mu.Unlock()
+
+	p.logger.WithFields(logrus.Fields{
+		"pod_id":           pod.ID,
+		"duration_seconds": pod.DurationSeconds(),
+		"planned_duration": pod.PlannedDurationSeconds,
+	}).Info("📺 Ad pod ended")
+
+	BroadcastAdPod(AdPodEvent{
+		Type:                   AdPodEventEnd,
+		ChannelID:              p.channelID,
+		PodID:                  pod.ID,
+		AdCount:                pod.AdCount,
+		PlannedDurationSeconds: pod.PlannedDurationSeconds,
+		StartedAt:              pod.StartedAt,
+		EndedAt:                pod.EndedAt,
+		DurationSeconds:        pod.DurationSeconds(),
+	})
+}
+
+// closeDanglingAdPods ends pods that were on air when the process stopped and
+// have no ads left to play. The end is taken from the history of their ads.
+func (p *PersistentPlayer) closeDanglingAdPods() {
+	var pods []models.AdPod
+	if err := helpers.GetXORM().
+		Where("channel_id = ? AND started_at > 0 AND ended_at = 0", p.channelID).
+		Find(&pods); err != nil {
+		p.logger.WithError(err).Error("Failed to query unfinished ad pods")
+		return
+	}
+
+	for _, pod := range pods {
+		remaining, err := helpers.GetXORM().Where("pod_id = ? AND played = ?", pod.ID, 0).Count(&models.VideoQueue{})
+		if err != nil || remaining > 0 {
+			continue
+		}
+
+		var endedAt int64
+		if _, err := helpers.GetXORM().SQL("SELECT COALESCE(MAX(finished_at), 0) FROM play_history WHERE pod_id = ?", pod.ID).Get(&endedAt); err != nil {
+			p.logger.WithError(err).WithField("pod_id", pod.ID).Warn("Failed to look up end of ad pod")
+		}
+		if endedAt < pod.StartedAt {
+			endedAt = pod.StartedAt
+		}
+
+		pod.EndedAt = endedAt
+		if _, err := helpers.GetXORM().ID(pod.ID).Cols("ended_at").Update(&pod); err != nil {
+			p.logger.WithError(err).WithField("pod_id", pod.ID).Error("Failed to close unfinished ad pod")
+			continue
+		}
+		p.logger.WithField("pod_id", pod.ID).Info("✓ Closed unfinished ad pod")
+	}
+}
@@ -0,0 +1,153 @@
+package streamer
+
+import (
+	"fmt"
+	"tv_streamer/helpers"
+	"tv_streamer/modules/streamer/models"
+)
+
+// Orders of the per-file statistics
+const (
+	StatsOrderAirtime  = "airtime"
+	StatsOrderPlays    = "plays"
+	StatsOrderSkipRate = "skip_rate"
+)
+
+// FileStats is the playback summary of a file over a range of days
+type FileStats struct {
+	FileID         string  `xorm:"file_id" json:"file_id"`
+	FilePath       string  `xorm:"filepath" json:"filepath"`
+	IsAd           int     `xorm:"is_ad" json:"is_ad"`
+	Plays          int64   `xorm:"plays" json:"plays"`
+	Skips          int64   `xorm:"skips" json:"skips"`
+	Interruptions  int64   `xorm:"interruptions" json:"interruptions"`
+	AirtimeSeconds int64   `xorm:"airtime_seconds" json:"airtime_seconds"`
+	SkipRate       float64 `xorm:"skip_rate" json:"skip_rate"`
+}
+
+// PlaybackSummary is the playback summary of a channel over a range of days
+type PlaybackSummary struct {
+	Plays                 int64   `json:"plays"`
+	Skips                 int64   `json:"skips"`
+	Interruptions         int64   `json:"interruptions"`
+	SkipRate              float64 `json:"skip_rate"`
+	AirtimeSeconds        int64   `json:"airtime_seconds"`
+	ContentPlays          int64   `json:"content_plays"`
+	ContentAirtimeSeconds int64   `json:"content_airtime_seconds"`
+	AdPlays               int64   `json:"ad_plays"`
+	AdAirtimeSeconds      int64   `json:"ad_airtime_seconds"`
+	AdContentRatio        float64 `json:"ad_content_ratio"` // ad airtime per second of content
+	AdShare               float64 `json:"ad_share"`         // share of the airtime that was ads
+}
+
+// skipRate returns the share of plays that were skipped
+func skipRate(skips, plays int64) float64 {
+	if plays == 0 {
+		return 0
+	}
+	return float64(skips) / float64(plays)
+}
+
+// GetDailyPlayStats returns the playback summary per file and day between
+// fromDay and toDay (YYYY-MM-DD, inclusive)
+func GetDailyPlayStats(channelID int64, fromDay, toDay string) ([]models.PlayStatsDaily, error) {
+	var stats []models.PlayStatsDaily
+	if err := helpers.GetXORM().
+		Where("channel_id = ? AND day >= ? AND day <= ?", channelID, fromDay, toDay).
+		OrderBy("day ASC, airtime_seconds DESC").
+		Find(&stats); err != nil {
+		return nil, fmt.Errorf("failed to fetch daily play stats: %w", err)
+	}
+	return stats, nil
+}
+
+// GetFileStats returns the playback summary per file between fromDay and
+// toDay (YYYY-MM-DD, inclusive) in the given order, limited to limit files
+// when limit is positive
+func GetFileStats(channelID int64, fromDay, toDay, order string, limit int) ([]FileStats, error) {
+	var orderBy string
+	switch order {
+	case "", StatsOrderAirtime:
+		orderBy = "airtime_seconds DESC, plays DESC"
+	case StatsOrderPlays:
+		orderBy = "plays DESC, airtime_seconds DESC"
+	case StatsOrderSkipRate:
+		orderBy = "skip_rate DESC, skips DESC"
+	default:
+		return nil, fmt.Errorf("invalid order %q: use %s, %s or %s", order, StatsOrderAirtime, StatsOrderPlays, StatsOrderSkipRate)
+	}
+
+	query := `
+		SELECT s.file_id,
+		       COALESCE(f.filepath, '') AS filepath,
+		       MAX(s.is_ad) AS is_ad,
+		       SUM(s.plays) AS plays,
+		       SUM(s.skips) AS skips,
+		       SUM(s.interruptions) AS interruptions,
+		       SUM(s.airtime_seconds) AS airtime_seconds,
+		       CASE WHEN SUM(s.plays) > 0 THEN CAST(SUM(s.skips) AS REAL) / SUM(s.plays) ELSE 0 END AS skip_rate
+		FROM play_stats_daily s
+		LEFT JOIN availible_files f ON f.file_id = s.file_id
+		WHERE s.channel_id = ? AND s.day >= ? AND s.day <= ?
+		GROUP BY s.file_id
+		ORDER BY ` + orderBy
+	args := []interface{}{channelID, fromDay, toDay}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	var stats []FileStats
+	if err := helpers.GetXORM().SQL(query, args...).Find(&stats); err != nil {
+		return nil, fmt.Errorf("failed to fetch file stats: %w", err)
+	}
+	return stats, nil
+}
+
+// GetPlaybackSummary returns the totals of a channel between fromDay and
+// toDay (YYYY-MM-DD, inclusive), split into content and ads
+func GetPlaybackSummary(channelID int64, fromDay, toDay string) (*PlaybackSummary, error) {
+	var totals []struct {
+		IsAd           int   `xorm:"is_ad"`
+		Plays          int64 `xorm:"plays"`
+		Skips          int64 `xorm:"skips"`
+		Interruptions  int64 `xorm:"interruptions"`
+		AirtimeSeconds int64 `xorm:"airtime_seconds"`
+	}
+	if err := helpers.GetXORM().SQL(`
+		SELECT is_ad,
+		       SUM(plays) AS plays,
+		       SUM(skips) AS skips,
+		       SUM(interruptions) AS interruptions,
+		       SUM(airtime_seconds) AS airtime_seconds
+		FROM play_stats_daily
+		WHERE channel_id = ? AND day >= ? AND day <= ?
+		GROUP BY is_ad`, channelID, fromDay, toDay).Find(&totals); err != nil {
+		return nil, fmt.Errorf("failed to fetch playback summary: %w", err)
+	}
+
+	summary := &PlaybackSummary{}
+	for _, total := range totals {
+		summary.Plays += total.Plays
+		summary.Skips += total.Skips
+		summary.Interruptions += total.Interruptions
+		summary.AirtimeSeconds += total.AirtimeSeconds
+		if total.IsAd == 1 {
+			summary.AdPlays += total.Plays
+			summary.AdAirtimeSeconds += total.AirtimeSeconds
+		} else {
+			summary.ContentPlays += total.Plays
+			summary.ContentAirtimeSeconds += total.AirtimeSeconds
+		}
+	}
+
+	summary.SkipRate = skipRate(summary.Skips, summary.Plays)
+	if summary.ContentAirtimeSeconds > 0 {
+		summary.AdContentRatio = float64(summary.AdAirtimeSeconds) / float64(summary.ContentAirtimeSeconds)
+	}
+	if summary.AirtimeSeconds > 0 {
+		summary.AdShare = float64(summary.AdAirtimeSeconds) / float64(summary.AirtimeSeconds)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,240 @@
+package streamer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Why an item left air before the end of its file, empty when it completed
+const (
+	SkipReasonUser        = "user"        // skipped through the API
+	SkipReasonStalled     = "stalled"     // FFmpeg stopped reading the file
+	SkipReasonFailed      = "failed"      // the file could not be played
+	SkipReasonInterrupted = "interrupted" // channel stopped, pipeline down or process restart
+)
+
+const (
+	// defaultAsRunRetentionDays is used when as_run.retention_days is not set
+	defaultAsRunRetentionDays = 90
+
+	// asRunCleanupInterval is how often expired as-run rows are removed
+	asRunCleanupInterval = 1 * time.Hour
+
+	// asRunChainGap breaks the planned timeline: an item starting longer than
+	// this after the previous one ended (channel stopped) was planned to
+	// start when it actually did
+	asRunChainGap = 5 * time.Minute
+)
+
+// AsRunDailyRow is the airtime of a file on one day
+type AsRunDailyRow struct {
+	Day            string `xorm:"day" json:"day"`
+	FileID         string `xorm:"file_id" json:"file_id"`
+	FilePath       string `xorm:"filepath" json:"filepath"`
+	IsAd           int    `xorm:"is_ad" json:"is_ad"`
+	Plays          int64  `xorm:"plays" json:"plays"`
+	Skips          int64  `xorm:"skips" json:"skips"`
+	Incomplete     int64  `xorm:"incomplete" json:"incomplete"`
+	AirtimeSeconds int64  `xorm:"airtime_seconds" json:"airtime_seconds"`
+}
+
+var (
+	asRunRetentionStop chan struct{}
+	asRunRetentionOnce sync.Once
+)
+
+// openAsRun records an item going on air. The planned start follows the
+// previous item: it should have started when that one reached the end of its
+// file.
+func (p *PersistentPlayer) openAsRun(video *models.VideoQueue, history *models.PlayHistory) {
+	entry := &models.AsRunEntry{
+		ChannelID:   p.channelID,
+		HistoryID:   history.ID,
+		FileID:      video.FileID,
+		IsAd:        video.IsAd,
+		PodID:       video.PodID,
+		CampaignID:  video.CampaignID,
+		ActualStart: history.StartedAt,
+	}
+
+	if file, err := GetFileInfoByID(video.FileID); err == nil {
+		entry.FilePath = file.FilePath
+		entry.PlannedDurationSeconds = file.VideoLength
+	}
+
+	entry.PlannedStart = entry.ActualStart
+	var previous models.AsRunEntry
+	has, err := helpers.GetXORM().
+		Where("channel_id = ? AND history_id != ?", p.channelID, history.ID).
+		OrderBy("actual_start DESC, id DESC").
+		Get(&previous)
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to look up previous as-run entry")
+	}
+	if has && previous.PlannedDurationSeconds > 0 {
+		previousEnd := previous.ActualEnd
+		if previousEnd == 0 {
+			previousEnd = previous.ActualStart + previous.DurationSeconds
+		}
+		if entry.ActualStart-previousEnd <= int64(asRunChainGap.Seconds()) {
+			entry.PlannedStart = previous.ActualStart + previous.PlannedDurationSeconds
+		}
+	}
+
+	if _, err := helpers.GetXORM().Insert(entry); err != nil {
+		p.logger.WithError(err).WithField("history_id", history.ID).Error("Failed to write as-run entry")
+		return
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"as_run_id":         entry.ID,
+		"deviation_seconds": entry.StartDeviationSeconds(),
+	}).Debug("✓ As-run entry opened")
+}
+
+// closeAsRun completes the as-run entry of a history record when the item
+// leaves air
+func closeAsRun(history *models.PlayHistory, skipReason string) {
+	if history == nil || history.ID == 0 {
+		return
+	}
+
+	entry := &models.AsRunEntry{
+		ActualEnd:       history.FinishedAt,
+		DurationSeconds: history.DurationSeconds,
+		SkipReason:      skipReason,
+	}
+	if _, err := helpers.GetXORM().
+		Where("history_id = ?", history.ID).
+		Cols("actual_end", "duration_seconds", "skip_reason").
+		Update(entry); err != nil {
+		logs.GetLogger().WithFields(logrus.Fields{
+			"module":     "streamer",
+			"function":   "closeAsRun",
+			"history_id": history.ID,
+		}).WithError(err).Error("Failed to close as-run entry")
+	}
+}
+
+// moveAsRunStart updates the actual start of an as-run entry when the item
+// went on air later than its history record was opened
+func moveAsRunStart(history *models.PlayHistory) {
+	if history == nil || history.ID == 0 {
+		return
+	}
+
+	entry := &models.AsRunEntry{ActualStart: history.StartedAt}
+	if _, err := helpers.GetXORM().
+		Where("history_id = ?", history.ID).
+		Cols("actual_start").
+		Update(entry); err != nil {
+		logs.GetLogger().WithFields(logrus.Fields{
+			"module":     "streamer",
+			"function":   "moveAsRunStart",
+			"history_id": history.ID,
+		}).WithError(err).Error("Failed to update as-run start")
+	}
+}
+
+// GetAsRunLog returns the as-run entries of a channel that started between
+// from (inclusive) and until (exclusive)
+func GetAsRunLog(channelID int64, from, until time.Time) ([]models.AsRunEntry, error) {
+	var entries []models.AsRunEntry
+	if err := helpers.GetXORM().
+		Where("channel_id = ? AND actual_start >= ? AND actual_start < ?", channelID, from.Unix(), until.Unix()).
+		OrderBy("actual_start ASC, id ASC").
+		Find(&entries); err != nil {
+		return nil, fmt.Errorf("failed to fetch as-run log: %w", err)
+	}
+	return entries, nil
+}
+
+// GetAsRunDaily rolls the as-run log of a channel up into airtime per file
+// and day (local time)
+func GetAsRunDaily(channelID int64, from, until time.Time) ([]AsRunDailyRow, error) {
+	var rows []AsRunDailyRow
+	err := helpers.GetXORM().SQL(`
+		SELECT date(actual_start, 'unixepoch', 'localtime') AS day,
+		       file_id,
+		       MAX(filepath) AS filepath,
+		       MAX(is_ad) AS is_ad,
+		       COUNT(*) AS plays,
+		       SUM(CASE WHEN skip_reason = ? THEN 1 ELSE 0 END) AS skips,
+		       SUM(CASE WHEN skip_reason != '' THEN 1 ELSE 0 END) AS incomplete,
+		       SUM(duration_seconds) AS airtime_seconds
+		FROM as_run_log
+		WHERE channel_id = ? AND actual_start >= ? AND actual_start < ?
+		GROUP BY day, file_id
+		ORDER BY day ASC, airtime_seconds DESC`,
+		SkipReasonUser, channelID, from.Unix(), until.Unix()).Find(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll up as-run log: %w", err)
+	}
+	return rows, nil
+}
+
+// CleanupAsRunLog removes as-run entries older than the retention period
+func CleanupAsRunLog(now time.Time) (int64, error) {
+	retentionDays := helpers.GetConfig().AsRun.RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = defaultAsRunRetentionDays
+	}
+
+	cutoff := now.AddDate(0, 0, -retentionDays).Unix()
+	deleted, err := helpers.GetXORM().Where("actual_start < ?", cutoff).Delete(&models.AsRunEntry{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to clean up as-run log: %w", err)
+	}
+	return deleted, nil
+}
+
+// startAsRunRetention removes expired as-run entries now and then every
+// asRunCleanupInterval until stopAsRunRetention is called
+func startAsRunRetention() {
+	asRunRetentionOnce.Do(func() {
+		asRunRetentionStop = make(chan struct{})
+		go runAsRunRetention(asRunRetentionStop)
+	})
+}
+
+// stopAsRunRetention stops the retention job started by startAsRunRetention
+func stopAsRunRetention() {
+	if asRunRetentionStop != nil {
+		select {
+		case <-asRunRetentionStop:
+		default:
+			close(asRunRetentionStop)
+		}
+	}
+}
+
+func runAsRunRetention(stop <-chan struct{}) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "runAsRunRetention",
+	})
+
+	ticker := time.NewTicker(asRunCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := CleanupAsRunLog(time.Now())
+		if err != nil {
+			logger.WithError(err).Error("Failed to apply as-run retention")
+		} else if deleted > 0 {
+			logger.WithField("deleted", deleted).Info("✓ Expired as-run entries removed")
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
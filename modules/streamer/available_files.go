@@ -2,6 +2,7 @@ package streamer
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,6 +14,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ErrFileNotFound is returned when a file is not in the library
+var ErrFileNotFound = errors.New("file not found")
+
 // GetAvailableFiles returns all files from the availible_files table
 func GetAvailableFiles() ([]models.AvailableFiles, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
@@ -89,7 +93,7 @@ func UpdateFileDescription(fileID string, description string) error {
 
 	if affected == 0 {
 		logger.WithField("file_id", fileID).Warn("File not found")
-		return fmt.Errorf("file not found")
+		return ErrFileNotFound
 	}
 
 	logger.WithField("file_id", fileID).Info("✓ File description updated successfully")
@@ -114,8 +118,20 @@ func NormalizeFilePath(path string) (string, error) {
 
 // AddToAvailableFiles adds a file to the availible_files table
 // This is the only place where files should be added to availible_files
-// Returns the file_id of the added (or existing) file
+// Returns the file_id of the added (or existing) file. A known file that was
+// moved or renamed to filePath keeps its file_id.
 func AddToAvailableFiles(filePath string) (string, error) {
+	file, _, err := addAvailableFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return file.FileID, nil
+}
+
+// addAvailableFile adds a file to the library, or recognizes it by its
+// content fingerprint as a known file that was moved to filePath and updates
+// the filepath of that file in place. previousPath is set for a moved file.
+func addAvailableFile(filePath string) (file *models.AvailableFiles, previousPath string, err error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
 		"module":   "streamer",
 		"function": "AddToAvailableFiles",
@@ -127,68 +143,95 @@ func AddToAvailableFiles(filePath string) (string, error) {
 	normalizedPath, err := NormalizeFilePath(filePath)
 	if err != nil {
 		logger.WithError(err).Error("Failed to normalize filepath")
-		return "", fmt.Errorf("failed to normalize filepath: %w", err)
+		return nil, "", fmt.Errorf("failed to normalize filepath: %w", err)
 	}
 
 	filePath = normalizedPath
 	logger.WithField("normalized_filepath", filePath).Debug("Filepath normalized")
 
-	// Generate file ID (MD5 of normalized filepath)
-	fileID := fmt.Sprintf("%x", md5.Sum([]byte(filePath)))
-	logger.WithField("file_id", fileID).Debug("Generated file ID")
-
 	// Check if file already exists in availible_files
 	var existingFile models.AvailableFiles
-	has, err := helpers.GetXORM().Where("file_id = ?", fileID).Get(&existingFile)
+	has, err := helpers.GetXORM().Where("filepath = ?", filePath).Get(&existingFile)
 	if err != nil {
 		logger.WithError(err).Error("Failed to query available files")
-		return "", fmt.Errorf("database error: %w", err)
+		return nil, "", fmt.Errorf("database error: %w", err)
 	}
 
 	if has {
-		logger.WithField("file_id", fileID).Debug("File already exists in available files")
-		return fileID, nil
+		logger.WithField("file_id", existingFile.FileID).Debug("File already exists in available files")
+		return &existingFile, "", nil
 	}
 
-	// Get file info from filesystem
-	fileInfo, err := os.Stat(filePath)
+	// Hash and probe the file
+	identity, err := identifyFile(filePath)
 	if err != nil {
-		logger.WithError(err).Error("File does not exist on filesystem")
-		return "", fmt.Errorf("file does not exist: %w", err)
+		logger.WithError(err).Error("Failed to identify file")
+		return nil, "", err
 	}
 
-	// Get ffprobe data
-	ffprobeData, err := GetFFProbeData(filePath)
+	// A known file at a new location keeps its file_id
+	moved, err := findMovedFile(filePath, identity)
 	if err != nil {
-		logger.WithError(err).Warn("Failed to get ffprobe data, using empty JSON")
-		ffprobeData = "{}"
+		logger.WithError(err).Error("Failed to look up moved file")
+		return nil, "", err
+	}
+	if moved != nil {
+		previousPath := moved.FilePath
+		if err := relocateAvailableFile(moved, filePath, identity); err != nil {
+			logger.WithError(err).Error("Failed to update moved file")
+			return nil, "", err
+		}
+		return moved, previousPath, nil
 	}
 
-	// Parse video duration
-	videoLength := ParseDuration(ffprobeData)
+	fileID, err := newFileID(filePath)
+	if err != nil {
+		logger.WithError(err).Error("Failed to generate file ID")
+		return nil, "", err
+	}
+	logger.WithField("file_id", fileID).Debug("Generated file ID")
 
 	// Insert into availible_files table
 	newFile := models.AvailableFiles{
 		FileID:      fileID,
 		FilePath:    filePath,
-		FileSize:    fileInfo.Size(),
-		VideoLength: videoLength,
+		FileSize:    identity.size,
+		VideoLength: identity.videoLength,
 		AddedTime:   time.Now().Unix(),
-		FFProbeData: ffprobeData,
+		FFProbeData: identity.ffprobeData,
+		Fingerprint: identity.fingerprint,
 	}
+	applyLibraryReview(&newFile)
 
 	if _, err := helpers.GetXORM().Insert(&newFile); err != nil {
 		logger.WithError(err).Error("Failed to insert into available files")
-		return "", fmt.Errorf("failed to add to available files: %w", err)
+		return nil, "", fmt.Errorf("failed to add to available files: %w", err)
 	}
 
 	logger.WithFields(logrus.Fields{
 		"file_id":      fileID,
-		"video_length": videoLength,
-		"file_size":    fileInfo.Size(),
+		"video_length": identity.videoLength,
+		"file_size":    identity.size,
 	}).Info("✓ File added to available files")
 
-	return fileID, nil
+	return &newFile, "", nil
+}
+
+// newFileID generates the file_id of a new file: the MD5 of its normalized
+// filepath. A moved file keeps the file_id of its old path, so when a new
+// file shows up at that path a suffix is added until the ID is unused.
+func newFileID(filePath string) (string, error) {
+	fileID := fmt.Sprintf("%x", md5.Sum([]byte(filePath)))
+	for n := 1; ; n++ {
+		taken, err := helpers.GetXORM().Where("file_id = ?", fileID).Exist(&models.AvailableFiles{})
+		if err != nil {
+			return "", fmt.Errorf("database error: %w", err)
+		}
+		if !taken {
+			return fileID, nil
+		}
+		fileID = fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%s#%d", filePath, n))))
+	}
 }
 
 // GetFilePathByID looks up the filepath for a given file_id
@@ -208,7 +251,7 @@ func GetFilePathByID(fileID string) (string, error) {
 
 	if !has {
 		logger.Warn("File not found")
-		return "", fmt.Errorf("file not found")
+		return "", ErrFileNotFound
 	}
 
 	return file.FilePath, nil
@@ -231,8 +274,107 @@ func GetFileInfoByID(fileID string) (*models.AvailableFiles, error) {
 
 	if !has {
 		logger.Warn("File not found")
-		return nil, fmt.Errorf("file not found")
+		return nil, ErrFileNotFound
+	}
+
+	return &file, nil
+}
+
+// GetFileInfoByPath looks up the full file info for a given filepath
+func GetFileInfoByPath(filePath string) (*models.AvailableFiles, error) {
+	normalizedPath, err := NormalizeFilePath(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize filepath: %w", err)
+	}
+
+	var file models.AvailableFiles
+	has, err := helpers.GetXORM().Where("filepath = ?", normalizedPath).Get(&file)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if !has {
+		return nil, ErrFileNotFound
 	}
 
 	return &file, nil
 }
+
+// RefreshAvailableFile probes a file again after it changed on disk and
+// clears its missing marker
+func RefreshAvailableFile(file *models.AvailableFiles) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "RefreshAvailableFile",
+		"file_id":  file.FileID,
+	})
+
+	fileInfo, err := os.Stat(file.FilePath)
+	if err != nil {
+		return fmt.Errorf("file does not exist: %w", err)
+	}
+
+	fingerprint, err := ComputeFingerprint(file.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to fingerprint file: %w", err)
+	}
+
+	ffprobeData, err := GetFFProbeData(file.FilePath)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get ffprobe data, using empty JSON")
+		ffprobeData = "{}"
+	}
+
+	file.FileSize = fileInfo.Size()
+	file.Fingerprint = fingerprint
+	file.FFProbeData = ffprobeData
+	file.VideoLength = ParseDuration(ffprobeData)
+	file.MissingSince = 0
+
+	if _, err := helpers.GetXORM().
+		Where("file_id = ?", file.FileID).
+		Cols("file_size", "fingerprint", "ffprobe_data", "video_length", "missing_since").
+		Update(file); err != nil {
+		return fmt.Errorf("failed to update available file: %w", err)
+	}
+
+	// A normalized copy of the old content must not air
+	RemoveNormalizedCache(file.FileID)
+
+	logger.WithFields(logrus.Fields{
+		"video_length": file.VideoLength,
+		"file_size":    file.FileSize,
+	}).Info("✓ Available file refreshed")
+
+	return nil
+}
+
+// MarkFilesMissing marks the file at path, or every file below it when path
+// was a directory, as deleted from disk. Returns the files that were marked.
+func MarkFilesMissing(path string) ([]models.AvailableFiles, error) {
+	normalizedPath, err := NormalizeFilePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize filepath: %w", err)
+	}
+	prefix := normalizedPath + string(filepath.Separator)
+
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().
+		Where("missing_since = 0 AND (filepath = ? OR substr(filepath, 1, ?) = ?)", normalizedPath, len(prefix), prefix).
+		Find(&files); err != nil {
+		return nil, fmt.Errorf("failed to query available files: %w", err)
+	}
+
+	now := time.Now().Unix()
+	for i := range files {
+		files[i].MissingSince = now
+		if _, err := helpers.GetXORM().
+			Where("file_id = ?", files[i].FileID).
+			Cols("missing_since").
+			Update(&files[i]); err != nil {
+			return nil, fmt.Errorf("failed to mark file missing: %w", err)
+		}
+	}
+
+	return files, nil
+}
@@ -4,7 +4,66 @@ import "sync"
 
 // Broadcaster is an interface for broadcasting events
 type Broadcaster interface {
-	BroadcastCurrentlyPlaying(fileID string, startedTime int64)
+	BroadcastCurrentlyPlaying(channelID int64, fileID string, startedTime int64)
+	BroadcastAdPod(event AdPodEvent)
+	BroadcastIngest(event IngestEvent)
+	BroadcastUploadValidated(event UploadValidatedEvent)
+}
+
+// Ad pod event types
+const (
+	AdPodEventStart = "ad_pod_start"
+	AdPodEventEnd   = "ad_pod_end"
+)
+
+// AdPodEvent reports the start or end of an ad break
+type AdPodEvent struct {
+	Type                   string `json:"type"`
+	ChannelID              int64  `json:"channel_id"`
+	PodID                  int64  `json:"pod_id"`
+	AdCount                int    `json:"ad_count"`
+	PlannedDurationSeconds int64  `json:"planned_duration_seconds"`
+	StartedAt              int64  `json:"started_at"`
+	EndedAt                int64  `json:"ended_at,omitempty"`
+	DurationSeconds        int64  `json:"duration_seconds,omitempty"`
+}
+
+// Ingest event types
+const (
+	IngestEventAdded   = "ingest_added"
+	IngestEventUpdated = "ingest_updated"
+	IngestEventMoved   = "ingest_moved"
+	IngestEventMissing = "ingest_missing"
+	IngestEventFailed  = "ingest_failed"
+)
+
+// IngestEvent reports a library file picked up, changed, moved or deleted
+// on disk
+type IngestEvent struct {
+	Type         string `json:"type"`
+	FileID       string `json:"file_id,omitempty"`
+	FilePath     string `json:"filepath"`
+	PreviousPath string `json:"previous_path,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	VideoLength  int64  `json:"video_length,omitempty"`
+	Error        string `json:"error,omitempty"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+// UploadEventValidated is the type of the event sent when the background
+// validation of an upload finished
+const UploadEventValidated = "upload_validated"
+
+// UploadValidatedEvent reports the validation result of an upload. SessionID
+// is empty for uploads validated again after a restart.
+type UploadValidatedEvent struct {
+	Type       string            `json:"type"`
+	SessionID  string            `json:"session_id,omitempty"`
+	FileID     string            `json:"file_id"`
+	Success    bool              `json:"success"`
+	Message    string            `json:"message"`
+	Validation *ValidationReport `json:"validation"`
+	Timestamp  int64             `json:"timestamp"`
 }
 
 var (
@@ -27,9 +86,34 @@ func GetBroadcaster() Broadcaster {
 }
 
 // BroadcastCurrentlyPlaying broadcasts currently playing info (helper function)
-func BroadcastCurrentlyPlaying(fileID string, startedTime int64) {
+func BroadcastCurrentlyPlaying(channelID int64, fileID string, startedTime int64) {
+	b := GetBroadcaster()
+	if b != nil {
+		b.BroadcastCurrentlyPlaying(channelID, fileID, startedTime)
+	}
+}
+
+// BroadcastAdPod broadcasts an ad pod start or end (helper function)
+func BroadcastAdPod(event AdPodEvent) {
+	b := GetBroadcaster()
+	if b != nil {
+		b.BroadcastAdPod(event)
+	}
+}
+
+// BroadcastIngest broadcasts a library ingest event (helper function)
+func BroadcastIngest(event IngestEvent) {
+	b := GetBroadcaster()
+	if b != nil {
+		b.BroadcastIngest(event)
+	}
+}
+
+// BroadcastUploadValidated broadcasts the validation result of an upload
+// (helper function)
+func BroadcastUploadValidated(event UploadValidatedEvent) {
 	b := GetBroadcaster()
 	if b != nil {
-		b.BroadcastCurrentlyPlaying(fileID, startedTime)
+		b.BroadcastUploadValidated(event)
 	}
 }
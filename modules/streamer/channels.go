@@ -0,0 +1,208 @@
+package streamer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	players   = make(map[int64]*PersistentPlayer)
+	playersMu sync.Mutex
+)
+
+// GetChannelPlayer returns the player of a channel, creating it on first use
+func GetChannelPlayer(channelID int64) (*PersistentPlayer, error) {
+	playersMu.Lock()
+	player, ok := players[channelID]
+	playersMu.Unlock()
+	if ok {
+		return player, nil
+	}
+
+	channel, err := GetChannel(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	return registerChannelPlayer(channel), nil
+}
+
+// registerChannelPlayer creates the player of a channel unless one already exists
+func registerChannelPlayer(channel *models.Channel) *PersistentPlayer {
+	playersMu.Lock()
+	defer playersMu.Unlock()
+
+	if player, ok := players[channel.ID]; ok {
+		return player
+	}
+
+	player := newPersistentPlayer(channel)
+	players[channel.ID] = player
+	return player
+}
+
+// lookupChannelPlayer returns the player of a channel if it has been created
+func lookupChannelPlayer(channelID int64) *PersistentPlayer {
+	playersMu.Lock()
+	defer playersMu.Unlock()
+	return players[channelID]
+}
+
+// IsChannelRunning returns true if the player of a channel is streaming
+func IsChannelRunning(channelID int64) bool {
+	player := lookupChannelPlayer(channelID)
+	return player != nil && player.IsRunning()
+}
+
+// CreateChannel stores a new channel. Its player is created on first use.
+func CreateChannel(channel *models.Channel) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "CreateChannel",
+		"name":     channel.Name,
+	})
+
+	logger.Info("Creating channel...")
+
+	channel.Name = strings.TrimSpace(channel.Name)
+	if channel.Name == "" {
+		return fmt.Errorf("channel name is required")
+	}
+
+	channel.Enabled = 1
+	channel.CreatedAt = time.Now().Unix()
+
+	if _, err := helpers.GetXORM().Insert(channel); err != nil {
+		logger.WithError(err).Error("Failed to insert channel")
+		return fmt.Errorf("failed to create channel: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"channel_id": channel.ID,
+		"output_dir": channel.ResolveOutputDir(ActiveStreamingProfile().OutputDir),
+	}).Info("✓ Channel created successfully")
+
+	return nil
+}
+
+// GetChannels returns all channels ordered by ID
+func GetChannels() ([]models.Channel, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "GetChannels",
+	})
+
+	var channels []models.Channel
+	if err := helpers.GetXORM().OrderBy("id ASC").Find(&channels); err != nil {
+		logger.WithError(err).Error("Failed to fetch channels")
+		return nil, fmt.Errorf("failed to fetch channels: %w", err)
+	}
+
+	logger.WithField("total_channels", len(channels)).Debug("✓ Channels fetched successfully")
+
+	return channels, nil
+}
+
+// GetChannel returns a single channel
+func GetChannel(channelID int64) (*models.Channel, error) {
+	var channel models.Channel
+	has, err := helpers.GetXORM().ID(channelID).Get(&channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query channel: %w", err)
+	}
+	if !has {
+		return nil, fmt.Errorf("channel not found")
+	}
+	return &channel, nil
+}
+
+// StartChannel starts the streaming pipeline of a channel
+func StartChannel(channelID int64) error {
+	player, err := GetChannelPlayer(channelID)
+	if err != nil {
+		return err
+	}
+	return player.Start()
+}
+
+// StopChannel stops the streaming pipeline of a channel
+func StopChannel(channelID int64) error {
+	if !IsChannelRunning(channelID) {
+		return fmt.Errorf("channel is not running")
+	}
+	return lookupChannelPlayer(channelID).Stop()
+}
+
+// DeleteChannel stops a channel and removes it together with its queue,
+// schedule, time slots and play history. The default channel can't be deleted.
+func DeleteChannel(channelID int64) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "DeleteChannel",
+		"channel_id": channelID,
+	})
+
+	logger.Info("Deleting channel...")
+
+	if channelID == models.DefaultChannelID {
+		return fmt.Errorf("the default channel can't be deleted")
+	}
+
+	if _, err := GetChannel(channelID); err != nil {
+		logger.WithError(err).Warn("Channel not found")
+		return err
+	}
+
+	if player := lookupChannelPlayer(channelID); player != nil {
+		if player.IsRunning() {
+			if err := player.Stop(); err != nil {
+				logger.WithError(err).Warn("Failed to stop channel player")
+			}
+		}
+		playersMu.Lock()
+		delete(players, channelID)
+		playersMu.Unlock()
+	}
+
+	session := helpers.GetXORM().NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// channel_id columns have no foreign key, remove dependent rows explicitly
+	statements := []string{
+		"DELETE FROM schedule_slot_items WHERE slot_id IN (SELECT id FROM schedule_slots WHERE channel_id = ?)",
+		"DELETE FROM schedule_slots WHERE channel_id = ?",
+		"DELETE FROM schedule WHERE channel_id = ?",
+		"DELETE FROM video_queue WHERE channel_id = ?",
+		"DELETE FROM play_history WHERE channel_id = ?",
+		"DELETE FROM play_stats_daily WHERE channel_id = ?",
+		"DELETE FROM ad_pods WHERE channel_id = ?",
+		// ad_campaign_creatives cascades
+		"DELETE FROM ad_campaigns WHERE channel_id = ?",
+		"DELETE FROM channels WHERE id = ?",
+	}
+	for _, statement := range statements {
+		if _, err := session.Exec(statement, channelID); err != nil {
+			session.Rollback()
+			logger.WithError(err).Error("Failed to delete channel data")
+			return fmt.Errorf("failed to delete channel: %w", err)
+		}
+	}
+
+	if err := session.Commit(); err != nil {
+		return fmt.Errorf("failed to commit channel deletion: %w", err)
+	}
+
+	logger.Info("✓ Channel deleted")
+	return nil
+}
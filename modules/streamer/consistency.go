@@ -0,0 +1,394 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Kinds of library inconsistencies
+const (
+	IssueMissingFile      = "missing_file"      // library file gone from disk
+	IssueUntrackedFile    = "untracked_file"    // video in a library folder that is not in the library
+	IssueStaleProbe       = "stale_probe"       // file changed on disk since it was probed
+	IssueQueueDangling    = "queue_dangling"    // unplayed queue entry of a missing or unknown file
+	IssueScheduleDangling = "schedule_dangling" // schedule entry of a missing or unknown file
+	IssueSlotDangling     = "slot_dangling"     // programming grid item of a missing or unknown file
+)
+
+// ErrConsistencyCheckRunning is returned when a check is started while
+// another one is running
+var ErrConsistencyCheckRunning = errors.New("a consistency check is already running")
+
+// ConsistencyIssue is an inconsistency between the library and the disk
+type ConsistencyIssue struct {
+	Kind      string `json:"kind"`
+	FileID    string `json:"file_id,omitempty"`
+	FilePath  string `json:"filepath,omitempty"`
+	ChannelID int64  `json:"channel_id,omitempty"`
+	RowID     int64  `json:"row_id,omitempty"` // queue, schedule or slot item ID
+	Detail    string `json:"detail"`
+	Fixed     bool   `json:"fixed"`
+	Error     string `json:"error,omitempty"` // why the fix failed
+}
+
+// ConsistencyReport is the result of a library consistency check
+type ConsistencyReport struct {
+	StartedAt    int64              `json:"started_at"`
+	FinishedAt   int64              `json:"finished_at"`
+	Fix          bool               `json:"fix"`
+	FilesChecked int                `json:"files_checked"`
+	Counts       map[string]int     `json:"counts"`
+	Fixed        int                `json:"fixed"`
+	Issues       []ConsistencyIssue `json:"issues"`
+}
+
+var (
+	consistencyMu   sync.Mutex // held while a check runs
+	lastReport      *ConsistencyReport
+	lastReportMu    sync.RWMutex
+	consistencyStop chan struct{}
+	consistencyOnce sync.Once
+)
+
+// LastConsistencyReport returns the report of the last check, nil if no check
+// ran since startup
+func LastConsistencyReport() *ConsistencyReport {
+	lastReportMu.RLock()
+	defer lastReportMu.RUnlock()
+	return lastReport
+}
+
+// CheckLibraryConsistency compares the library with the disk: files that are
+// gone, videos in the library folders that are not in the library, files
+// that changed since they were probed and queue, schedule and programming
+// grid entries of files that can't be played. With fix the issues are
+// repaired: untracked files are ingested (which recognizes moved files),
+// missing files are marked missing, stale files are probed again and dangling
+// entries are removed.
+func CheckLibraryConsistency(fix bool) (*ConsistencyReport, error) {
+	if !consistencyMu.TryLock() {
+		return nil, ErrConsistencyCheckRunning
+	}
+	defer consistencyMu.Unlock()
+
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "CheckLibraryConsistency",
+		"fix":      fix,
+	})
+
+	logger.Info("Checking library consistency...")
+
+	report := &ConsistencyReport{
+		StartedAt: time.Now().Unix(),
+		Fix:       fix,
+		Counts:    map[string]int{},
+		Issues:    []ConsistencyIssue{},
+	}
+	add := func(issue ConsistencyIssue) {
+		report.Counts[issue.Kind]++
+		if issue.Fixed {
+			report.Fixed++
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// Untracked files first: ingesting them recognizes moved files, which are
+	// then no longer missing
+	untracked, err := findUntrackedFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range untracked {
+		issue := ConsistencyIssue{
+			Kind:     IssueUntrackedFile,
+			FilePath: path,
+			Detail:   "video in a library folder is not in the library",
+		}
+		if fix {
+			issue.Fixed, issue.Error = fixResult(ingestLibraryFile(logger, path))
+			if file, err := GetFileInfoByPath(path); err == nil {
+				issue.FileID = file.FileID
+			}
+		}
+		add(issue)
+	}
+
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().OrderBy("added_time ASC").Find(&files); err != nil {
+		return nil, fmt.Errorf("failed to query available files: %w", err)
+	}
+	report.FilesChecked = len(files)
+
+	unplayable := map[string]bool{}
+	for i := range files {
+		file := &files[i]
+
+		fileInfo, err := os.Stat(file.FilePath)
+		if errors.Is(err, os.ErrNotExist) {
+			unplayable[file.FileID] = true
+			issue := ConsistencyIssue{
+				Kind:     IssueMissingFile,
+				FileID:   file.FileID,
+				FilePath: file.FilePath,
+				Detail:   "file is gone from disk",
+			}
+			if file.IsMissing() {
+				issue.Detail = fmt.Sprintf("file is gone from disk, marked missing since %s", time.Unix(file.MissingSince, 0).Format(time.RFC3339))
+			} else if fix {
+				issue.Fixed, issue.Error = fixResult(markLibraryFilesMissing(logger, file.FilePath))
+			}
+			add(issue)
+			continue
+		}
+		if err != nil {
+			logger.WithError(err).WithField("filepath", file.FilePath).Warn("Failed to stat library file")
+			continue
+		}
+
+		// Uploads are probed, and may be replaced by a conformed copy, when
+		// their validation finishes
+		if file.ValidationStatus == ValidationProcessing {
+			continue
+		}
+
+		if detail := staleProbeDetail(file, fileInfo); detail != "" {
+			issue := ConsistencyIssue{
+				Kind:     IssueStaleProbe,
+				FileID:   file.FileID,
+				FilePath: file.FilePath,
+				Detail:   detail,
+			}
+			if fix {
+				issue.Fixed, issue.Error = fixResult(RefreshAvailableFile(file))
+			}
+			add(issue)
+		}
+	}
+
+	for _, issue := range findDanglingEntries(logger, unplayable, fix) {
+		add(issue)
+	}
+
+	report.FinishedAt = time.Now().Unix()
+
+	lastReportMu.Lock()
+	lastReport = report
+	lastReportMu.Unlock()
+
+	logger.WithFields(logrus.Fields{
+		"files_checked": report.FilesChecked,
+		"issues":        len(report.Issues),
+		"fixed":         report.Fixed,
+	}).Info("✓ Library consistency checked")
+
+	return report, nil
+}
+
+// fixResult converts the error of a fix to the fixed flag and error of an issue
+func fixResult(err error) (bool, string) {
+	if err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// findUntrackedFiles returns the videos in the library folders that are not
+// in the library
+func findUntrackedFiles() ([]string, error) {
+	var untracked []string
+	for _, folder := range libraryFolders() {
+		err := filepath.WalkDir(folder, func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				if path == folder {
+					return err
+				}
+				return nil
+			}
+			if path != folder && isHiddenPath(path) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() || !hasVideoExtension(path, DefaultVideoExtensions) {
+				return nil
+			}
+
+			known, err := helpers.GetXORM().Where("filepath = ?", path).Exist(&models.AvailableFiles{})
+			if err != nil {
+				return fmt.Errorf("failed to query available files: %w", err)
+			}
+			if !known {
+				untracked = append(untracked, path)
+			}
+			return nil
+		})
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk library folder %s: %w", folder, err)
+		}
+	}
+	return untracked, nil
+}
+
+// staleProbeDetail returns why the stored probe data of a file no longer
+// matches the file on disk, empty if it does
+func staleProbeDetail(file *models.AvailableFiles, fileInfo os.FileInfo) string {
+	if fileInfo.Size() != file.FileSize {
+		return fmt.Sprintf("size changed from %d to %d bytes", file.FileSize, fileInfo.Size())
+	}
+	if file.FFProbeData == "" || file.FFProbeData == "{}" {
+		return "no ffprobe data"
+	}
+	if file.Fingerprint != "" {
+		fingerprint, err := ComputeFingerprint(file.FilePath)
+		if err == nil && fingerprint != file.Fingerprint {
+			return "content changed"
+		}
+	}
+	return ""
+}
+
+// findDanglingEntries returns the unplayed queue entries, schedule entries and
+// programming grid items of files that are unplayable or not in the library,
+// and removes them with fix
+func findDanglingEntries(logger *logrus.Entry, unplayable map[string]bool, fix bool) []ConsistencyIssue {
+	type entry struct {
+		ID        int64  `xorm:"'id'"`
+		FileID    string `xorm:"'file_id'"`
+		ChannelID int64  `xorm:"'channel_id'"`
+		Known     int    `xorm:"'known'"`
+	}
+
+	sources := []struct {
+		kind  string
+		table string
+		query string
+	}{
+		{IssueQueueDangling, "video_queue",
+			`SELECT q.id, q.file_id, q.channel_id, f.file_id IS NOT NULL AS known
+			FROM video_queue q LEFT JOIN availible_files f ON f.file_id = q.file_id
+			WHERE q.played = 0`},
+		{IssueScheduleDangling, "schedule",
+			`SELECT s.id, s.file_id, s.channel_id, f.file_id IS NOT NULL AS known
+			FROM schedule s LEFT JOIN availible_files f ON f.file_id = s.file_id`},
+		{IssueSlotDangling, "schedule_slot_items",
+			`SELECT i.id, i.file_id, COALESCE(sl.channel_id, 0) AS channel_id, f.file_id IS NOT NULL AS known
+			FROM schedule_slot_items i
+			LEFT JOIN schedule_slots sl ON sl.id = i.slot_id
+			LEFT JOIN availible_files f ON f.file_id = i.file_id`},
+	}
+
+	issues := []ConsistencyIssue{}
+	for _, source := range sources {
+		var entries []entry
+		if err := helpers.GetXORM().SQL(source.query).Find(&entries); err != nil {
+			logger.WithError(err).WithField("table", source.table).Error("Failed to query entries")
+			continue
+		}
+
+		for _, e := range entries {
+			if e.Known == 1 && !unplayable[e.FileID] {
+				continue
+			}
+
+			issue := ConsistencyIssue{
+				Kind:      source.kind,
+				FileID:    e.FileID,
+				ChannelID: e.ChannelID,
+				RowID:     e.ID,
+				Detail:    "file is gone from disk",
+			}
+			if e.Known == 1 {
+				issue.FilePath, _ = GetFilePathByID(e.FileID)
+			} else {
+				issue.Detail = "file is not in the library"
+			}
+
+			if fix {
+				_, err := helpers.GetXORM().Exec(fmt.Sprintf("DELETE FROM %s WHERE id = ?", source.table), e.ID)
+				issue.Fixed, issue.Error = fixResult(err)
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues
+}
+
+// startConsistencyCheck runs the library consistency check every
+// library.check_interval_minutes, repairing what it finds if
+// library.check_auto_fix is set
+func startConsistencyCheck() {
+	cfg := helpers.GetConfig()
+	if cfg.Library.CheckIntervalMinutes <= 0 {
+		logs.GetLogger().WithFields(logrus.Fields{
+			"module":   "streamer",
+			"function": "startConsistencyCheck",
+		}).Info("Periodic library consistency check is disabled")
+		return
+	}
+
+	consistencyOnce.Do(func() {
+		consistencyStop = make(chan struct{})
+		go runConsistencyCheck(consistencyStop,
+			time.Duration(cfg.Library.CheckIntervalMinutes)*time.Minute, cfg.Library.CheckAutoFix)
+	})
+}
+
+// stopConsistencyCheck stops the job started by startConsistencyCheck
+func stopConsistencyCheck() {
+	if consistencyStop != nil {
+		select {
+		case <-consistencyStop:
+		default:
+			close(consistencyStop)
+		}
+	}
+}
+
+func runConsistencyCheck(stop <-chan struct{}, interval time.Duration, fix bool) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "runConsistencyCheck",
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		report, err := CheckLibraryConsistency(fix)
+		if errors.Is(err, ErrConsistencyCheckRunning) {
+			continue
+		}
+		if err != nil {
+			logger.WithError(err).Error("Library consistency check failed")
+			continue
+		}
+		if len(report.Issues) > report.Fixed {
+			logger.WithFields(logrus.Fields{
+				"issues": len(report.Issues),
+				"fixed":  report.Fixed,
+				"counts": report.Counts,
+			}).Warn("Library consistency check found issues")
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package streamer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"tv_streamer/modules/streamer/models"
+)
+
+// Cue markers written around ad pods in the served media playlists
+const (
+	AdMarkersCue       = "cue"       // #EXT-X-CUE-OUT / #EXT-X-CUE-OUT-CONT / #EXT-X-CUE-IN
+	AdMarkersDateRange = "daterange" // #EXT-X-DATERANGE per pod
+	AdMarkersBoth      = "both"      // both styles, for players that only understand one
+	AdMarkersOff       = "off"       // playlists are served as FFmpeg wrote them
+)
+
+// adPodDateRangeClass identifies the ad pod date ranges of this service
+const adPodDateRangeClass = "com.tv-streamer.ad-pod"
+
+// FFmpeg writes #EXT-X-PROGRAM-DATE-TIME with a numeric zone offset (+0200)
+const ffmpegProgramDateTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// playlistSegment is one media segment of a playlist: the tag lines that
+// precede it and its URI
+type playlistSegment struct {
+	lines    []string
+	start    time.Time
+	duration time.Duration
+}
+
+// DecoratePlaylist inserts ad cue markers into a media playlist written by
+// FFmpeg. Segments are matched to the ad pods of the channel by their program
+// date time. Playlists without segments (the ABR master playlist) and
+// playlists without program date times are returned unchanged.
+func (p *PersistentPlayer) DecoratePlaylist(playlist []byte) []byte {
+	p.mu.RLock()
+	mode := p.adMarkers
+	p.mu.RUnlock()
+
+	if mode == AdMarkersOff {
+		return playlist
+	}
+
+	header, segments, trailer := parseMediaPlaylist(playlist)
+	if len(segments) == 0 || segments[0].start.IsZero() {
+		return playlist
+	}
+
+	last := segments[len(segments)-1]
+	pods, err := getAdPodsInWindow(p.channelID, segments[0].start.Unix(), last.start.Add(last.duration).Unix())
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to load ad pods, serving playlist without cue markers")
+		return playlist
+	}
+	if len(pods) == 0 {
+		return playlist
+	}
+
+	cue := mode == AdMarkersCue || mode == AdMarkersBoth
+	dateRange := mode == AdMarkersDateRange || mode == AdMarkersBoth
+
+	var out bytes.Buffer
+	for _, line := range header {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	var previous *models.AdPod
+	announced := make(map[int64]bool)
+	for i, segment := range segments {
+		pod := podAt(pods, segment.start.Add(segment.duration/2))
+
+		var markers []string
+		if previous != nil && (pod == nil || pod.ID != previous.ID) && cue {
+			markers = append(markers, "#EXT-X-CUE-IN")
+		}
+
+		if pod != nil {
+			podStart := time.Unix(pod.StartedAt, 0)
+			elapsed := segment.start.Sub(podStart).Seconds()
+			if elapsed < 0 {
+				elapsed = 0
+			}
+
+			if dateRange && !announced[pod.ID] {
+				markers = append(markers, adPodDateRange(pod))
+				announced[pod.ID] = true
+			}
+
+			if cue {
+				// A pod that started before the first listed segment is joined
+				// mid-break, players pick it up from the continuation tag
+				joinedMidBreak := i == 0 && podStart.Before(segment.start)
+				if (previous == nil || previous.ID != pod.ID) && !joinedMidBreak {
+					markers = append(markers, fmt.Sprintf("#EXT-X-CUE-OUT:DURATION=%d", pod.PlannedDurationSeconds))
+				} else {
+					markers = append(markers, fmt.Sprintf("#EXT-X-CUE-OUT-CONT:ElapsedTime=%.3f,Duration=%d", elapsed, pod.PlannedDurationSeconds))
+				}
+			}
+		}
+
+		for _, line := range markers {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+		for _, line := range segment.lines {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+
+		previous = pod
+	}
+
+	for _, line := range trailer {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes()
+}
+
+// parseMediaPlaylist splits a media playlist into its header, its segments
+// and the tags after the last segment (#EXT-X-ENDLIST). Segments without a
+// program date time continue from the previous segment.
+func parseMediaPlaylist(playlist []byte) (header []string, segments []playlistSegment, trailer []string) {
+	var current *playlistSegment
+	var next time.Time
+
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		isSegmentTag := strings.HasPrefix(line, "#EXTINF:") ||
+			strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:") ||
+			strings.HasPrefix(line, "#EXT-X-DISCONTINUITY")
+
+		if current == nil {
+			if !isSegmentTag {
+				if len(segments) == 0 {
+					header = append(header, line)
+				} else {
+					// Other tags between segments travel with the next one
+					trailer = append(trailer, line)
+				}
+				continue
+			}
+			current = &playlistSegment{lines: trailer}
+			trailer = nil
+		}
+
+		current.lines = append(current.lines, line)
+
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if comma := strings.IndexByte(value, ','); comma >= 0 {
+				value = value[:comma]
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				current.duration = time.Duration(seconds * float64(time.Second))
+			}
+		case strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:"):
+			current.start = parseProgramDateTime(strings.TrimPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:"))
+		case line != "" && !strings.HasPrefix(line, "#"):
+			// The URI closes the segment
+			if current.start.IsZero() && !next.IsZero() {
+				current.start = next
+			}
+			if !current.start.IsZero() {
+				next = current.start.Add(current.duration)
+			}
+			segments = append(segments, *current)
+			current = nil
+		}
+	}
+
+	// Tags of a segment that was never completed stay where they were
+	if current != nil {
+		trailer = append(trailer, current.lines...)
+	}
+
+	return header, segments, trailer
+}
+
+// parseProgramDateTime parses the FFmpeg layout and falls back to RFC 3339
+func parseProgramDateTime(value string) time.Time {
+	if t, err := time.Parse(ffmpegProgramDateTimeLayout, value); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// podAt returns the pod on air at the given time, or nil
+func podAt(pods []models.AdPod, at time.Time) *models.AdPod {
+	for i := range pods {
+		pod := &pods[i]
+		if at.Before(time.Unix(pod.StartedAt, 0)) {
+			continue
+		}
+		if pod.IsEnded() && !at.Before(time.Unix(pod.EndedAt, 0)) {
+			continue
+		}
+		return pod
+	}
+	return nil
+}
+
+// adPodDateRange returns the #EXT-X-DATERANGE tag of a pod. DURATION is only
+// known once the pod has ended.
+func adPodDateRange(pod *models.AdPod) string {
+	tag := fmt.Sprintf(`#EXT-X-DATERANGE:ID="ad-pod-%d",CLASS="%s",START-DATE="%s",PLANNED-DURATION=%d`,
+		pod.ID, adPodDateRangeClass, time.Unix(pod.StartedAt, 0).UTC().Format("2006-01-02T15:04:05.000Z07:00"), pod.PlannedDurationSeconds)
+	if pod.IsEnded() {
+		tag += fmt.Sprintf(",DURATION=%d", pod.DurationSeconds())
+	}
+	return tag
+}
@@ -0,0 +1,270 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+	"xorm.io/xorm"
+)
+
+// DuplicateFile is a library file of a duplicate group
+type DuplicateFile struct {
+	FileID    string `json:"file_id"`
+	FilePath  string `json:"filepath"`
+	AddedTime int64  `json:"added_time"`
+	IsActive  int    `json:"is_active"`
+	Missing   bool   `json:"missing"`
+}
+
+// DuplicateGroup is a set of library files with the same content. Keep is the
+// file the others are merged into by default: the oldest one on disk.
+type DuplicateGroup struct {
+	Fingerprint string          `json:"fingerprint"`
+	FileSize    int64           `json:"file_size"`
+	VideoLength int64           `json:"video_length"`
+	Keep        string          `json:"keep"`
+	Files       []DuplicateFile `json:"files"`
+}
+
+// MergeResult reports the files merged into a kept file
+type MergeResult struct {
+	Keep         string   `json:"keep"`
+	FilePath     string   `json:"filepath"`
+	Merged       []string `json:"merged"`
+	DeletedFiles []string `json:"deleted_files,omitempty"`
+}
+
+// fileReferenceTables are the tables whose file_id is moved to the kept file
+// when duplicates are merged. play_stats_daily is merged separately, its
+// rows are unique per file.
+var fileReferenceTables = []string{
+	"video_queue",
+	"schedule",
+	"schedule_slot_items",
+	"ad_campaign_creatives",
+	"play_history",
+	"as_run_log",
+}
+
+// mergeFileReferences moves everything that refers to the duplicate to the
+// kept file and removes the duplicate from the library
+func mergeFileReferences(session *xorm.Session, keepID, duplicateID string) error {
+	for _, table := range fileReferenceTables {
+		if _, err := session.Exec(fmt.Sprintf("UPDATE %s SET file_id = ? WHERE file_id = ?", table), keepID, duplicateID); err != nil {
+			return err
+		}
+	}
+
+	// Days both files aired on are added up
+	if _, err := session.Exec(`INSERT INTO play_stats_daily (channel_id, day, file_id, is_ad, plays, skips, interruptions, airtime_seconds)
+		SELECT channel_id, day, ?, is_ad, plays, skips, interruptions, airtime_seconds
+		FROM play_stats_daily WHERE file_id = ?
+		ON CONFLICT (channel_id, day, file_id, is_ad) DO UPDATE SET
+			plays = plays + excluded.plays,
+			skips = skips + excluded.skips,
+			interruptions = interruptions + excluded.interruptions,
+			airtime_seconds = airtime_seconds + excluded.airtime_seconds`, keepID, duplicateID); err != nil {
+		return err
+	}
+	if _, err := session.Exec("DELETE FROM play_stats_daily WHERE file_id = ?", duplicateID); err != nil {
+		return err
+	}
+
+	_, err := session.Exec("DELETE FROM availible_files WHERE file_id = ?", duplicateID)
+	return err
+}
+
+// onDisk returns true if the file exists at its filepath
+func onDisk(file *models.AvailableFiles) bool {
+	if file.IsMissing() {
+		return false
+	}
+	_, err := os.Stat(file.FilePath)
+	return err == nil
+}
+
+// FindDuplicateFiles returns the groups of library files with the same
+// fingerprint, size and duration
+func FindDuplicateFiles() ([]DuplicateGroup, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "FindDuplicateFiles",
+	})
+
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().
+		Where(`fingerprint != '' AND EXISTS (
+			SELECT 1 FROM availible_files other
+			WHERE other.fingerprint = availible_files.fingerprint
+			  AND other.file_size = availible_files.file_size
+			  AND other.video_length = availible_files.video_length
+			  AND other.file_id != availible_files.file_id)`).
+		OrderBy("fingerprint, file_size, video_length, added_time ASC, file_id").
+		Find(&files); err != nil {
+		logger.WithError(err).Error("Failed to query duplicate files")
+		return nil, fmt.Errorf("failed to query duplicate files: %w", err)
+	}
+
+	groups := []DuplicateGroup{}
+	for i := range files {
+		file := &files[i]
+		last := len(groups) - 1
+		if last < 0 || groups[last].Fingerprint != file.Fingerprint ||
+			groups[last].FileSize != file.FileSize || groups[last].VideoLength != file.VideoLength {
+			groups = append(groups, DuplicateGroup{
+				Fingerprint: file.Fingerprint,
+				FileSize:    file.FileSize,
+				VideoLength: file.VideoLength,
+			})
+			last++
+		}
+
+		present := onDisk(file)
+		if groups[last].Keep == "" && present {
+			groups[last].Keep = file.FileID
+		}
+		groups[last].Files = append(groups[last].Files, DuplicateFile{
+			FileID:    file.FileID,
+			FilePath:  file.FilePath,
+			AddedTime: file.AddedTime,
+			IsActive:  file.IsActive,
+			Missing:   !present,
+		})
+	}
+
+	// Without a file on disk the oldest one is kept
+	for i := range groups {
+		if groups[i].Keep == "" {
+			groups[i].Keep = groups[i].Files[0].FileID
+		}
+	}
+
+	logger.WithField("group_count", len(groups)).Debug("✓ Duplicate files found")
+
+	return groups, nil
+}
+
+// MergeDuplicateFiles merges duplicates into the kept file: the queue,
+// schedule, campaigns, history and statistics of the duplicates are moved to
+// it and the duplicates are removed from the library. Without duplicateIDs
+// every other file of the group is merged. If the kept file is missing from
+// disk it takes over the filepath of a merged file that is not. With
+// deleteFiles the merged files are also deleted from disk.
+func MergeDuplicateFiles(keepID string, duplicateIDs []string, deleteFiles bool) (*MergeResult, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "MergeDuplicateFiles",
+		"keep":     keepID,
+	})
+
+	keep, err := GetFileInfoByID(keepID)
+	if err != nil {
+		return nil, err
+	}
+	if keep.Fingerprint == "" {
+		return nil, fmt.Errorf("file %s has no fingerprint yet", keepID)
+	}
+
+	var group []models.AvailableFiles
+	if err := helpers.GetXORM().
+		Where("fingerprint = ? AND file_size = ? AND video_length = ? AND file_id != ?",
+			keep.Fingerprint, keep.FileSize, keep.VideoLength, keep.FileID).
+		OrderBy("added_time ASC, file_id").
+		Find(&group); err != nil {
+		return nil, fmt.Errorf("failed to query duplicate files: %w", err)
+	}
+
+	var duplicates []models.AvailableFiles
+	if len(duplicateIDs) == 0 {
+		duplicates = group
+	} else {
+		byID := make(map[string]models.AvailableFiles, len(group))
+		for _, file := range group {
+			byID[file.FileID] = file
+		}
+		for _, id := range duplicateIDs {
+			file, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("file %s is not a duplicate of %s", id, keepID)
+			}
+			duplicates = append(duplicates, file)
+			delete(byID, id)
+		}
+	}
+
+	if len(duplicates) == 0 {
+		return nil, fmt.Errorf("file %s has no duplicates", keepID)
+	}
+
+	// A kept file that is gone from disk takes over the path of a duplicate
+	keepPath := keep.FilePath
+	if !onDisk(keep) {
+		for i := range duplicates {
+			if onDisk(&duplicates[i]) {
+				keepPath = duplicates[i].FilePath
+				break
+			}
+		}
+	}
+
+	session := helpers.GetXORM().NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	for _, duplicate := range duplicates {
+		if err := mergeFileReferences(session, keep.FileID, duplicate.FileID); err != nil {
+			session.Rollback()
+			logger.WithError(err).WithField("duplicate", duplicate.FileID).Error("Failed to merge duplicate file")
+			return nil, fmt.Errorf("failed to merge file %s: %w", duplicate.FileID, err)
+		}
+	}
+
+	if keepPath != keep.FilePath {
+		if _, err := session.Exec("UPDATE availible_files SET filepath = ?, missing_since = 0 WHERE file_id = ?",
+			keepPath, keep.FileID); err != nil {
+			session.Rollback()
+			return nil, fmt.Errorf("failed to update kept file: %w", err)
+		}
+	}
+
+	if err := session.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	result := &MergeResult{
+		Keep:     keep.FileID,
+		FilePath: keepPath,
+		Merged:   make([]string, 0, len(duplicates)),
+	}
+	for _, duplicate := range duplicates {
+		result.Merged = append(result.Merged, duplicate.FileID)
+		RemoveNormalizedCache(duplicate.FileID)
+
+		if !deleteFiles || duplicate.FilePath == keepPath {
+			continue
+		}
+		if err := os.Remove(duplicate.FilePath); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.WithError(err).WithField("filepath", duplicate.FilePath).Warn("Failed to delete duplicate file")
+			}
+			continue
+		}
+		result.DeletedFiles = append(result.DeletedFiles, duplicate.FilePath)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"filepath":      keepPath,
+		"merged":        len(result.Merged),
+		"deleted_files": len(result.DeletedFiles),
+	}).Info("✓ Duplicate files merged")
+
+	return result, nil
+}
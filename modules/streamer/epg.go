@@ -0,0 +1,339 @@
+package streamer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// xmltvTimeLayout is the timestamp format used by XMLTV
+const xmltvTimeLayout = "20060102150405 -0700"
+
+// maxEPGProjectionSteps bounds the schedule projection loop
+const maxEPGProjectionSteps = 10000
+
+// EPG programme sources
+const (
+	EPGSourceHistory  = "history"
+	EPGSourceCurrent  = "current"
+	EPGSourceQueue    = "queue"
+	EPGSourceSlot     = "slot"
+	EPGSourceSchedule = "schedule"
+)
+
+// EPGProgramme is a single entry of the electronic program guide
+type EPGProgramme struct {
+	FileID      string    `json:"file_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Start       time.Time `json:"start"`
+	Stop        time.Time `json:"stop"`
+	IsAd        bool      `json:"is_ad"`
+	Source      string    `json:"source"`
+}
+
+// BuildEPG assembles the program guide of a channel: past programmes from
+// play_history, the current video, upcoming queue items and the projected
+// programming grid and schedule loop up to the given horizon
+func BuildEPG(channelID int64, now time.Time, horizon time.Duration) ([]EPGProgramme, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "BuildEPG",
+		"channel_id": channelID,
+	})
+
+	logger.Debug("Building EPG...")
+
+	files, err := loadFilesByID()
+	if err != nil {
+		return nil, err
+	}
+
+	programmes := []EPGProgramme{}
+
+	// Past programmes
+	var history []models.PlayHistory
+	err = helpers.GetXORM().
+		Where("finished_at > ? AND channel_id = ?", 0, channelID).
+		OrderBy("started_at ASC").
+		Find(&history)
+	if err != nil {
+		logger.WithError(err).Error("Failed to fetch play history")
+		return nil, fmt.Errorf("failed to fetch play history: %w", err)
+	}
+
+	for _, item := range history {
+		programmes = append(programmes, newEPGProgramme(files, item.FileID,
+			time.Unix(item.StartedAt, 0), time.Unix(item.FinishedAt, 0), item.IsAd == 1, EPGSourceHistory))
+	}
+
+	// Currently playing
+	cursor := now
+	var currentVideo *models.VideoQueue
+	var currentHistory *models.PlayHistory
+	if player := lookupChannelPlayer(channelID); player != nil {
+		currentVideo, currentHistory = player.currentPlayback()
+	}
+	if currentVideo != nil && currentHistory != nil {
+		start := time.Unix(currentHistory.StartedAt, 0)
+		stop := start.Add(fileDuration(files, currentVideo.FileID))
+		if stop.Before(now) {
+			stop = now
+		}
+		programmes = append(programmes, newEPGProgramme(files, currentVideo.FileID, start, stop, currentVideo.IsAd == 1, EPGSourceCurrent))
+		cursor = stop
+	}
+
+	// Upcoming queue items
+	var queue []models.VideoQueue
+	err = helpers.GetXORM().
+		Where("played = ? AND channel_id = ?", 0, channelID).
+		OrderBy("queue_position ASC, id ASC").
+		Find(&queue)
+	if err != nil {
+		logger.WithError(err).Error("Failed to fetch queue")
+		return nil, fmt.Errorf("failed to fetch queue: %w", err)
+	}
+
+	for _, item := range queue {
+		if currentVideo != nil && item.ID == currentVideo.ID {
+			continue
+		}
+		stop := cursor.Add(fileDuration(files, item.FileID))
+		programmes = append(programmes, newEPGProgramme(files, item.FileID, cursor, stop, item.IsAd == 1, EPGSourceQueue))
+		cursor = stop
+	}
+
+	// Projected programming grid and schedule loop
+	projected, err := projectSchedule(channelID, files, cursor, now.Add(horizon))
+	if err != nil {
+		return nil, err
+	}
+	programmes = append(programmes, projected...)
+
+	logger.WithFields(logrus.Fields{
+		"history":   len(history),
+		"queue":     len(queue),
+		"projected": len(projected),
+	}).Info("✓ EPG built successfully")
+
+	return programmes, nil
+}
+
+// projectSchedule simulates autoFillQueueFromLibrary from the given start time
+// without touching the database state of the slots or the schedule
+func projectSchedule(channelID int64, files map[string]*models.AvailableFiles, cursor, until time.Time) ([]EPGProgramme, error) {
+	var slots []models.ScheduleSlot
+	err := helpers.GetXORM().
+		Where("enabled = ? AND channel_id = ?", 1, channelID).
+		OrderBy("priority DESC, start_time DESC").
+		Find(&slots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule slots: %w", err)
+	}
+
+	slotItems := make(map[int64][]models.ScheduleSlotItem)
+	slotDurations := make(map[int64]time.Duration)
+	for _, slot := range slots {
+		items, err := GetScheduleSlotItems(slot.ID)
+		if err != nil {
+			return nil, err
+		}
+		slotItems[slot.ID] = items
+		slotDurations[slot.ID] = slotBlockDuration(slot.ID)
+	}
+
+	loop, err := GetSchedule(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Continue the loop after the current item
+	loopIndex := 0
+	for i, item := range loop {
+		if item.IsCurrent == 1 {
+			loopIndex = i + 1
+			break
+		}
+	}
+
+	// An empty schedule is populated from the library when the queue runs dry
+	if len(loop) == 0 {
+		library, err := libraryFallbackFiles()
+		if err != nil {
+			return nil, err
+		}
+		for i, file := range library {
+			loop = append(loop, models.Schedule{FileID: file.FileID, SchedulePosition: i})
+		}
+	}
+
+	programmes := []EPGProgramme{}
+	emptySteps := 0
+
+	for step := 0; step < maxEPGProjectionSteps && cursor.Before(until); step++ {
+		fileID := ""
+		source := ""
+
+		for i := range slots {
+			slot := &slots[i]
+			items := slotItems[slot.ID]
+			position, occurrence, ok := pickSlotPosition(slot, len(items), cursor, slotDurations[slot.ID])
+			if !ok {
+				continue
+			}
+			slot.LastRunDate = occurrence
+			slot.NextPosition = position + 1
+			// Slot items that don't air are passed over for the loop
+			if fileAirsIn(files, items[position].FileID) {
+				fileID = items[position].FileID
+				source = EPGSourceSlot
+			}
+			break
+		}
+
+		if fileID == "" {
+			if len(loop) == 0 {
+				break
+			}
+			fileID = loop[loopIndex%len(loop)].FileID
+			loopIndex++
+			source = EPGSourceSchedule
+		}
+
+		duration := fileDuration(files, fileID)
+		if duration <= 0 || !fileAirsIn(files, fileID) {
+			// Files without a known length can't be placed on the grid, files
+			// that are not approved or missing don't air
+			emptySteps++
+			if emptySteps > len(loop)+1 {
+				break
+			}
+			continue
+		}
+		emptySteps = 0
+
+		stop := cursor.Add(duration)
+		programmes = append(programmes, newEPGProgramme(files, fileID, cursor, stop, false, source))
+		cursor = stop
+	}
+
+	return programmes, nil
+}
+
+// loadFilesByID loads the library keyed by file_id
+func loadFilesByID() (map[string]*models.AvailableFiles, error) {
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().Find(&files); err != nil {
+		return nil, fmt.Errorf("failed to fetch available files: %w", err)
+	}
+
+	byID := make(map[string]*models.AvailableFiles, len(files))
+	for i := range files {
+		byID[files[i].FileID] = &files[i]
+	}
+	return byID, nil
+}
+
+// fileAirsIn returns true if the file is in the library, approved and on disk
+func fileAirsIn(files map[string]*models.AvailableFiles, fileID string) bool {
+	file, ok := files[fileID]
+	return ok && file.IsApproved() && file.MissingSince == 0
+}
+
+// fileDuration returns the video length of a file as a duration
+func fileDuration(files map[string]*models.AvailableFiles, fileID string) time.Duration {
+	if file, ok := files[fileID]; ok {
+		return time.Duration(file.VideoLength) * time.Second
+	}
+	return 0
+}
+
+// newEPGProgramme creates a programme with title and description taken from the library
+func newEPGProgramme(files map[string]*models.AvailableFiles, fileID string, start, stop time.Time, isAd bool, source string) EPGProgramme {
+	programme := EPGProgramme{
+		FileID: fileID,
+		Title:  fileID,
+		Start:  start,
+		Stop:   stop,
+		IsAd:   isAd,
+		Source: source,
+	}
+
+	if file, ok := files[fileID]; ok {
+		base := filepath.Base(file.FilePath)
+		programme.Title = strings.TrimSuffix(base, filepath.Ext(base))
+		programme.Description = file.Description
+	}
+
+	return programme
+}
+
+// XMLTV document structures
+type xmltvDocument struct {
+	XMLName       xml.Name         `xml:"tv"`
+	GeneratorName string           `xml:"generator-info-name,attr"`
+	Channels      []xmltvChannel   `xml:"channel"`
+	Programmes    []xmltvProgramme `xml:"programme"`
+}
+
+type xmltvChannel struct {
+	ID          string    `xml:"id,attr"`
+	DisplayName xmltvText `xml:"display-name"`
+}
+
+type xmltvProgramme struct {
+	Start    string     `xml:"start,attr"`
+	Stop     string     `xml:"stop,attr"`
+	Channel  string     `xml:"channel,attr"`
+	Title    xmltvText  `xml:"title"`
+	Desc     *xmltvText `xml:"desc,omitempty"`
+	Category *xmltvText `xml:"category,omitempty"`
+}
+
+type xmltvText struct {
+	Lang  string `xml:"lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// RenderXMLTV serializes programmes as an XMLTV document for a single channel
+func RenderXMLTV(programmes []EPGProgramme, channelID, channelName string) ([]byte, error) {
+	doc := xmltvDocument{
+		GeneratorName: "tv_streamer",
+		Channels: []xmltvChannel{
+			{ID: channelID, DisplayName: xmltvText{Value: channelName}},
+		},
+		Programmes: make([]xmltvProgramme, 0, len(programmes)),
+	}
+
+	for _, programme := range programmes {
+		entry := xmltvProgramme{
+			Start:   programme.Start.Format(xmltvTimeLayout),
+			Stop:    programme.Stop.Format(xmltvTimeLayout),
+			Channel: channelID,
+			Title:   xmltvText{Lang: "en", Value: programme.Title},
+		}
+		if programme.Description != "" {
+			entry.Desc = &xmltvText{Lang: "en", Value: programme.Description}
+		}
+		if programme.IsAd {
+			entry.Category = &xmltvText{Lang: "en", Value: "Advertisement"}
+		}
+		doc.Programmes = append(doc.Programmes, entry)
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render XMLTV: %w", err)
+	}
+
+	output := []byte(xml.Header + `<!DOCTYPE tv SYSTEM "xmltv.dtd">` + "\n")
+	return append(output, body...), nil
+}
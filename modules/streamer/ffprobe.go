@@ -37,8 +37,11 @@ type FFProbeStream struct {
 	DisplayAspectRatio string            `json:"display_aspect_ratio,omitempty"`
 	FrameRate          string            `json:"r_frame_rate,omitempty"`
 	AvgFrameRate       string            `json:"avg_frame_rate,omitempty"`
+	FieldOrder         string            `json:"field_order,omitempty"`
 	BitRate            string            `json:"bit_rate,omitempty"`
 	Duration           string            `json:"duration,omitempty"`
+	SampleRate         string            `json:"sample_rate,omitempty"`
+	Channels           int               `json:"channels,omitempty"`
 	Tags               map[string]string `json:"tags,omitempty"`
 }
 
@@ -0,0 +1,217 @@
+package streamer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// fingerprintSampleSize is the size of each sample hashed for a fingerprint
+	fingerprintSampleSize = 64 * 1024
+
+	// fingerprintSamples is the number of samples spread over the file, files
+	// smaller than all samples together are hashed completely
+	fingerprintSamples = 3
+)
+
+// ComputeFingerprint returns the content fingerprint of a file: a SHA-256 of
+// the size and samples from the start, middle and end of the file. Reading a
+// few samples keeps it fast for large videos while a changed header, trailer
+// or length changes the fingerprint.
+func ComputeFingerprint(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := info.Size()
+
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, size)
+
+	if size <= fingerprintSampleSize*fingerprintSamples {
+		if _, err := io.Copy(hash, f); err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		return fmt.Sprintf("%x", hash.Sum(nil)), nil
+	}
+
+	offsets := []int64{0, (size - fingerprintSampleSize) / 2, size - fingerprintSampleSize}
+	buf := make([]byte, fingerprintSampleSize)
+	for _, offset := range offsets {
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		hash.Write(buf)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// fileIdentity is what is known about the content of a file on disk
+// independent of its path
+type fileIdentity struct {
+	size        int64
+	fingerprint string
+	ffprobeData string
+	videoLength int64
+}
+
+// identifyFile hashes and probes a file on disk
+func identifyFile(path string) (*fileIdentity, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("file does not exist: %w", err)
+	}
+
+	fingerprint, err := ComputeFingerprint(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint file: %w", err)
+	}
+
+	ffprobeData, err := GetFFProbeData(path)
+	if err != nil {
+		logs.GetLogger().WithError(err).WithField("filepath", path).Warn("Failed to get ffprobe data, using empty JSON")
+		ffprobeData = "{}"
+	}
+
+	return &fileIdentity{
+		size:        fileInfo.Size(),
+		fingerprint: fingerprint,
+		ffprobeData: ffprobeData,
+		videoLength: ParseDuration(ffprobeData),
+	}, nil
+}
+
+// findMovedFile returns the library file with the same content as the file
+// at path whose own path no longer exists on disk, nil if there is none. A
+// file with the same content that is still in place is a copy, not a move.
+func findMovedFile(path string, identity *fileIdentity) (*models.AvailableFiles, error) {
+	var candidates []models.AvailableFiles
+	if err := helpers.GetXORM().
+		Where("fingerprint = ? AND file_size = ? AND video_length = ? AND filepath != ?",
+			identity.fingerprint, identity.size, identity.videoLength, path).
+		OrderBy("added_time ASC").
+		Find(&candidates); err != nil {
+		return nil, fmt.Errorf("failed to query available files: %w", err)
+	}
+
+	for i := range candidates {
+		if _, err := os.Stat(candidates[i].FilePath); errors.Is(err, os.ErrNotExist) {
+			return &candidates[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// FindMovedFile returns the library file that was moved or renamed to path,
+// nil if the file at path is not a known file at a new location
+func FindMovedFile(path string) (*models.AvailableFiles, error) {
+	normalizedPath, err := NormalizeFilePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize filepath: %w", err)
+	}
+
+	identity, err := identifyFile(normalizedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return findMovedFile(normalizedPath, identity)
+}
+
+// relocateAvailableFile points a library file to the path it was moved to.
+// The file_id stays the same, so the queue, schedule and history keep their
+// references.
+func relocateAvailableFile(file *models.AvailableFiles, newPath string, identity *fileIdentity) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "relocateAvailableFile",
+		"file_id":  file.FileID,
+	})
+
+	oldPath := file.FilePath
+	file.FilePath = newPath
+	file.FileSize = identity.size
+	file.Fingerprint = identity.fingerprint
+	file.FFProbeData = identity.ffprobeData
+	file.VideoLength = identity.videoLength
+	file.MissingSince = 0
+
+	if _, err := helpers.GetXORM().
+		Where("file_id = ?", file.FileID).
+		Cols("filepath", "file_size", "fingerprint", "ffprobe_data", "video_length", "missing_since").
+		Update(file); err != nil {
+		return fmt.Errorf("failed to update available file: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"old_path": oldPath,
+		"new_path": newPath,
+	}).Info("✓ Moved file recognized, filepath updated")
+
+	return nil
+}
+
+// backfillFingerprints hashes the library files added before fingerprints
+// existed, so they are recognized when they are moved later. Files that are
+// not on disk are skipped.
+func backfillFingerprints() {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "backfillFingerprints",
+	})
+
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().
+		Where("fingerprint = '' AND missing_since = 0").
+		Find(&files); err != nil {
+		logger.WithError(err).Error("Failed to query files without fingerprint")
+		return
+	}
+
+	if len(files) == 0 {
+		return
+	}
+
+	logger.WithField("file_count", len(files)).Info("Fingerprinting library files...")
+
+	hashed := 0
+	for i := range files {
+		fingerprint, err := ComputeFingerprint(files[i].FilePath)
+		if err != nil {
+			logger.WithError(err).WithField("filepath", files[i].FilePath).Debug("Failed to fingerprint file")
+			continue
+		}
+
+		files[i].Fingerprint = fingerprint
+		if _, err := helpers.GetXORM().
+			Where("file_id = ?", files[i].FileID).
+			Cols("fingerprint").
+			Update(&files[i]); err != nil {
+			logger.WithError(err).WithField("file_id", files[i].FileID).Warn("Failed to store fingerprint")
+			continue
+		}
+		hashed++
+	}
+
+	logger.WithFields(logrus.Fields{
+		"hashed":  hashed,
+		"skipped": len(files) - hashed,
+	}).Info("✓ Library files fingerprinted")
+}
@@ -0,0 +1,140 @@
+package streamer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"tv_streamer/helpers"
+	"tv_streamer/modules/streamer/models"
+)
+
+// useTestDB points the database at an empty directory for one test
+func useTestDB(t *testing.T) {
+	t.Helper()
+	helpers.CloseXORM()
+	t.Setenv("DB_PATH", t.TempDir())
+	helpers.GetXORM()
+	t.Cleanup(func() { helpers.CloseXORM() })
+}
+
+// writeTestFile writes data to a new file below dir and returns its path
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// patternBytes returns n bytes that differ at every sample offset
+func patternBytes(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestComputeFingerprint(t *testing.T) {
+	large := fingerprintSampleSize*fingerprintSamples + 4096
+	middle := (large - fingerprintSampleSize) / 2
+
+	changed := func(data []byte, offset int) []byte {
+		data = bytes.Clone(data)
+		data[offset]++
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		a, b     []byte
+		wantSame bool
+	}{
+		{name: "same small content", a: []byte("same content"), b: []byte("same content"), wantSame: true},
+		{name: "different small content", a: []byte("content a"), b: []byte("content b")},
+		{name: "empty files", a: []byte{}, b: []byte{}, wantSame: true},
+		{name: "same large content", a: patternBytes(large), b: patternBytes(large), wantSame: true},
+		{name: "large file with changed header", a: patternBytes(large), b: changed(patternBytes(large), 0)},
+		{name: "large file with changed middle", a: patternBytes(large), b: changed(patternBytes(large), middle)},
+		{name: "large file with changed trailer", a: patternBytes(large), b: changed(patternBytes(large), large-1)},
+		// Only the samples are read, a change between them is not seen
+		{name: "large file changed between samples", a: patternBytes(large), b: changed(patternBytes(large), fingerprintSampleSize+1), wantSame: true},
+		{name: "large file with other length", a: patternBytes(large), b: patternBytes(large + 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a, err := ComputeFingerprint(writeTestFile(t, dir, "a.ts", tt.a))
+			if err != nil {
+				t.Fatalf("ComputeFingerprint(a) error = %v", err)
+			}
+			b, err := ComputeFingerprint(writeTestFile(t, dir, "b.ts", tt.b))
+			if err != nil {
+				t.Fatalf("ComputeFingerprint(b) error = %v", err)
+			}
+			if len(a) != 64 {
+				t.Errorf("fingerprint %q is not a hex SHA-256", a)
+			}
+			if (a == b) != tt.wantSame {
+				t.Errorf("fingerprints %s and %s, want same = %v", a, b, tt.wantSame)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := ComputeFingerprint(filepath.Join(t.TempDir(), "missing.ts")); err == nil {
+			t.Error("ComputeFingerprint() of a missing file returned no error")
+		}
+	})
+}
+
+func TestNewFileID(t *testing.T) {
+	useTestDB(t)
+
+	const path = "/videos/show.ts"
+	idOf := func(s string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+	}
+
+	tests := []struct {
+		name  string
+		taken []string
+		want  string
+	}{
+		{name: "free path", want: idOf(path)},
+		{name: "path taken by a moved file", taken: []string{idOf(path)}, want: idOf(path + "#1")},
+		{name: "first fallback taken too", taken: []string{idOf(path), idOf(path + "#1")}, want: idOf(path + "#2")},
+		{name: "only a fallback taken", taken: []string{idOf(path + "#1")}, want: idOf(path)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := helpers.GetXORM()
+			if _, err := db.Exec("DELETE FROM availible_files"); err != nil {
+				t.Fatal(err)
+			}
+			for i, fileID := range tt.taken {
+				file := &models.AvailableFiles{
+					FileID:   fileID,
+					FilePath: fmt.Sprintf("/videos/other%d.ts", i),
+				}
+				if _, err := db.Insert(file); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := newFileID(path)
+			if err != nil {
+				t.Fatalf("newFileID() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("newFileID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
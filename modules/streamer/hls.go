@@ -0,0 +1,215 @@
+package streamer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// StreamModeCopy stream-copies the input into a single HLS rendition (no re-encoding)
+	StreamModeCopy = "copy"
+
+	// StreamModeABR transcodes the input into an adaptive bitrate ladder with a master playlist
+	StreamModeABR = "abr"
+
+	// masterPlaylistName is the playlist clients open, in both modes
+	masterPlaylistName = "stream.m3u8"
+
+	// hlsFlags keeps the playlist live across FFmpeg restarts: append_list
+	// continues the segment numbering and inserts #EXT-X-DISCONTINUITY, and
+	// omit_endlist stops players from treating a restart as the end of stream.
+	// temp_file writes segments under a .tmp name until they are complete, so
+	// a killed FFmpeg never leaves a truncated segment in the playlist.
+	// program_date_time stamps the segments with wall-clock time, which is
+	// what places the ad cue markers.
+	hlsFlags = "delete_segments+append_list+omit_endlist+temp_file+program_date_time"
+)
+
+// Markers written to the media playlists when the service shuts down
+const (
+	// ShutdownMarkerRestart keeps the playlist live and tags it as a planned
+	// restart, players keep polling and continue after a discontinuity
+	ShutdownMarkerRestart = "restart"
+
+	// ShutdownMarkerEndlist ends the stream with #EXT-X-ENDLIST
+	ShutdownMarkerEndlist = "endlist"
+
+	// plannedRestartTag is ignored by players (unknown tags must be skipped)
+	// and dropped by FFmpeg when it rewrites the playlist after the restart
+	plannedRestartTag = "#EXT-X-TV-STREAMER-PLANNED-RESTART"
+)
+
+// buildFFmpegArgs returns the arguments for the persistent FFmpeg process
+// based on the configured stream mode
+func (p *PersistentPlayer) buildFFmpegArgs() []string {
+	if p.streamMode == StreamModeABR && len(p.renditions) > 0 {
+		return p.buildABRArgs()
+	}
+
+	return []string{
+		"-re",          // Read input at native frame rate (real-time streaming)
+		"-f", "mpegts", // Input format (MPEG-TS)
+		"-i", "pipe:0", // Read from stdin
+		"-c:v", "copy", // Copy video codec (no re-encoding)
+		"-c:a", "copy", // Copy audio codec (no re-encoding)
+		"-f", "hls", // HLS output format
+		"-hls_time", fmt.Sprintf("%d", p.hlsSegmentTime), // Segment duration
+		"-hls_list_size", fmt.Sprintf("%d", p.hlsListSize), // Playlist size
+		"-hls_flags", hlsFlags, // Auto-cleanup old segments, resume numbering after restarts
+		"-hls_segment_filename", filepath.Join(p.outputDir, "segment_%03d.ts"),
+		filepath.Join(p.outputDir, masterPlaylistName),
+	}
+}
+
+// buildABRArgs builds a transcoding command that splits the input video into
+// one scaled output per rendition and writes a master playlist referencing
+// one variant playlist per rung
+func (p *PersistentPlayer) buildABRArgs() []string {
+	count := len(p.renditions)
+
+	// [0:v]split=N[v0][v1]...;[v0]scale=w:h[v0out];...
+	var filter strings.Builder
+	filter.WriteString(fmt.Sprintf("[0:v]split=%d", count))
+	for i := range p.renditions {
+		filter.WriteString(fmt.Sprintf("[v%d]", i))
+	}
+	for i, r := range p.renditions {
+		filter.WriteString(fmt.Sprintf(";[v%d]scale=w=%d:h=%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2[v%dout]",
+			i, r.Width, r.Height, r.Width, r.Height, i))
+	}
+
+	args := []string{
+		"-re",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-filter_complex", filter.String(),
+	}
+
+	streamMap := make([]string, 0, count)
+	for i, r := range p.renditions {
+		args = append(args,
+			"-map", fmt.Sprintf("[v%dout]", i),
+			fmt.Sprintf("-c:v:%d", i), "libx264",
+			fmt.Sprintf("-b:v:%d", i), r.VideoBitrate,
+			fmt.Sprintf("-maxrate:v:%d", i), r.VideoBitrate,
+			fmt.Sprintf("-bufsize:v:%d", i), r.VideoBitrate,
+		)
+		// var_stream_map needs an audio stream, prepareVideo gives files
+		// without audio a silent track
+		args = append(args,
+			"-map", "0:a:0",
+			fmt.Sprintf("-c:a:%d", i), "aac",
+			fmt.Sprintf("-b:a:%d", i), r.AudioBitrate,
+		)
+		streamMap = append(streamMap, fmt.Sprintf("v:%d,a:%d,name:%s", i, i, r.Name))
+	}
+
+	args = append(args,
+		"-preset", p.ffmpegPreset,
+		// Align keyframes with segment boundaries so every rung switches cleanly
+		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", p.hlsSegmentTime),
+		"-sc_threshold", "0",
+		"-f", "hls",
+		"-hls_time", fmt.Sprintf("%d", p.hlsSegmentTime),
+		"-hls_list_size", fmt.Sprintf("%d", p.hlsListSize),
+		"-hls_flags", hlsFlags+"+independent_segments",
+		"-hls_segment_filename", filepath.Join(p.outputDir, "stream_%v_%03d.ts"),
+		"-master_pl_name", masterPlaylistName,
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(p.outputDir, "stream_%v.m3u8"),
+	)
+
+	return args
+}
+
+// activeRenditions describes the renditions currently produced by the pipeline
+func (p *PersistentPlayer) activeRenditions() []map[string]interface{} {
+	if p.streamMode != StreamModeABR || len(p.renditions) == 0 {
+		return []map[string]interface{}{
+			{
+				"name":     "source",
+				"playlist": masterPlaylistName,
+				"copy":     true,
+			},
+		}
+	}
+
+	renditions := make([]map[string]interface{}, 0, len(p.renditions))
+	for _, r := range p.renditions {
+		renditions = append(renditions, map[string]interface{}{
+			"name":          r.Name,
+			"resolution":    fmt.Sprintf("%dx%d", r.Width, r.Height),
+			"video_bitrate": r.VideoBitrate,
+			"audio_bitrate": r.AudioBitrate,
+			"playlist":      fmt.Sprintf("stream_%s.m3u8", r.Name),
+			"copy":          false,
+		})
+	}
+	return renditions
+}
+
+// mediaPlaylists returns the paths of the playlists that list segments. In ABR
+// mode stream.m3u8 is the master playlist and only references them.
+func (p *PersistentPlayer) mediaPlaylists() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	playlists := make([]string, 0, len(p.renditions))
+	for _, r := range p.activeRenditions() {
+		playlists = append(playlists, filepath.Join(p.outputDir, r["playlist"].(string)))
+	}
+	return playlists
+}
+
+// finalizePlaylists marks the media playlists after FFmpeg has exited and
+// removes segments FFmpeg didn't finish writing
+func (p *PersistentPlayer) finalizePlaylists(marker string) error {
+	tag := fmt.Sprintf("%s:TIME=%s", plannedRestartTag, time.Now().UTC().Format(time.RFC3339))
+	if marker == ShutdownMarkerEndlist {
+		tag = "#EXT-X-ENDLIST"
+	}
+
+	var failed []string
+	for _, playlist := range p.mediaPlaylists() {
+		data, err := os.ReadFile(playlist)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", filepath.Base(playlist), err))
+			continue
+		}
+
+		content := strings.TrimRight(string(data), "\n")
+		if strings.HasSuffix(content, tag) {
+			continue
+		}
+
+		if err := os.WriteFile(playlist, []byte(content+"\n"+tag+"\n"), 0644); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", filepath.Base(playlist), err))
+			continue
+		}
+
+		p.logger.WithFields(logrus.Fields{
+			"playlist": playlist,
+			"marker":   marker,
+		}).Info("✓ Playlist finalized")
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(p.OutputDir(), "*.tmp"))
+	for _, leftover := range leftovers {
+		if err := os.Remove(leftover); err != nil {
+			p.logger.WithError(err).WithField("path", leftover).Warn("Failed to remove unfinished segment")
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to finalize playlists: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package streamer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultLibrarySettle is used when library.settle_seconds is not set
+	defaultLibrarySettle = 5 * time.Second
+
+	// librarySettleCheckInterval is how often settled paths are processed
+	librarySettleCheckInterval = 1 * time.Second
+)
+
+// libraryWatcher ingests files added to the library folders and marks
+// deleted ones missing. Events are collected per path and processed once the
+// path was quiet for the settle delay, so files still being copied are not
+// probed half-written.
+type libraryWatcher struct {
+	watcher    *fsnotify.Watcher
+	folders    []string
+	extensions []string
+	settle     time.Duration
+	pending    map[string]time.Time // path -> time of its last event
+	stop       chan struct{}
+	done       chan struct{}
+	logger     *logrus.Entry
+}
+
+var (
+	libWatcher   *libraryWatcher
+	libWatcherMu sync.Mutex
+)
+
+// libraryFolders returns the folders to watch: app.video_files_path followed
+// by library.watch_folders, normalized and without duplicates
+func libraryFolders() []string {
+	cfg := helpers.GetConfig()
+
+	folders := []string{}
+	seen := map[string]bool{}
+	for _, folder := range append([]string{cfg.App.VideoFilesPath}, cfg.Library.WatchFolders...) {
+		if folder == "" {
+			continue
+		}
+		normalized, err := NormalizeFilePath(folder)
+		if err != nil || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		folders = append(folders, normalized)
+	}
+	return folders
+}
+
+// startLibraryWatcher starts watching the library folders if library.watch
+// is enabled
+func startLibraryWatcher() {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "startLibraryWatcher",
+	})
+
+	cfg := helpers.GetConfig()
+	if !cfg.Library.Watch {
+		logger.Info("Library watcher is disabled")
+		return
+	}
+
+	libWatcherMu.Lock()
+	defer libWatcherMu.Unlock()
+
+	if libWatcher != nil {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.WithError(err).Error("Failed to create library watcher")
+		return
+	}
+
+	settle := time.Duration(cfg.Library.SettleSeconds) * time.Second
+	if settle <= 0 {
+		settle = defaultLibrarySettle
+	}
+
+	w := &libraryWatcher{
+		watcher:    watcher,
+		folders:    libraryFolders(),
+		extensions: DefaultVideoExtensions,
+		settle:     settle,
+		pending:    make(map[string]time.Time),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		logger:     logs.GetLogger().WithField("module", "library_watcher"),
+	}
+
+	for _, folder := range w.folders {
+		if err := w.watchTree(folder); err != nil {
+			logger.WithError(err).WithField("folder", folder).Warn("Failed to watch library folder")
+		}
+	}
+
+	libWatcher = w
+	go w.run()
+
+	logger.WithFields(logrus.Fields{
+		"folders": w.folders,
+		"settle":  settle.String(),
+	}).Info("✓ Library watcher started")
+}
+
+// stopLibraryWatcher stops the watcher started by startLibraryWatcher
+func stopLibraryWatcher() {
+	libWatcherMu.Lock()
+	w := libWatcher
+	libWatcher = nil
+	libWatcherMu.Unlock()
+
+	if w == nil {
+		return
+	}
+
+	close(w.stop)
+	<-w.done
+	w.logger.Info("✓ Library watcher stopped")
+}
+
+// watchTree adds a watch for a directory and every directory below it,
+// fsnotify does not watch recursively
+func (w *libraryWatcher) watchTree(root string) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			w.logger.WithError(err).WithField("path", path).Warn("Error accessing path")
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != root && isHiddenPath(path) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			w.logger.WithError(err).WithField("path", path).Warn("Failed to watch directory")
+		}
+		return nil
+	})
+}
+
+func (w *libraryWatcher) run() {
+	defer close(w.done)
+	defer w.watcher.Close()
+
+	// Catch up with what changed while the application was not running
+	for _, folder := range w.folders {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		w.syncFolder(folder)
+	}
+
+	ticker := time.NewTicker(librarySettleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.WithError(err).Warn("Library watcher error")
+
+		case now := <-ticker.C:
+			w.processSettled(now)
+		}
+	}
+}
+
+// handleEvent records a path to look at once it settled
+func (w *libraryWatcher) handleEvent(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod || isHiddenPath(event.Name) {
+		return
+	}
+
+	// New directories are watched right away so files copied into them are seen
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := w.watchTree(event.Name); err != nil {
+				w.logger.WithError(err).WithField("path", event.Name).Warn("Failed to watch new directory")
+			}
+		}
+	}
+
+	w.pending[event.Name] = time.Now()
+}
+
+// processSettled handles every path that had no events for the settle delay
+func (w *libraryWatcher) processSettled(now time.Time) {
+	for path, lastEvent := range w.pending {
+		if now.Sub(lastEvent) < w.settle {
+			continue
+		}
+		delete(w.pending, path)
+		w.reconcilePath(path)
+	}
+}
+
+// reconcilePath brings the library in line with what is on disk at path
+func (w *libraryWatcher) reconcilePath(path string) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		w.markMissing(path)
+		return
+	}
+	if err != nil {
+		w.logger.WithError(err).WithField("path", path).Warn("Failed to stat library path")
+		return
+	}
+
+	if info.IsDir() {
+		w.syncFolder(path)
+		return
+	}
+
+	if hasVideoExtension(path, w.extensions) {
+		w.ingest(path)
+	}
+}
+
+// syncFolder ingests the video files below folder that are not in the
+// library yet and marks library files below it that are gone as missing
+func (w *libraryWatcher) syncFolder(folder string) {
+	logger := w.logger.WithField("folder", folder)
+
+	onDisk := map[string]bool{}
+	err := filepath.WalkDir(folder, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			logger.WithError(err).WithField("path", path).Warn("Error accessing path")
+			return nil
+		}
+		if path != folder && isHiddenPath(path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !hasVideoExtension(path, w.extensions) {
+			return nil
+		}
+		onDisk[path] = true
+		return nil
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Failed to walk library folder")
+		return
+	}
+
+	var known []models.AvailableFiles
+	prefix := folder + string(filepath.Separator)
+	if err := helpers.GetXORM().
+		Where("substr(filepath, 1, ?) = ?", len(prefix), prefix).
+		Find(&known); err != nil {
+		logger.WithError(err).Error("Failed to query available files")
+		return
+	}
+
+	for _, file := range known {
+		switch {
+		case onDisk[file.FilePath] && file.IsMissing():
+			w.ingest(file.FilePath)
+		case !onDisk[file.FilePath] && !file.IsMissing():
+			if _, err := os.Stat(file.FilePath); errors.Is(err, os.ErrNotExist) {
+				w.markMissing(file.FilePath)
+			}
+		}
+		delete(onDisk, file.FilePath)
+	}
+
+	// What is left is new
+	for path := range onDisk {
+		w.ingest(path)
+	}
+}
+
+// ingest adds a new file to the library, probes a known one again or
+// recognizes a known file moved to path
+func (w *libraryWatcher) ingest(path string) {
+	ingestLibraryFile(w.logger, path)
+}
+
+// markMissing marks the library files at or below path as missing
+func (w *libraryWatcher) markMissing(path string) {
+	markLibraryFilesMissing(w.logger, path)
+}
+
+// ingestLibraryFile adds a new file to the library, probes a known one again
+// or recognizes a known file moved to path, and broadcasts what happened
+func ingestLibraryFile(logger *logrus.Entry, path string) error {
+	logger = logger.WithField("path", path)
+
+	event := IngestEvent{
+		FilePath:  path,
+		Timestamp: time.Now().Unix(),
+	}
+
+	if file, err := GetFileInfoByPath(path); err == nil {
+		if err := RefreshAvailableFile(file); err != nil {
+			logger.WithError(err).Warn("Failed to refresh library file")
+			event.Type = IngestEventFailed
+			event.FileID = file.FileID
+			event.Error = err.Error()
+			BroadcastIngest(event)
+			return err
+		}
+		event.Type = IngestEventUpdated
+		event.FileID = file.FileID
+		event.FileSize = file.FileSize
+		event.VideoLength = file.VideoLength
+		BroadcastIngest(event)
+		logger.WithField("file_id", file.FileID).Info("✓ Library file updated")
+		return nil
+	}
+
+	file, previousPath, err := addAvailableFile(path)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to ingest library file")
+		event.Type = IngestEventFailed
+		event.Error = err.Error()
+		BroadcastIngest(event)
+		return err
+	}
+
+	event.Type = IngestEventAdded
+	event.FileID = file.FileID
+	event.FilePath = file.FilePath
+	event.FileSize = file.FileSize
+	event.VideoLength = file.VideoLength
+	if previousPath != "" {
+		event.Type = IngestEventMoved
+		event.PreviousPath = previousPath
+		BroadcastIngest(event)
+		logger.WithFields(logrus.Fields{
+			"file_id":       file.FileID,
+			"previous_path": previousPath,
+		}).Info("✓ Library file moved")
+		return nil
+	}
+	BroadcastIngest(event)
+	logger.WithField("file_id", file.FileID).Info("✓ Library file ingested")
+	return nil
+}
+
+// markLibraryFilesMissing marks the library files at or below path as
+// missing and broadcasts each of them
+func markLibraryFilesMissing(logger *logrus.Entry, path string) error {
+	files, err := MarkFilesMissing(path)
+	if err != nil {
+		logger.WithError(err).WithField("path", path).Error("Failed to mark library files missing")
+		return err
+	}
+
+	for _, file := range files {
+		BroadcastIngest(IngestEvent{
+			Type:      IngestEventMissing,
+			FileID:    file.FileID,
+			FilePath:  file.FilePath,
+			Timestamp: file.MissingSince,
+		})
+		logger.WithFields(logrus.Fields{
+			"file_id":  file.FileID,
+			"filepath": file.FilePath,
+		}).Warn("Library file deleted from disk, marked missing")
+	}
+	return nil
+}
+
+// isHiddenPath returns true for dot files and directories, such as the
+// temporary files of copy tools
+func isHiddenPath(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
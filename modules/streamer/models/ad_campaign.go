@@ -0,0 +1,50 @@
+package models
+
+// AdCampaign represents an advertiser's campaign that fills automatic ad breaks
+type AdCampaign struct {
+	ID                int64  `xorm:"pk autoincr 'id'"`
+	ChannelID         int64  `xorm:"not null default 1 'channel_id'"`
+	Name              string `xorm:"varchar(100) not null 'name'"`
+	StartDate         string `xorm:"varchar(10) not null 'start_date'"`
+	EndDate           string `xorm:"varchar(10) not null default '' 'end_date'"`
+	DailyCap          int    `xorm:"not null default 0 'daily_cap'"`
+	MinSpacingMinutes int    `xorm:"not null default 0 'min_spacing_minutes'"`
+	Priority          int    `xorm:"not null default 0 'priority'"`
+	Enabled           int    `xorm:"not null default 1 'enabled'"`
+	CreatedAt         int64  `xorm:"not null 'created_at'"`
+}
+
+// TableName sets the table name for XORM
+func (AdCampaign) TableName() string {
+	return "ad_campaigns"
+}
+
+// IsEnabled returns true if the campaign takes part in ad breaks
+func (a *AdCampaign) IsEnabled() bool {
+	return a.Enabled == 1
+}
+
+// InFlight returns true if the given day (YYYY-MM-DD) is within the flight dates
+func (a *AdCampaign) InFlight(day string) bool {
+	if day < a.StartDate {
+		return false
+	}
+	return a.EndDate == "" || day <= a.EndDate
+}
+
+// HasDailyCap returns true if the campaign limits its impressions per day
+func (a *AdCampaign) HasDailyCap() bool {
+	return a.DailyCap > 0
+}
+
+// AdCampaignCreative represents an ad file of a campaign
+type AdCampaignCreative struct {
+	ID         int64  `xorm:"pk autoincr 'id'"`
+	CampaignID int64  `xorm:"not null 'campaign_id'"`
+	FileID     string `xorm:"varchar(50) not null 'file_id'"`
+}
+
+// TableName sets the table name for XORM
+func (AdCampaignCreative) TableName() string {
+	return "ad_campaign_creatives"
+}
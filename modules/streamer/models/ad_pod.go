@@ -0,0 +1,35 @@
+package models
+
+// AdPod represents an ad break made of one or more consecutive ads
+type AdPod struct {
+	ID                     int64 `xorm:"pk autoincr 'id'"`
+	ChannelID              int64 `xorm:"not null default 1 'channel_id'"`
+	PlannedDurationSeconds int64 `xorm:"not null default 0 'planned_duration_seconds'"`
+	AdCount                int   `xorm:"not null default 0 'ad_count'"`
+	CreatedAt              int64 `xorm:"not null 'created_at'"`
+	StartedAt              int64 `xorm:"not null default 0 'started_at'"`
+	EndedAt                int64 `xorm:"not null default 0 'ended_at'"`
+}
+
+// TableName sets the table name for XORM
+func (AdPod) TableName() string {
+	return "ad_pods"
+}
+
+// IsStarted returns true once the first ad of the pod went on air
+func (a *AdPod) IsStarted() bool {
+	return a.StartedAt > 0
+}
+
+// IsEnded returns true once the last ad of the pod finished
+func (a *AdPod) IsEnded() bool {
+	return a.EndedAt > 0
+}
+
+// DurationSeconds returns how long the pod aired, 0 while it is still airing
+func (a *AdPod) DurationSeconds() int64 {
+	if !a.IsStarted() || !a.IsEnded() {
+		return 0
+	}
+	return a.EndedAt - a.StartedAt
+}
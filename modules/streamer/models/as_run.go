@@ -0,0 +1,36 @@
+package models
+
+// AsRunEntry is a durable record of what went on air. Unlike play_history it
+// is kept for the configured retention period.
+type AsRunEntry struct {
+	ID                     int64  `xorm:"pk autoincr 'id'"`
+	ChannelID              int64  `xorm:"not null default 1 'channel_id'"`
+	HistoryID              int64  `xorm:"not null 'history_id'"`
+	FileID                 string `xorm:"varchar(50) not null 'file_id'"`
+	FilePath               string `xorm:"text not null 'filepath'"`
+	IsAd                   int    `xorm:"not null default 0 'is_ad'"`
+	PodID                  int64  `xorm:"not null default 0 'pod_id'"`
+	CampaignID             int64  `xorm:"not null default 0 'campaign_id'"`
+	PlannedStart           int64  `xorm:"not null 'planned_start'"`
+	PlannedDurationSeconds int64  `xorm:"not null default 0 'planned_duration_seconds'"`
+	ActualStart            int64  `xorm:"not null 'actual_start'"`
+	ActualEnd              int64  `xorm:"not null default 0 'actual_end'"`
+	DurationSeconds        int64  `xorm:"not null default 0 'duration_seconds'"`
+	SkipReason             string `xorm:"varchar(20) not null default '' 'skip_reason'"`
+}
+
+// TableName sets the table name for XORM
+func (AsRunEntry) TableName() string {
+	return "as_run_log"
+}
+
+// IsOnAir returns true while the item is still airing
+func (a *AsRunEntry) IsOnAir() bool {
+	return a.ActualEnd == 0
+}
+
+// StartDeviationSeconds returns how late (positive) or early (negative) the
+// item started compared to the plan
+func (a *AsRunEntry) StartDeviationSeconds() int64 {
+	return a.ActualStart - a.PlannedStart
+}
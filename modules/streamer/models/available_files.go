@@ -1,5 +1,12 @@
 package models
 
+// Review states of a file, only approved files go on air
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
 // AvailableFiles represents files that are available for streaming
 type AvailableFiles struct {
 	FileID      string `xorm:"pk varchar(50) 'file_id'"`
@@ -10,9 +17,31 @@ type AvailableFiles struct {
 	FFProbeData string `xorm:"text null default '{}' 'ffprobe_data'"`
 	IsActive    int    `xorm:"not null default 0 'is_active'"`
 	Description string `xorm:"varchar(500) null default '' 'description'"`
+	// Unix time the file was found deleted from disk, 0 while it exists
+	MissingSince int64 `xorm:"not null default 0 'missing_since'"`
+	// Hash of content samples and size, empty until the file was hashed
+	Fingerprint string `xorm:"varchar(64) not null default '' 'fingerprint'"`
+	// Last review decision, IsActive is 1 while the file is approved
+	ReviewStatus string `xorm:"varchar(20) not null default 'pending' 'review_status'"`
+	ReviewedBy   string `xorm:"varchar(100) not null default '' 'reviewed_by'"`
+	ReviewedAt   int64  `xorm:"not null default 0 'reviewed_at'"`
+	ReviewNote   string `xorm:"varchar(500) not null default '' 'review_note'"`
+	// Result of the upload validation policy, empty for scanned files
+	ValidationStatus string `xorm:"varchar(20) not null default '' 'validation_status'"`
+	ValidationReport string `xorm:"text not null default '' 'validation_report'"`
 }
 
 // TableName returns the table name for AvailableFiles
 func (AvailableFiles) TableName() string {
 	return "availible_files"
 }
+
+// IsMissing returns true if the file was deleted from disk
+func (a *AvailableFiles) IsMissing() bool {
+	return a.MissingSince > 0
+}
+
+// IsApproved returns true if the file may go on air
+func (a *AvailableFiles) IsApproved() bool {
+	return a.IsActive == 1
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// DefaultChannelID is the channel used by the legacy single-channel API
+const DefaultChannelID int64 = 1
+
+// Channel represents a TV channel with its own queue, schedule and FFmpeg pipeline
+type Channel struct {
+	ID        int64  `xorm:"pk autoincr 'id'"`
+	Name      string `xorm:"varchar(100) not null 'name'"`
+	OutputDir string `xorm:"varchar(250) not null default '' 'output_dir'"`
+	Enabled   int    `xorm:"not null default 1 'enabled'"`
+	CreatedAt int64  `xorm:"not null 'created_at'"`
+}
+
+// TableName sets the table name for XORM
+func (Channel) TableName() string {
+	return "channels"
+}
+
+// IsEnabled returns true if the channel should be started with the service
+func (c *Channel) IsEnabled() bool {
+	return c.Enabled == 1
+}
+
+// ResolveOutputDir returns the HLS output directory of the channel. Channels
+// without an explicit directory use the base directory (default channel) or a
+// per-channel subdirectory of it.
+func (c *Channel) ResolveOutputDir(baseDir string) string {
+	if c.OutputDir != "" {
+		return c.OutputDir
+	}
+	if c.ID == DefaultChannelID {
+		return baseDir
+	}
+	return filepath.Join(baseDir, "channels", fmt.Sprintf("%d", c.ID))
+}
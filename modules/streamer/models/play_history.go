@@ -13,6 +13,11 @@ type PlayHistory struct {
 	DurationSeconds int64  `xorm:"null 'duration_seconds'"`
 	IsAd            int    `xorm:"not null default 0 'is_ad'"`
 	SkipRequested   int    `xorm:"not null default 0 'skip_requested'"`
+	ChannelID       int64  `xorm:"not null default 1 'channel_id'"`
+	PositionSeconds int64  `xorm:"not null default 0 'position_seconds'"`
+	Interrupted     int    `xorm:"not null default 0 'interrupted'"`
+	PodID           int64  `xorm:"not null default 0 'pod_id'"`
+	CampaignID      int64  `xorm:"not null default 0 'campaign_id'"`
 }
 
 // TableName returns the table name for PlayHistory
@@ -31,3 +36,9 @@ func (p *PlayHistory) MarkAsSkipped() {
 	p.SkipRequested = 1
 	p.MarkAsFinished()
 }
+
+// MarkAsInterrupted marks the playback as ended before the file finished
+func (p *PlayHistory) MarkAsInterrupted() {
+	p.Interrupted = 1
+	p.MarkAsFinished()
+}
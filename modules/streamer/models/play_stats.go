@@ -0,0 +1,20 @@
+package models
+
+// PlayStatsDaily is the playback summary of a file on one day. It is kept up
+// to date by a trigger on play_history and survives the history cleanup.
+type PlayStatsDaily struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	ChannelID      int64  `xorm:"not null default 1 'channel_id'"`
+	Day            string `xorm:"varchar(10) not null 'day'"`
+	FileID         string `xorm:"varchar(50) not null 'file_id'"`
+	IsAd           int    `xorm:"not null default 0 'is_ad'"`
+	Plays          int64  `xorm:"not null default 0 'plays'"`
+	Skips          int64  `xorm:"not null default 0 'skips'"`
+	Interruptions  int64  `xorm:"not null default 0 'interruptions'"`
+	AirtimeSeconds int64  `xorm:"not null default 0 'airtime_seconds'"`
+}
+
+// TableName sets the table name for XORM
+func (PlayStatsDaily) TableName() string {
+	return "play_stats_daily"
+}
@@ -9,6 +9,7 @@ type Schedule struct {
 	SchedulePosition int    `xorm:"not null 'schedule_position'"`
 	IsCurrent        int    `xorm:"not null default 0 'is_current'"`
 	AddedAt          int64  `xorm:"not null 'added_at'"`
+	ChannelID        int64  `xorm:"not null default 1 'channel_id'"`
 }
 
 // TableName sets the table name for XORM
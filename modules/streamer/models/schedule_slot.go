@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// ScheduleSlot represents a recurring time slot in the programming grid
+// (e.g. "news block at 18:00 every weekday")
+type ScheduleSlot struct {
+	ID           int64  `xorm:"pk autoincr 'id'"`
+	Name         string `xorm:"varchar(100) not null 'name'"`
+	StartTime    string `xorm:"varchar(5) not null 'start_time'"`
+	EndTime      string `xorm:"varchar(5) not null default '' 'end_time'"`
+	Days         string `xorm:"varchar(50) not null 'days'"`
+	Priority     int    `xorm:"not null default 0 'priority'"`
+	Enabled      int    `xorm:"not null default 1 'enabled'"`
+	LastRunDate  string `xorm:"varchar(10) not null default '' 'last_run_date'"`
+	NextPosition int    `xorm:"not null default 0 'next_position'"`
+	CreatedAt    int64  `xorm:"not null 'created_at'"`
+	ChannelID    int64  `xorm:"not null default 1 'channel_id'"`
+}
+
+// TableName sets the table name for XORM
+func (ScheduleSlot) TableName() string {
+	return "schedule_slots"
+}
+
+// IsEnabled returns true if the slot takes part in programming
+func (s *ScheduleSlot) IsEnabled() bool {
+	return s.Enabled == 1
+}
+
+// HasEndTime returns true if the slot has a fixed end time; slots without one
+// air their block playlist once
+func (s *ScheduleSlot) HasEndTime() bool {
+	return s.EndTime != ""
+}
+
+// GetCreatedTime returns the created time as a time.Time
+func (s *ScheduleSlot) GetCreatedTime() time.Time {
+	return time.Unix(s.CreatedAt, 0)
+}
+
+// ScheduleSlotItem represents a video in a slot's block playlist
+type ScheduleSlotItem struct {
+	ID       int64  `xorm:"pk autoincr 'id'"`
+	SlotID   int64  `xorm:"not null 'slot_id'"`
+	FileID   string `xorm:"varchar(50) not null 'file_id'"`
+	Position int    `xorm:"not null 'position'"`
+}
+
+// TableName sets the table name for XORM
+func (ScheduleSlotItem) TableName() string {
+	return "schedule_slot_items"
+}
@@ -0,0 +1,25 @@
+package models
+
+// UploadSession is a chunked upload in progress. Chunks are written to
+// TempPath at their offset, in any order.
+type UploadSession struct {
+	SessionID      string `xorm:"pk varchar(64) 'session_id'"`
+	UserID         int64  `xorm:"not null default 0 'user_id'"`
+	Filename       string `xorm:"varchar(255) not null 'filename'"`
+	TotalSize      int64  `xorm:"not null 'total_size'"`
+	ReceivedSize   int64  `xorm:"not null default 0 'received_size'"`
+	ReceivedRanges string `xorm:"text not null default '[]' 'received_ranges'"`
+	TempPath       string `xorm:"text not null 'temp_path'"`
+	CreatedAt      int64  `xorm:"not null 'created_at'"`
+	UpdatedAt      int64  `xorm:"not null 'updated_at'"`
+}
+
+// TableName sets the table name for XORM
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// IsComplete returns true if every byte of the file was received
+func (u *UploadSession) IsComplete() bool {
+	return u.ReceivedSize == u.TotalSize
+}
@@ -13,6 +13,14 @@ type VideoQueue struct {
 	PlayedAt      int64  `xorm:"null 'played_at'"`
 	QueuePosition int    `xorm:"not null default 0 'queue_position'"`
 	IsAd          int    `xorm:"not null default 0 'is_ad'"`
+	ChannelID     int64  `xorm:"not null default 1 'channel_id'"`
+	PodID         int64  `xorm:"not null default 0 'pod_id'"`      // ad pod the ad belongs to, 0 for content
+	CampaignID    int64  `xorm:"not null default 0 'campaign_id'"` // campaign that booked the ad, 0 if manual
+
+	// Playback position saved while the video airs, used to resume after a restart
+	ResumeByteOffset      int64 `xorm:"not null default 0 'resume_byte_offset'"`
+	ResumePositionSeconds int64 `xorm:"not null default 0 'resume_position_seconds'"`
+	ResumeSavedAt         int64 `xorm:"not null default 0 'resume_saved_at'"`
 }
 
 // TableName returns the table name for VideoQueue
@@ -30,3 +38,15 @@ func (v *VideoQueue) MarkAsPlayed() {
 	v.Played = 1
 	v.PlayedAt = time.Now().Unix()
 }
+
+// HasResumePosition returns true if the video was interrupted while airing
+func (v *VideoQueue) HasResumePosition() bool {
+	return v.ResumeByteOffset > 0
+}
+
+// ClearResumePosition makes the video start from the beginning
+func (v *VideoQueue) ClearResumePosition() {
+	v.ResumeByteOffset = 0
+	v.ResumePositionSeconds = 0
+	v.ResumeSavedAt = 0
+}
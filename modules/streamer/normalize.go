@@ -0,0 +1,483 @@
+package streamer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// NormalizeModeOff feeds files as they are (legacy behaviour)
+	NormalizeModeOff = "off"
+
+	// NormalizeModeAuto remuxes or transcodes only files that don't match the canonical profile
+	NormalizeModeAuto = "auto"
+
+	// NormalizeModeAlways transcodes every file to the canonical profile
+	NormalizeModeAlways = "always"
+)
+
+// Preparation actions decided for a single file
+const (
+	prepareNone      = "none"
+	prepareRemux     = "remux"
+	prepareTranscode = "transcode"
+	prepareSilence   = "silence" // copies the video and adds a silent audio track
+)
+
+// normalizeCacheInterval is how often stale prepared copies are removed
+const normalizeCacheInterval = 1 * time.Hour
+
+var (
+	normalizeCacheStop chan struct{}
+	normalizeCacheOnce sync.Once
+)
+
+// normalizeLocks serializes preparation per file so the same file is never
+// transcoded twice concurrently
+var normalizeLocks sync.Map
+
+// normalizePrefetching holds the IDs of files prepared in the background
+var normalizePrefetching sync.Map
+
+// prefetchNext prepares the video that airs after the current one in the
+// background, so it doesn't have to wait for its preparation when it comes up
+func (p *PersistentPlayer) prefetchNext(current *models.VideoQueue) {
+	if (p.normalize.Mode == "" || p.normalize.Mode == NormalizeModeOff) && p.streamMode != StreamModeABR {
+		return
+	}
+
+	fileID, err := p.nextToAir(current)
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to look up the next video to prepare")
+		return
+	}
+	if fileID == "" {
+		return
+	}
+
+	file, err := GetFileInfoByID(fileID)
+	if err != nil || p.preparationFor(file) == prepareNone {
+		return
+	}
+	p.prepareInBackground(file)
+}
+
+// nextToAir returns the file that airs after the current video: the next
+// queue item or, when the queue runs dry, the programme the queue is filled
+// with once the current video ends. Returns "" if nothing is known to air.
+func (p *PersistentPlayer) nextToAir(current *models.VideoQueue) (string, error) {
+	var next models.VideoQueue
+	has, err := helpers.GetXORM().
+		Where("played = ? AND channel_id = ? AND id != ?", 0, p.channelID, current.ID).
+		OrderBy("queue_position ASC, id ASC").
+		Get(&next)
+	if err != nil {
+		return "", fmt.Errorf("failed to query queue: %w", err)
+	}
+	if has {
+		return next.FileID, nil
+	}
+
+	files, err := loadFilesByID()
+	if err != nil {
+		return "", err
+	}
+
+	// The grid is consulted when the current video ends
+	cursor := time.Now().Add(fileDuration(files, current.FileID))
+	projected, err := projectSchedule(p.channelID, files, cursor, cursor.Add(time.Second))
+	if err != nil || len(projected) == 0 {
+		return "", err
+	}
+	return projected[0].FileID, nil
+}
+
+// prepareInBackground prepares a file unless it is already being prepared
+func (p *PersistentPlayer) prepareInBackground(file *models.AvailableFiles) {
+	if _, running := normalizePrefetching.LoadOrStore(file.FileID, true); running {
+		return
+	}
+
+	go func() {
+		defer normalizePrefetching.Delete(file.FileID)
+		if _, err := p.prepareVideo(file); err != nil {
+			p.logger.WithError(err).WithField("file_id", file.FileID).Warn("Failed to prepare file in the background")
+		}
+	}()
+}
+
+// prepareVideo returns the path of a file that is safe to write into the
+// persistent FFmpeg stdin. Mismatched files are remuxed or transcoded to the
+// canonical MPEG-TS profile once and served from the cache afterwards.
+func (p *PersistentPlayer) prepareVideo(file *models.AvailableFiles) (string, error) {
+	cfg := p.normalize
+
+	logger := p.logger.WithFields(logrus.Fields{
+		"file_id":  file.FileID,
+		"filepath": file.FilePath,
+	})
+
+	action := p.preparationFor(file)
+	if action == prepareNone {
+		logger.Debug("File already matches canonical profile, feeding as-is")
+		return file.FilePath, nil
+	}
+
+	fileInfo, err := os.Stat(file.FilePath)
+	if err != nil {
+		return "", fmt.Errorf("video file does not exist: %w", err)
+	}
+
+	cachedPath := normalizedCachePath(cfg.CacheDir, file.FileID, p.normalizeKey, fileInfo)
+
+	lock, _ := normalizeLocks.LoadOrStore(file.FileID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(cachedPath); err == nil {
+		logger.WithField("cached_path", cachedPath).Debug("✓ Using cached normalized file")
+		return cachedPath, nil
+	}
+
+	if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create normalize cache directory: %w", err)
+	}
+
+	// Drop cache entries left over from older versions of this file
+	RemoveNormalizedCache(file.FileID)
+
+	logger.WithFields(logrus.Fields{
+		"action":      action,
+		"cached_path": cachedPath,
+	}).Info("Preparing file for streaming...")
+
+	tempPath := cachedPath + ".tmp"
+	startTime := time.Now()
+
+	cmd := exec.Command("ffmpeg", p.buildNormalizeArgs(action, file.FilePath, tempPath, probeHasAudio(file.FFProbeData))...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(tempPath)
+		logger.WithError(err).WithField("ffmpeg_output", lastLines(string(output), 5)).Error("Failed to prepare file")
+		return "", fmt.Errorf("failed to %s file: %w", action, err)
+	}
+
+	if err := os.Rename(tempPath, cachedPath); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("failed to store normalized file: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"action":      action,
+		"cached_path": cachedPath,
+		"duration":    time.Since(startTime).String(),
+	}).Info("✓ File prepared for streaming")
+
+	return cachedPath, nil
+}
+
+// preparationFor decides how a file is prepared for the pipeline. Without
+// normalization files are fed as they are, except that the ABR pipeline maps
+// an audio track from every file and needs a silent one for files without.
+func (p *PersistentPlayer) preparationFor(file *models.AvailableFiles) string {
+	if p.normalize.Mode == "" || p.normalize.Mode == NormalizeModeOff {
+		if p.streamMode == StreamModeABR && !probeHasAudio(file.FFProbeData) {
+			return prepareSilence
+		}
+		return prepareNone
+	}
+	return decidePreparation(file.FFProbeData, p.normalize)
+}
+
+// buildNormalizeArgs builds the FFmpeg arguments for a remux or transcode.
+// Files without audio get a silent track.
+func (p *PersistentPlayer) buildNormalizeArgs(action, src, dst string, hasAudio bool) []string {
+	cfg := p.normalize
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-i", src}
+	if hasAudio || action == prepareRemux {
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+	} else {
+		args = append(args, "-f", "lavfi", "-i", silentAudioSource(cfg),
+			"-map", "0:v:0", "-map", "1:a:0", "-shortest")
+	}
+
+	switch action {
+	case prepareRemux:
+		args = append(args, "-c", "copy")
+	case prepareSilence:
+		args = append(args, "-c:v", "copy", "-c:a", "aac", "-b:a", p.audioBitrate)
+	default:
+		videoFilter := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%d,format=yuv420p",
+			cfg.Width, cfg.Height, cfg.Width, cfg.Height, cfg.FrameRate)
+		args = append(args,
+			"-vf", videoFilter,
+			"-c:v", encoderForCodec(cfg.VideoCodec),
+			"-preset", p.ffmpegPreset,
+			"-b:v", p.videoBitrate,
+			"-g", strconv.Itoa(cfg.FrameRate*p.hlsSegmentTime),
+			"-c:a", encoderForCodec(cfg.AudioCodec),
+			"-b:a", p.audioBitrate,
+			"-ar", strconv.Itoa(cfg.AudioSampleRate),
+			"-ac", strconv.Itoa(cfg.AudioChannels),
+		)
+	}
+
+	return append(args, "-f", "mpegts", dst)
+}
+
+// silentAudioSource returns the lavfi source of a silent track in the sample
+// rate and channels of the canonical profile, 48 kHz stereo without one
+func silentAudioSource(cfg helpers.NormalizeConfig) string {
+	sampleRate := cfg.AudioSampleRate
+	if sampleRate <= 0 {
+		sampleRate = 48000
+	}
+
+	layout := "stereo"
+	switch {
+	case cfg.AudioChannels == 1:
+		layout = "mono"
+	case cfg.AudioChannels > 2:
+		layout = fmt.Sprintf("%dc", cfg.AudioChannels)
+	}
+
+	return fmt.Sprintf("anullsrc=r=%d:cl=%s", sampleRate, layout)
+}
+
+// probeHasAudio reports whether stored ffprobe data lists an audio stream.
+// Files without probe data are assumed to have one.
+func probeHasAudio(probeDataJSON string) bool {
+	var probeData FFProbeData
+	if err := json.Unmarshal([]byte(probeDataJSON), &probeData); err != nil || len(probeData.Streams) == 0 {
+		return true
+	}
+	for _, stream := range probeData.Streams {
+		if stream.CodecType == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
+// decidePreparation inspects stored ffprobe data and decides whether a file
+// can be fed as-is, only needs a container remux, or must be transcoded
+func decidePreparation(probeDataJSON string, cfg helpers.NormalizeConfig) string {
+	if cfg.Mode == NormalizeModeAlways {
+		return prepareTranscode
+	}
+
+	var probeData FFProbeData
+	if err := json.Unmarshal([]byte(probeDataJSON), &probeData); err != nil || len(probeData.Streams) == 0 {
+		// Unknown content, transcode to be safe
+		return prepareTranscode
+	}
+
+	var video, audio *FFProbeStream
+	for i := range probeData.Streams {
+		stream := &probeData.Streams[i]
+		if stream.CodecType == "video" && video == nil {
+			video = stream
+		} else if stream.CodecType == "audio" && audio == nil {
+			audio = stream
+		}
+	}
+
+	if video == nil || !codecMatches(video.CodecName, cfg.VideoCodec) ||
+		video.Width != cfg.Width || video.Height != cfg.Height ||
+		parseFrameRate(video.FrameRate) != cfg.FrameRate {
+		return prepareTranscode
+	}
+
+	if audio == nil || !codecMatches(audio.CodecName, cfg.AudioCodec) ||
+		audio.SampleRate != strconv.Itoa(cfg.AudioSampleRate) || audio.Channels != cfg.AudioChannels {
+		return prepareTranscode
+	}
+
+	if !strings.Contains(probeData.Format.FormatName, "mpegts") {
+		return prepareRemux
+	}
+
+	return prepareNone
+}
+
+// RemoveNormalizedCache deletes all cached normalized copies of a file
+func RemoveNormalizedCache(fileID string) {
+	cacheDir := ActiveStreamingProfile().Normalize.CacheDir
+	if cacheDir == "" {
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(cacheDir, fileID+"_*.ts"))
+	if err != nil {
+		return
+	}
+
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil {
+			logs.GetLogger().WithError(err).WithField("path", match).Warn("Failed to remove normalized cache file")
+		}
+	}
+}
+
+// normalizedCachePath builds the cache location for a file; size and mtime are
+// part of the name so a replaced source file is prepared again, the profile
+// key so a new streaming profile doesn't serve copies made for the old one
+func normalizedCachePath(cacheDir, fileID, profileKey string, info os.FileInfo) string {
+	return filepath.Join(cacheDir, fmt.Sprintf("%s_%d_%d_%s.ts", fileID, info.Size(), info.ModTime().Unix(), profileKey))
+}
+
+// normalizeProfileKey hashes the streaming settings that change a prepared copy
+func normalizeProfileKey(profile helpers.StreamingConfig) string {
+	encoded, _ := json.Marshal(struct {
+		Normalize      helpers.NormalizeConfig
+		FFmpegPreset   string
+		VideoBitrate   string
+		AudioBitrate   string
+		HlsSegmentTime int
+	}{profile.Normalize, profile.FFmpegPreset, profile.VideoBitrate, profile.AudioBitrate, profile.HlsSegmentTime})
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:4])
+}
+
+// PruneNormalizedCache removes prepared copies of files that were deleted or
+// went missing, and copies superseded by a changed source file or streaming
+// profile. Copies still being written are left alone.
+func PruneNormalizedCache() (int, error) {
+	profile := ActiveStreamingProfile()
+	if profile.Normalize.CacheDir == "" {
+		return 0, nil
+	}
+	profileKey := normalizeProfileKey(profile)
+
+	matches, err := filepath.Glob(filepath.Join(profile.Normalize.CacheDir, "*_*.ts"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list normalize cache: %w", err)
+	}
+
+	removed := 0
+	for _, match := range matches {
+		fileID, _, _ := strings.Cut(filepath.Base(match), "_")
+
+		var file models.AvailableFiles
+		has, err := helpers.GetXORM().Where("file_id = ?", fileID).Get(&file)
+		if err != nil {
+			return removed, fmt.Errorf("failed to look up cached file: %w", err)
+		}
+
+		stale := !has || file.MissingSince != 0
+		if !stale {
+			info, err := os.Stat(file.FilePath)
+			stale = err != nil || match != normalizedCachePath(profile.Normalize.CacheDir, fileID, profileKey, info)
+		}
+		if !stale {
+			continue
+		}
+
+		if err := os.Remove(match); err != nil {
+			logs.GetLogger().WithError(err).WithField("path", match).Warn("Failed to remove normalized cache file")
+			continue
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// startNormalizeCachePruning prunes the normalize cache now and then every
+// normalizeCacheInterval until stopNormalizeCachePruning is called
+func startNormalizeCachePruning() {
+	normalizeCacheOnce.Do(func() {
+		normalizeCacheStop = make(chan struct{})
+		go runNormalizeCachePruning(normalizeCacheStop)
+	})
+}
+
+// stopNormalizeCachePruning stops the job started by startNormalizeCachePruning
+func stopNormalizeCachePruning() {
+	if normalizeCacheStop != nil {
+		select {
+		case <-normalizeCacheStop:
+		default:
+			close(normalizeCacheStop)
+		}
+	}
+}
+
+func runNormalizeCachePruning(stop <-chan struct{}) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "runNormalizeCachePruning",
+	})
+
+	ticker := time.NewTicker(normalizeCacheInterval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := PruneNormalizedCache()
+		if err != nil {
+			logger.WithError(err).Error("Failed to prune normalize cache")
+		} else if removed > 0 {
+			logger.WithField("removed", removed).Info("✓ Stale normalized files removed")
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// codecMatches compares an ffprobe codec name with a configured codec
+func codecMatches(actual, expected string) bool {
+	return strings.EqualFold(actual, expected)
+}
+
+// encoderForCodec maps a codec name to the FFmpeg encoder used to produce it
+func encoderForCodec(codec string) string {
+	switch strings.ToLower(codec) {
+	case "h264":
+		return "libx264"
+	case "hevc", "h265":
+		return "libx265"
+	default:
+		return codec
+	}
+}
+
+// parseFrameRate converts an ffprobe rational frame rate ("25/1") to whole frames per second
+func parseFrameRate(rate string) int {
+	var num, den float64
+	if n, _ := fmt.Sscanf(rate, "%f/%f", &num, &den); n == 2 && den > 0 {
+		return int(num/den + 0.5)
+	}
+	if n, _ := fmt.Sscanf(rate, "%f", &num); n == 1 {
+		return int(num + 0.5)
+	}
+	return 0
+}
+
+// lastLines returns the last n lines of a multi-line string
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
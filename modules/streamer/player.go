@@ -3,6 +3,7 @@ package streamer
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -22,67 +23,121 @@ import (
 type VideoFeedRequest struct {
 	Video   *models.VideoQueue
 	History *models.PlayHistory
-	Done    chan error // Signal when video feed completes
+	Ctx     context.Context // Cancelled when the video is skipped
+	Offset  int64           // Byte offset to start feeding from when resuming
+	Started chan time.Time  // Signal when the feed starts, after the file was prepared
+	Done    chan error      // Signal when video feed completes
 }
 
-// PersistentPlayer manages a persistent FFmpeg streaming pipeline
-type PersistentPlayer struct {
-	mu             sync.RWMutex
-	cmd            *exec.Cmd
-	stdin          io.WriteCloser
-	currentFile    *models.VideoQueue
-	currentHistory *models.PlayHistory
-	stopChan       chan struct{}
-	skipChan       chan struct{}
-	videoFeedChan  chan *VideoFeedRequest
-	running        bool
-	ffmpegRunning  bool
-	logger         *logrus.Entry
-	outputDir      string
-	videoFilesPath string
-	hlsSegmentTime int
-	hlsListSize    int
-	ffmpegPreset   string
-	videoBitrate   string
-	audioBitrate   string
+// feedProgress tracks how much of the current file FFmpeg has consumed
+type feedProgress struct {
+	FileID         string
+	BytesWritten   int64
+	BytesTotal     int64
+	StartedAt      time.Time
+	LastProgressAt time.Time
 }
 
-var (
-	persistentPlayer     *PersistentPlayer
-	persistentPlayerOnce sync.Once
-)
+// PersistentPlayer manages a persistent FFmpeg streaming pipeline for one channel
+type PersistentPlayer struct {
+	mu                   sync.RWMutex
+	cmd                  *exec.Cmd
+	stdin                io.WriteCloser
+	currentFile          *models.VideoQueue
+	currentHistory       *models.PlayHistory
+	stopChan             chan struct{}
+	skipChan             chan struct{}
+	videoFeedChan        chan *VideoFeedRequest
+	running              bool
+	ffmpegRunning        bool
+	ffmpegDone           chan struct{} // closed when the current FFmpeg process exits
+	ffmpegStartedAt      time.Time
+	ffmpegRestarts       int
+	ffmpegLastExitReason string
+	ffmpegLastExitAt     time.Time
+	ffmpegPlannedExit    bool // FFmpeg was terminated on purpose (skip), restart without backoff
+	logger               *logrus.Entry
+	workers              sync.WaitGroup // feeder, player and supervisor goroutines
+	channel              models.Channel
+	channelID            int64
+	channelName          string
+	outputDir            string
+	videoFilesPath       string
+	hlsSegmentTime       int
+	hlsListSize          int
+	ffmpegPreset         string
+	videoBitrate         string
+	audioBitrate         string
+	feedStallTimeout     time.Duration
+	resumePolicy         string
+	feed                 *feedProgress // nil while no file is being fed
+	adPod                *models.AdPod // pod on air, nil outside ad breaks
+	adMarkers            string
+	streamMode           string
+	renditions           []helpers.StreamRendition
+	normalize            helpers.NormalizeConfig
+	normalizeKey         string // hash of the settings prepared copies depend on
+}
 
-// GetPersistentPlayer returns the singleton PersistentPlayer instance
+// GetPersistentPlayer returns the player of the default channel
 func GetPersistentPlayer() *PersistentPlayer {
-	persistentPlayerOnce.Do(func() {
-		config := helpers.GetConfig()
-
-		logger := logs.GetLogger().WithField("module", "streamer")
-		logger.Info("Initializing Persistent TV Streamer Player...")
-
-		persistentPlayer = &PersistentPlayer{
-			stopChan:       make(chan struct{}),
-			skipChan:       make(chan struct{}),
-			videoFeedChan:  make(chan *VideoFeedRequest, 5),
-			logger:         logger,
-			outputDir:      "./out",
-			videoFilesPath: config.App.VideoFilesPath,
-			hlsSegmentTime: 6,
-			hlsListSize:    10,
-			ffmpegPreset:   "veryfast",
-			videoBitrate:   "2000k",
-			audioBitrate:   "128k",
-		}
-
-		logger.WithFields(logrus.Fields{
-			"output_dir":       persistentPlayer.outputDir,
-			"video_files_path": persistentPlayer.videoFilesPath,
-			"hls_segment_time": persistentPlayer.hlsSegmentTime,
-			"hls_list_size":    persistentPlayer.hlsListSize,
-			"stream_copy":      true,
-		}).Info("Persistent Player configuration loaded")
+	player, err := GetChannelPlayer(models.DefaultChannelID)
+	if err != nil {
+		// The default channel is created by migrations, fall back to an in-memory definition
+		logs.GetLogger().WithError(err).Warn("Failed to load default channel, using built-in definition")
+		player = registerChannelPlayer(&models.Channel{ID: models.DefaultChannelID, Name: "Default", Enabled: 1})
+	}
+	return player
+}
+
+// newPersistentPlayer creates a player for a channel from the active streaming profile
+func newPersistentPlayer(channel *models.Channel) *PersistentPlayer {
+	config := helpers.GetConfig()
+
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"channel_id": channel.ID,
 	})
-	return persistentPlayer
+	logger.WithField("channel", channel.Name).Info("Initializing Persistent TV Streamer Player...")
+
+	player := &PersistentPlayer{
+		stopChan:       make(chan struct{}),
+		skipChan:       make(chan struct{}),
+		videoFeedChan:  make(chan *VideoFeedRequest, 5),
+		logger:         logger,
+		channel:        *channel,
+		channelID:      channel.ID,
+		channelName:    channel.Name,
+		videoFilesPath: config.App.VideoFilesPath,
+	}
+
+	// Adaptive bitrate ladder is opt-in, copy mode stays the default for low-CPU hosts
+	player.applyProfile(ActiveStreamingProfile())
+
+	logger.WithFields(logrus.Fields{
+		"output_dir":       player.outputDir,
+		"video_files_path": player.videoFilesPath,
+		"hls_segment_time": player.hlsSegmentTime,
+		"hls_list_size":    player.hlsListSize,
+		"ffmpeg_preset":    player.ffmpegPreset,
+		"stream_mode":      player.streamMode,
+		"renditions":       len(player.renditions),
+		"normalize_mode":   player.normalize.Mode,
+	}).Info("Persistent Player configuration loaded")
+
+	return player
+}
+
+// ChannelID returns the channel this player streams
+func (p *PersistentPlayer) ChannelID() int64 {
+	return p.channelID
+}
+
+// OutputDir returns the HLS output directory of the player
+func (p *PersistentPlayer) OutputDir() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.outputDir
 }
 
 // Start initializes and starts the persistent streaming pipeline
@@ -94,6 +149,9 @@ func (p *PersistentPlayer) Start() error {
 		return fmt.Errorf("player is already running")
 	}
 	p.running = true
+	// A fresh stop channel lets a stopped channel be started again
+	stop := make(chan struct{})
+	p.stopChan = stop
 	p.mu.Unlock()
 
 	p.logger.Info("Starting Persistent TV Streamer Player...")
@@ -101,44 +159,56 @@ func (p *PersistentPlayer) Start() error {
 	// Create output directory
 	if err := os.MkdirAll(p.outputDir, 0755); err != nil {
 		p.logger.WithError(err).Error("Failed to create output directory")
+		p.setRunning(false)
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 	p.logger.WithField("path", p.outputDir).Info("✓ Output directory created/verified")
 
+	// Close history left open by a crash and decide what to do with the video that was airing
+	p.recoverInterruptedPlayback()
+
 	// Start persistent FFmpeg process
 	if err := p.startPersistentFFmpeg(); err != nil {
 		p.logger.WithError(err).Error("Failed to start persistent FFmpeg")
+		p.setRunning(false)
 		return fmt.Errorf("failed to start persistent FFmpeg: %w", err)
 	}
 
+	p.workers.Add(3)
+
+	// Restart FFmpeg whenever it exits while the player is running
+	go p.superviseFFmpeg(stop)
+
 	// Start video feeder goroutine
-	go p.videoFeeder()
+	go p.videoFeeder(stop)
 
 	// Start video player goroutine (queues videos for feeding)
-	go p.videoPlayer()
+	go p.videoPlayer(stop)
 
 	p.logger.Info("✓ Persistent TV Streamer Player started successfully")
 	return nil
 }
 
+// setRunning updates the running flag
+func (p *PersistentPlayer) setRunning(running bool) {
+	p.mu.Lock()
+	p.running = running
+	p.mu.Unlock()
+}
+
+// IsRunning returns true if the player has been started
+func (p *PersistentPlayer) IsRunning() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.running
+}
+
 // startPersistentFFmpeg starts a single FFmpeg process that reads from stdin
 func (p *PersistentPlayer) startPersistentFFmpeg() error {
 	p.logger.Info("Starting persistent FFmpeg process...")
 
 	// Build FFmpeg command to read from stdin
-	cmd := exec.Command("ffmpeg",
-		"-re",               // Read input at native frame rate (real-time streaming)
-		"-f", "mpegts",      // Input format (MPEG-TS)
-		"-i", "pipe:0",      // Read from stdin
-		"-c:v", "copy",      // Copy video codec (no re-encoding)
-		"-c:a", "copy",      // Copy audio codec (no re-encoding)
-		"-f", "hls",         // HLS output format
-		"-hls_time", fmt.Sprintf("%d", p.hlsSegmentTime), // Segment duration
-		"-hls_list_size", fmt.Sprintf("%d", p.hlsListSize), // Playlist size
-		"-hls_flags", "delete_segments+append_list", // Auto-cleanup old segments
-		"-hls_segment_filename", filepath.Join(p.outputDir, "segment_%03d.ts"),
-		filepath.Join(p.outputDir, "stream.m3u8"),
-	)
+	cmd := exec.Command("ffmpeg", p.buildFFmpegArgs()...)
 
 	p.logger.WithFields(logrus.Fields{
 		"command": cmd.String(),
@@ -165,12 +235,6 @@ func (p *PersistentPlayer) startPersistentFFmpeg() error {
 		return fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
-	p.mu.Lock()
-	p.cmd = cmd
-	p.stdin = stdin
-	p.ffmpegRunning = true
-	p.mu.Unlock()
-
 	// Monitor FFmpeg output in background
 	go p.monitorFFmpegOutput(stdout, stderr)
 
@@ -181,36 +245,63 @@ func (p *PersistentPlayer) startPersistentFFmpeg() error {
 		return fmt.Errorf("failed to start FFmpeg: %w", err)
 	}
 
+	done := make(chan struct{})
+
+	p.mu.Lock()
+	p.cmd = cmd
+	p.stdin = stdin
+	p.ffmpegRunning = true
+	p.ffmpegDone = done
+	p.ffmpegStartedAt = startTime
+	p.mu.Unlock()
+
 	p.logger.WithFields(logrus.Fields{
-		"pid":             cmd.Process.Pid,
-		"output_file":     filepath.Join(p.outputDir, "stream.m3u8"),
-		"startup_time_ms": time.Since(startTime).Milliseconds(),
+		"pid":              cmd.Process.Pid,
+		"output_file":      filepath.Join(p.outputDir, "stream.m3u8"),
+		"hls_segment_time": p.hlsSegmentTime,
+		"hls_list_size":    p.hlsListSize,
+		"stream_mode":      p.streamMode,
+		"startup_time_ms":  time.Since(startTime).Milliseconds(),
 	}).Info("✓ Persistent FFmpeg process started successfully")
 
 	// Monitor FFmpeg process in background
 	go func() {
 		err := cmd.Wait()
+
+		reason := "exited normally"
+		if err != nil {
+			reason = err.Error()
+		}
+
 		p.mu.Lock()
 		p.ffmpegRunning = false
+		if !p.ffmpegPlannedExit {
+			p.ffmpegLastExitReason = reason
+			p.ffmpegLastExitAt = time.Now()
+		}
 		p.mu.Unlock()
 
 		if err != nil {
-			p.logger.WithError(err).Error("⚠ Persistent FFmpeg process exited with error")
+			p.logger.WithError(err).WithField("uptime", time.Since(startTime).String()).Error("⚠ Persistent FFmpeg process exited with error")
 		} else {
-			p.logger.Info("Persistent FFmpeg process exited normally")
+			p.logger.WithField("uptime", time.Since(startTime).String()).Info("Persistent FFmpeg process exited normally")
 		}
+
+		// Wake up the supervisor and Stop()
+		close(done)
 	}()
 
 	return nil
 }
 
 // videoFeeder continuously feeds videos to FFmpeg stdin
-func (p *PersistentPlayer) videoFeeder() {
+func (p *PersistentPlayer) videoFeeder(stop <-chan struct{}) {
+	defer p.workers.Done()
 	p.logger.Info("Starting video feeder goroutine...")
 
 	for {
 		select {
-		case <-p.stopChan:
+		case <-stop:
 			p.logger.Info("Stop signal received in video feeder, exiting")
 			return
 
@@ -219,26 +310,50 @@ func (p *PersistentPlayer) videoFeeder() {
 				continue
 			}
 
-			// Lookup filepath from available_files
-			filepath, err := GetFilePathByID(req.Video.FileID)
+			// Lookup file info from available_files
+			fileInfo, err := GetFileInfoByID(req.Video.FileID)
 			if err != nil {
 				p.logger.WithError(err).WithField("file_id", req.Video.FileID).Error("Failed to lookup filepath for video")
 				req.Done <- fmt.Errorf("failed to lookup filepath: %w", err)
 				continue
 			}
 
+			// Remux or transcode to the canonical profile if needed
+			filepath, err := p.prepareVideo(fileInfo)
+			if err != nil {
+				p.logger.WithError(err).WithField("file_id", req.Video.FileID).Error("Failed to prepare video for streaming")
+				req.Done <- fmt.Errorf("failed to prepare video: %w", err)
+				continue
+			}
+
+			// Skipped while it was being prepared
+			if err := req.Ctx.Err(); err != nil {
+				req.Done <- err
+				continue
+			}
+
+			// Hold the video back while the supervisor restarts FFmpeg
+			if !p.waitForFFmpeg(stop) {
+				req.Done <- errFFmpegUnavailable
+				p.logger.Info("Stop signal received in video feeder, exiting")
+				return
+			}
+
 			p.logger.WithFields(logrus.Fields{
 				"file_id":  req.Video.FileID,
 				"filepath": filepath,
 			}).Info("📤 Feeding video to FFmpeg...")
+			req.Started <- time.Now()
 
 			// Feed the video to FFmpeg
-			err = p.feedVideoToFFmpeg(filepath)
+			err = p.feedVideoToFFmpeg(req.Ctx, req.Video.FileID, filepath, req.Offset)
 
 			// Signal completion
 			req.Done <- err
 
-			if err != nil {
+			if errors.Is(err, context.Canceled) {
+				p.logger.WithField("file_id", req.Video.FileID).Info("✓ Feed cancelled, remaining data discarded")
+			} else if err != nil {
 				p.logger.WithError(err).WithField("file_id", req.Video.FileID).Error("Failed to feed video to FFmpeg")
 			} else {
 				p.logger.WithField("file_id", req.Video.FileID).Info("✓ Video fed to FFmpeg successfully")
@@ -247,8 +362,11 @@ func (p *PersistentPlayer) videoFeeder() {
 	}
 }
 
-// feedVideoToFFmpeg reads a video file and writes it to FFmpeg stdin
-func (p *PersistentPlayer) feedVideoToFFmpeg(videoPath string) error {
+// feedVideoToFFmpeg reads a video file and writes it to FFmpeg stdin. FFmpeg
+// reads at playback speed (-re), so the feed takes as long as the video. It is
+// only aborted when FFmpeg stops consuming data for feedStallTimeout. A
+// non-zero offset resumes an interrupted video.
+func (p *PersistentPlayer) feedVideoToFFmpeg(feedCtx context.Context, fileID string, videoPath string, offset int64) error {
 	// Verify file exists
 	fileInfo, err := os.Stat(videoPath)
 	if err != nil {
@@ -267,35 +385,65 @@ func (p *PersistentPlayer) feedVideoToFFmpeg(videoPath string) error {
 	}
 	defer file.Close()
 
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek to resume offset: %w", err)
+		}
+		p.logger.WithFields(logrus.Fields{
+			"file_id": fileID,
+			"offset":  offset,
+		}).Info("⏩ Resuming feed from saved offset")
+	}
+
 	// Get stdin pipe
-	p.mu.RLock()
+	p.mu.Lock()
 	stdin := p.stdin
 	ffmpegRunning := p.ffmpegRunning
-	p.mu.RUnlock()
+	ffmpegDone := p.ffmpegDone
+	cmd := p.cmd
+	stallTimeout := p.feedStallTimeout
+	if ffmpegRunning && stdin != nil {
+		now := time.Now()
+		p.feed = &feedProgress{
+			FileID:         fileID,
+			BytesWritten:   offset,
+			BytesTotal:     fileInfo.Size(),
+			StartedAt:      now,
+			LastProgressAt: now,
+		}
+	}
+	p.mu.Unlock()
 
 	if !ffmpegRunning || stdin == nil {
-		return fmt.Errorf("FFmpeg is not running or stdin is not available")
+		return errFFmpegUnavailable
 	}
 
+	defer func() {
+		p.mu.Lock()
+		p.feed = nil
+		p.mu.Unlock()
+	}()
+
 	// Create a buffered writer for better performance
 	bufWriter := bufio.NewWriterSize(stdin, 256*1024) // 256KB buffer
 
-	// Copy video data to FFmpeg stdin with timeout protection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	bytesWritten := int64(0)
 	buffer := make([]byte, 32*1024) // 32KB chunks
 
 	// Create a channel to signal write completion
 	writeDone := make(chan error, 1)
 
 	go func() {
+		bytesWritten := offset
+		nextLogPercent := int64(25)
+		if fileInfo.Size() > 0 {
+			nextLogPercent = (offset*100/fileInfo.Size()/25 + 1) * 25
+		}
+
 		for {
-			// Check if context is done
+			// Stop feeding when the video is skipped
 			select {
-			case <-ctx.Done():
-				writeDone <- ctx.Err()
+			case <-feedCtx.Done():
+				writeDone <- feedCtx.Err()
 				return
 			default:
 			}
@@ -316,7 +464,7 @@ func (p *PersistentPlayer) feedVideoToFFmpeg(videoPath string) error {
 				return
 			}
 
-			// Write to FFmpeg stdin
+			// Write to FFmpeg stdin, blocks while FFmpeg isn't reading
 			written, err := bufWriter.Write(buffer[:n])
 			if err != nil {
 				writeDone <- fmt.Errorf("failed to write to FFmpeg stdin: %w", err)
@@ -326,27 +474,92 @@ func (p *PersistentPlayer) feedVideoToFFmpeg(videoPath string) error {
 			bytesWritten += int64(written)
 
 			// Periodic flush to avoid buffer buildup (every 1MB)
-			if bytesWritten%( 1024*1024) == 0 {
+			if bytesWritten%(1024*1024) == 0 {
 				if err := bufWriter.Flush(); err != nil {
 					writeDone <- fmt.Errorf("failed to flush buffer: %w", err)
 					return
 				}
 			}
+
+			p.mu.Lock()
+			if p.feed != nil {
+				p.feed.BytesWritten = bytesWritten
+				p.feed.LastProgressAt = time.Now()
+			}
+			p.mu.Unlock()
+
+			if fileInfo.Size() > 0 && bytesWritten*100/fileInfo.Size() >= nextLogPercent {
+				p.logger.WithFields(logrus.Fields{
+					"file_id":       fileID,
+					"bytes_written": bytesWritten,
+					"bytes_total":   fileInfo.Size(),
+					"percent":       nextLogPercent,
+				}).Info("📤 Feed progress")
+				nextLogPercent += 25
+			}
 		}
 	}()
 
-	// Wait for write to complete or timeout
-	err = <-writeDone
-	if err != nil {
-		return err
+	// Wait for the write to complete while watching for a stalled FFmpeg
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for err == nil {
+		select {
+		case err = <-writeDone:
+			if err == nil {
+				p.logger.WithFields(logrus.Fields{
+					"file_id":    fileID,
+					"bytes":      fileInfo.Size(),
+					"video_path": videoPath,
+				}).Debug("✓ Video data written to FFmpeg stdin")
+				return nil
+			}
+
+		case <-ticker.C:
+			p.mu.RLock()
+			lastProgress := p.feed.LastProgressAt
+			bytesWritten := p.feed.BytesWritten
+			p.mu.RUnlock()
+
+			stalledFor := time.Since(lastProgress)
+			if stalledFor < stallTimeout {
+				continue
+			}
+
+			p.logger.WithFields(logrus.Fields{
+				"file_id":       fileID,
+				"bytes_written": bytesWritten,
+				"bytes_total":   fileInfo.Size(),
+				"stalled_for":   stalledFor.String(),
+			}).Error("⚠ FFmpeg stopped consuming input, killing stalled process")
+
+			// The blocked write only returns once FFmpeg is gone, the
+			// supervisor restarts it
+			if cmd != nil && cmd.Process != nil {
+				if killErr := cmd.Process.Kill(); killErr != nil {
+					p.logger.WithError(killErr).Error("Failed to kill stalled FFmpeg process")
+				}
+			}
+
+			return fmt.Errorf("%w: no data consumed for %s (%d of %d bytes written)",
+				errFeedStalled, stalledFor.Round(time.Second), bytesWritten, fileInfo.Size())
+		}
 	}
 
-	p.logger.WithFields(logrus.Fields{
-		"bytes_written": bytesWritten,
-		"video_path":    videoPath,
-	}).Debug("✓ Video data written to FFmpeg stdin")
+	// Skipped, the unflushed rest of the buffer is dropped on purpose
+	if feedCtx.Err() != nil {
+		return feedCtx.Err()
+	}
 
-	return nil
+	// A broken pipe shortly followed by the process exit is a pipeline
+	// failure, not a problem with the video
+	select {
+	case <-ffmpegDone:
+		return fmt.Errorf("%w: %v", errFFmpegUnavailable, err)
+	case <-time.After(2 * time.Second):
+	}
+	return err
 }
 
 // monitorFFmpegOutput monitors FFmpeg stdout and stderr for logging
@@ -411,12 +624,13 @@ func (p *PersistentPlayer) monitorFFmpegOutput(stdout, stderr io.Reader) {
 }
 
 // videoPlayer continuously plays videos from the queue
-func (p *PersistentPlayer) videoPlayer() {
+func (p *PersistentPlayer) videoPlayer(stop <-chan struct{}) {
+	defer p.workers.Done()
 	p.logger.Info("Starting video player loop...")
 
 	for {
 		select {
-		case <-p.stopChan:
+		case <-stop:
 			p.logger.Info("Stop signal received, exiting video player")
 			return
 		default:
@@ -439,8 +653,47 @@ func (p *PersistentPlayer) videoPlayer() {
 				continue
 			}
 
+			// An automatic ad break goes in front of the next programme
+			if p.maybeScheduleAdBreak(video) {
+				continue
+			}
+
 			// Play the video
-			if err := p.playVideo(video); err != nil {
+			err = p.playVideo(video, stop)
+			if video.PodID != 0 && !errors.Is(err, errFFmpegUnavailable) && !errors.Is(err, errPlayerStopped) {
+				p.finishAdPod(video.PodID)
+			}
+			if errors.Is(err, errVideoSkipped) {
+				continue
+			}
+
+			if errors.Is(err, errFFmpegUnavailable) || errors.Is(err, errPlayerStopped) {
+				if errors.Is(err, errPlayerStopped) {
+					p.logger.WithField("file_id", video.FileID).Info("Player stopped during playback, video will be resumed on start")
+				} else {
+					p.logger.WithError(err).WithField("file_id", video.FileID).Warn("Pipeline went down during playback, video will be retried")
+				}
+
+				// Close the interrupted history record, the video stays unplayed
+				p.mu.Lock()
+				history := p.currentHistory
+				p.currentFile = nil
+				p.currentHistory = nil
+				p.mu.Unlock()
+
+				if history != nil {
+					history.MarkAsInterrupted()
+					if _, err := helpers.GetXORM().ID(history.ID).Cols("finished_at", "duration_seconds", "interrupted").Update(history); err != nil {
+						p.logger.WithError(err).Error("Failed to update play history")
+					}
+					closeAsRun(history, SkipReasonInterrupted)
+				}
+
+				p.waitForFFmpeg(stop)
+				continue
+			}
+
+			if err != nil {
 				p.logger.WithError(err).WithFields(logrus.Fields{
 					"file_id": video.FileID,
 					"is_ad":   video.IsAd == 1,
@@ -452,6 +705,12 @@ func (p *PersistentPlayer) videoPlayer() {
 					if _, err := helpers.GetXORM().ID(p.currentHistory.ID).Cols("finished_at", "duration_seconds", "skip_requested").Update(p.currentHistory); err != nil {
 						p.logger.WithError(err).Error("Failed to update play history")
 					}
+
+					skipReason := SkipReasonFailed
+					if errors.Is(err, errFeedStalled) {
+						skipReason = SkipReasonStalled
+					}
+					closeAsRun(p.currentHistory, skipReason)
 				}
 
 				// CRITICAL: Mark video as played even on failure to prevent infinite retry loop
@@ -475,7 +734,7 @@ func (p *PersistentPlayer) getNextVideo() (*models.VideoQueue, error) {
 
 	var video models.VideoQueue
 	has, err := helpers.GetXORM().
-		Where("played = ?", 0).
+		Where("played = ? AND channel_id = ?", 0, p.channelID).
 		OrderBy("queue_position ASC, id ASC").
 		Get(&video)
 
@@ -498,30 +757,54 @@ func (p *PersistentPlayer) getNextVideo() (*models.VideoQueue, error) {
 	return &video, nil
 }
 
-// autoFillQueueFromLibrary automatically fills the queue from schedule (endless loop)
+// autoFillQueueFromLibrary automatically fills the queue from the programming grid,
+// falling back to the schedule (endless loop) when no time slot is airing
 func (p *PersistentPlayer) autoFillQueueFromLibrary() error {
 	p.logger.Info("Auto-filling queue from schedule...")
 
+	// Time slots take precedence over the endless loop. A slot item that is
+	// not approved is passed over, the slot continues with its next item on
+	// the next fill.
+	slotItem, slot, err := GetNextFromSlots(p.channelID, time.Now())
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to consult programming grid, falling back to schedule loop")
+	} else if slotItem != nil {
+		approved, err := isFileApproved(slotItem.FileID)
+		if err != nil {
+			return err
+		}
+		if approved {
+			return p.enqueueScheduledFile(slotItem.FileID, logrus.Fields{
+				"slot_id":       slot.ID,
+				"slot":          slot.Name,
+				"slot_position": slotItem.Position,
+			})
+		}
+		p.logger.WithFields(logrus.Fields{
+			"slot_id": slot.ID,
+			"file_id": slotItem.FileID,
+		}).Warn("Slot item is not approved, falling back to schedule loop")
+	}
+
 	// Get next video from schedule (handles endless loop automatically)
-	scheduleItem, err := GetNextFromSchedule()
+	scheduleItem, err := p.nextApprovedFromSchedule()
 	if err != nil {
-		return fmt.Errorf("failed to get next from schedule: %w", err)
+		return err
 	}
 
 	if scheduleItem == nil {
 		// Schedule is empty, attempt to auto-populate from available_files
 		p.logger.Info("Schedule is empty, attempting to populate from available files...")
 
-		var availableFiles []models.AvailableFiles
-		err := helpers.GetXORM().Find(&availableFiles)
+		availableFiles, err := libraryFallbackFiles()
 		if err != nil {
 			p.logger.WithError(err).Error("Failed to query available files")
-			return fmt.Errorf("failed to query available files: %w", err)
+			return err
 		}
 
 		if len(availableFiles) == 0 {
-			p.logger.Warn("No videos in available files - please add videos to the library first")
-			return fmt.Errorf("no videos available in library")
+			p.logger.Warn("No approved videos in available files - please add or approve videos first")
+			return fmt.Errorf("no approved videos available in library")
 		}
 
 		p.logger.WithField("file_count", len(availableFiles)).Info("Found available files, populating schedule...")
@@ -542,6 +825,7 @@ func (p *PersistentPlayer) autoFillQueueFromLibrary() error {
 				SchedulePosition: i,
 				IsCurrent:        0,
 				AddedAt:          time.Now().Unix(),
+				ChannelID:        p.channelID,
 			}
 
 			if _, err := helpers.GetXORM().Insert(scheduleItem); err != nil {
@@ -559,7 +843,7 @@ func (p *PersistentPlayer) autoFillQueueFromLibrary() error {
 		p.logger.WithField("added_count", successCount).Info("✓ Schedule auto-populated from available files")
 
 		// Retry getting from schedule
-		scheduleItem, err = GetNextFromSchedule()
+		scheduleItem, err = p.nextApprovedFromSchedule()
 		if err != nil {
 			return fmt.Errorf("failed to get next from schedule after population: %w", err)
 		}
@@ -570,23 +854,76 @@ func (p *PersistentPlayer) autoFillQueueFromLibrary() error {
 		}
 	}
 
+	return p.enqueueScheduledFile(scheduleItem.FileID, logrus.Fields{
+		"schedule_position": scheduleItem.SchedulePosition,
+	})
+}
+
+// libraryFallbackFiles returns the files an empty schedule is populated with.
+// Only approved files that are on disk go on air.
+func libraryFallbackFiles() ([]models.AvailableFiles, error) {
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().Where("is_active = 1 AND missing_since = 0").Find(&files); err != nil {
+		return nil, fmt.Errorf("failed to query available files: %w", err)
+	}
+	return files, nil
+}
+
+// nextApprovedFromSchedule advances the schedule loop to the next approved
+// file. Returns nil if the schedule is empty and an error if none of its
+// files is approved.
+func (p *PersistentPlayer) nextApprovedFromSchedule() (*models.Schedule, error) {
+	count, err := helpers.GetXORM().Where("channel_id = ?", p.channelID).Count(&models.Schedule{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count schedule: %w", err)
+	}
+
+	// One round through the loop visits every item
+	for i := int64(0); i <= count; i++ {
+		scheduleItem, err := GetNextFromSchedule(p.channelID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next from schedule: %w", err)
+		}
+		if scheduleItem == nil {
+			return nil, nil
+		}
+
+		approved, err := isFileApproved(scheduleItem.FileID)
+		if err != nil {
+			return nil, err
+		}
+		if approved {
+			return scheduleItem, nil
+		}
+
+		p.logger.WithFields(logrus.Fields{
+			"file_id":           scheduleItem.FileID,
+			"schedule_position": scheduleItem.SchedulePosition,
+		}).Debug("Schedule item is not approved, skipping")
+	}
+
+	p.logger.Warn("No approved videos in schedule - please approve videos first")
+	return nil, fmt.Errorf("no approved videos in schedule")
+}
+
+// enqueueScheduledFile appends a file picked by the schedule or programming grid to the queue
+func (p *PersistentPlayer) enqueueScheduledFile(fileID string, fields logrus.Fields) error {
 	// Lookup filepath for the scheduled item
-	filepath, err := GetFilePathByID(scheduleItem.FileID)
+	filepath, err := GetFilePathByID(fileID)
 	if err != nil {
-		p.logger.WithError(err).WithField("file_id", scheduleItem.FileID).Error("Failed to lookup filepath for scheduled item")
+		p.logger.WithError(err).WithField("file_id", fileID).Error("Failed to lookup filepath for scheduled item")
 		return fmt.Errorf("failed to lookup filepath: %w", err)
 	}
 
-	p.logger.WithFields(logrus.Fields{
-		"file_id":           scheduleItem.FileID,
-		"filepath":          filepath,
-		"schedule_position": scheduleItem.SchedulePosition,
+	p.logger.WithFields(fields).WithFields(logrus.Fields{
+		"file_id":  fileID,
+		"filepath": filepath,
 	}).Info("Retrieved next video from schedule")
 
 	// Check if file still exists on disk
 	if _, err := os.Stat(filepath); err != nil {
 		p.logger.WithFields(logrus.Fields{
-			"file_id":  scheduleItem.FileID,
+			"file_id":  fileID,
 			"filepath": filepath,
 		}).Error("Scheduled file no longer exists on disk")
 		return fmt.Errorf("scheduled file does not exist: %w", err)
@@ -594,7 +931,7 @@ func (p *PersistentPlayer) autoFillQueueFromLibrary() error {
 
 	// Get current max queue position
 	var maxPosition int
-	_, err = helpers.GetXORM().SQL("SELECT COALESCE(MAX(queue_position), 0) FROM video_queue").Get(&maxPosition)
+	_, err = helpers.GetXORM().SQL("SELECT COALESCE(MAX(queue_position), 0) FROM video_queue WHERE channel_id = ?", p.channelID).Get(&maxPosition)
 	if err != nil {
 		return fmt.Errorf("failed to get max queue position: %w", err)
 	}
@@ -602,31 +939,31 @@ func (p *PersistentPlayer) autoFillQueueFromLibrary() error {
 	// Add scheduled video to queue
 	nextPosition := maxPosition + 1
 	queueItem := &models.VideoQueue{
-		FileID:        scheduleItem.FileID,
+		FileID:        fileID,
 		AddedAt:       time.Now().Unix(),
 		Played:        0,
 		QueuePosition: nextPosition,
 		IsAd:          0,
+		ChannelID:     p.channelID,
 	}
 
 	if _, err := helpers.GetXORM().Insert(queueItem); err != nil {
-		p.logger.WithError(err).WithField("file_id", scheduleItem.FileID).Error("Failed to add scheduled video to queue")
+		p.logger.WithError(err).WithField("file_id", fileID).Error("Failed to add scheduled video to queue")
 		return fmt.Errorf("failed to add to queue: %w", err)
 	}
 
-	p.logger.WithFields(logrus.Fields{
-		"queue_id":          queueItem.ID,
-		"file_id":           scheduleItem.FileID,
-		"filepath":          filepath,
-		"queue_position":    nextPosition,
-		"schedule_position": scheduleItem.SchedulePosition,
+	p.logger.WithFields(fields).WithFields(logrus.Fields{
+		"queue_id":       queueItem.ID,
+		"file_id":        fileID,
+		"filepath":       filepath,
+		"queue_position": nextPosition,
 	}).Info("✓ Queue auto-filled with next scheduled video")
 
 	return nil
 }
 
 // playVideo feeds a single video to the persistent FFmpeg process
-func (p *PersistentPlayer) playVideo(video *models.VideoQueue) error {
+func (p *PersistentPlayer) playVideo(video *models.VideoQueue, stop <-chan struct{}) error {
 	startTime := time.Now()
 
 	p.logger.WithFields(logrus.Fields{
@@ -636,17 +973,33 @@ func (p *PersistentPlayer) playVideo(video *models.VideoQueue) error {
 		"timestamp": startTime.Format(time.RFC3339),
 	}).Info("▶ Starting to play video")
 
+	// An interrupted video continues near where it stopped
+	basePosition := video.ResumePositionSeconds
+	var offset int64
+	if video.HasResumePosition() {
+		offset = resumeOffset(video.ResumeByteOffset)
+		p.logger.WithFields(logrus.Fields{
+			"file_id":          video.FileID,
+			"byte_offset":      offset,
+			"position_seconds": basePosition,
+		}).Info("▶ Resuming interrupted video")
+	}
+
 	// Create play history record
 	history := &models.PlayHistory{
-		FileID:    video.FileID,
-		StartedAt: startTime.Unix(),
-		IsAd:      video.IsAd,
+		FileID:     video.FileID,
+		StartedAt:  startTime.Unix(),
+		IsAd:       video.IsAd,
+		ChannelID:  p.channelID,
+		PodID:      video.PodID,
+		CampaignID: video.CampaignID,
 	}
 
 	if _, err := helpers.GetXORM().Insert(history); err != nil {
 		p.logger.WithError(err).Error("Failed to create play history record")
 	} else {
 		p.logger.WithField("history_id", history.ID).Debug("✓ Play history record created")
+		p.openAsRun(video, history)
 	}
 
 	p.mu.Lock()
@@ -655,12 +1008,18 @@ func (p *PersistentPlayer) playVideo(video *models.VideoQueue) error {
 	p.mu.Unlock()
 
 	// Broadcast currently_playing event to WebSocket clients
-	BroadcastCurrentlyPlaying(video.FileID, startTime.Unix())
+	BroadcastCurrentlyPlaying(p.channelID, video.FileID, startTime.Unix())
+
+	// Create feed request, cancelling its context stops the feed on skip
+	feedCtx, cancelFeed := context.WithCancel(context.Background())
+	defer cancelFeed()
 
-	// Create feed request
 	feedReq := &VideoFeedRequest{
 		Video:   video,
 		History: history,
+		Ctx:     feedCtx,
+		Offset:  offset,
+		Started: make(chan time.Time, 1),
 		Done:    make(chan error, 1),
 	}
 
@@ -672,76 +1031,128 @@ func (p *PersistentPlayer) playVideo(video *models.VideoQueue) error {
 		return fmt.Errorf("timeout sending video to feeder channel")
 	}
 
-	// Wait for video to complete or skip signal
-	select {
-	case <-p.skipChan:
-		p.logger.WithField("file_id", video.FileID).Warn("⏭ Skip requested, stopping current video")
+	// Get the next video ready while this one airs
+	p.prefetchNext(video)
 
-		// Mark as skipped in history
-		history.MarkAsSkipped()
-		if _, err := helpers.GetXORM().ID(history.ID).Cols("finished_at", "duration_seconds", "skip_requested").Update(history); err != nil {
-			p.logger.WithError(err).Error("Failed to update play history")
-		}
+	// Wait for video to complete or skip signal, saving the position meanwhile
+	saveTicker := time.NewTicker(resumeSaveInterval)
+	defer saveTicker.Stop()
 
-		// Mark video as played
-		video.MarkAsPlayed()
-		if _, err := helpers.GetXORM().ID(video.ID).Cols("played", "played_at").Update(video); err != nil {
-			p.logger.WithError(err).Error("Failed to mark video as played")
-		}
+	for {
+		select {
+		case <-stop:
+			p.savePlaybackPosition(video, history, basePosition)
+			return errPlayerStopped
 
-		p.mu.Lock()
-		p.currentFile = nil
-		p.currentHistory = nil
-		p.mu.Unlock()
+		case <-saveTicker.C:
+			p.savePlaybackPosition(video, history, basePosition)
 
-		return fmt.Errorf("video skipped by user")
+		case feedStart := <-feedReq.Started:
+			// The file had to be prepared first, it goes on air now
+			if feedStart.Unix() > history.StartedAt+1 {
+				p.movePlaybackStart(video, history, feedStart)
+			}
 
-	case err := <-feedReq.Done:
-		duration := time.Since(startTime)
+			// The first ad of a pod opens the ad break
+			if video.PodID != 0 {
+				p.beginAdPod(video.PodID, feedStart)
+			}
 
-		if err != nil {
-			// Video feed failed
-			p.logger.WithError(err).WithFields(logrus.Fields{
-				"file_id":  video.FileID,
-				"duration": duration.String(),
-			}).Error("Failed to feed video to FFmpeg")
-			return fmt.Errorf("video feed error: %w", err)
-		}
+		case <-p.skipChan:
+			p.logger.WithField("file_id", video.FileID).Warn("⏭ Skip requested, stopping current video")
 
-		// Video completed successfully
-		p.logger.WithFields(logrus.Fields{
-			"file_id":          video.FileID,
-			"duration":         duration.String(),
-			"duration_seconds": duration.Seconds(),
-		}).Info("✓ Video playback completed successfully")
-
-		// Update play history
-		history.MarkAsFinished()
-		if _, err := helpers.GetXORM().ID(history.ID).Cols("finished_at", "duration_seconds").Update(history); err != nil {
-			p.logger.WithError(err).Error("Failed to update play history")
-		} else {
-			p.logger.WithField("history_id", history.ID).Debug("✓ Play history updated")
-		}
+			// Stop writing the rest of the file and drop what FFmpeg already buffered
+			cancelFeed()
+			if err := p.cutFFmpeg(stop); err != nil {
+				p.logger.WithError(err).Warn("Failed to cut FFmpeg input, skipped content may still air briefly")
+			}
 
-		// Mark video as played
-		video.MarkAsPlayed()
-		if _, err := helpers.GetXORM().ID(video.ID).Cols("played", "played_at").Update(video); err != nil {
-			p.logger.WithError(err).Error("Failed to mark video as played")
-		} else {
-			p.logger.WithField("video_id", video.ID).Debug("✓ Video marked as played in queue")
-		}
+			// Mark as skipped in history
+			history.MarkAsSkipped()
+			if _, err := helpers.GetXORM().ID(history.ID).Cols("finished_at", "duration_seconds", "skip_requested").Update(history); err != nil {
+				p.logger.WithError(err).Error("Failed to update play history")
+			}
+			closeAsRun(history, SkipReasonUser)
 
-		p.mu.Lock()
-		p.currentFile = nil
-		p.currentHistory = nil
-		p.mu.Unlock()
+			// Mark video as played
+			video.MarkAsPlayed()
+			if _, err := helpers.GetXORM().ID(video.ID).Cols("played", "played_at").Update(video); err != nil {
+				p.logger.WithError(err).Error("Failed to mark video as played")
+			}
 
-		// Small delay before next video for smooth transition
-		p.logger.Debug("Waiting 1 second before loading next video")
-		time.Sleep(1 * time.Second)
+			p.mu.Lock()
+			p.currentFile = nil
+			p.currentHistory = nil
+			p.mu.Unlock()
 
-		return nil
+			return errVideoSkipped
+
+		case err := <-feedReq.Done:
+			duration := time.Since(startTime)
+
+			if err != nil {
+				// Video feed failed
+				p.logger.WithError(err).WithFields(logrus.Fields{
+					"file_id":  video.FileID,
+					"duration": duration.String(),
+				}).Error("Failed to feed video to FFmpeg")
+				return fmt.Errorf("video feed error: %w", err)
+			}
+
+			// Video completed successfully
+			p.logger.WithFields(logrus.Fields{
+				"file_id":          video.FileID,
+				"duration":         duration.String(),
+				"duration_seconds": duration.Seconds(),
+			}).Info("✓ Video playback completed successfully")
+
+			// Update play history
+			history.MarkAsFinished()
+			if _, err := helpers.GetXORM().ID(history.ID).Cols("finished_at", "duration_seconds").Update(history); err != nil {
+				p.logger.WithError(err).Error("Failed to update play history")
+			} else {
+				p.logger.WithField("history_id", history.ID).Debug("✓ Play history updated")
+			}
+			closeAsRun(history, "")
+
+			// Mark video as played
+			video.MarkAsPlayed()
+			if _, err := helpers.GetXORM().ID(video.ID).Cols("played", "played_at").Update(video); err != nil {
+				p.logger.WithError(err).Error("Failed to mark video as played")
+			} else {
+				p.logger.WithField("video_id", video.ID).Debug("✓ Video marked as played in queue")
+			}
+
+			p.mu.Lock()
+			p.currentFile = nil
+			p.currentHistory = nil
+			p.mu.Unlock()
+
+			// Small delay before next video for smooth transition
+			p.logger.Debug("Waiting 1 second before loading next video")
+			time.Sleep(1 * time.Second)
+
+			return nil
+		}
+	}
+}
+
+// movePlaybackStart moves the start of a playback to when its feed started, so
+// the history, the as-run log and the resume position don't count the time
+// the file was being prepared
+func (p *PersistentPlayer) movePlaybackStart(video *models.VideoQueue, history *models.PlayHistory, startedAt time.Time) {
+	p.mu.Lock()
+	history.StartedAt = startedAt.Unix()
+	p.mu.Unlock()
+
+	if history.ID != 0 {
+		if _, err := helpers.GetXORM().ID(history.ID).Cols("started_at").Update(history); err != nil {
+			p.logger.WithError(err).WithField("history_id", history.ID).Error("Failed to update play history start")
+		}
+		moveAsRunStart(history)
 	}
+
+	BroadcastCurrentlyPlaying(p.channelID, video.FileID, history.StartedAt)
 }
 
 // Skip skips the currently playing video
@@ -780,10 +1191,11 @@ func (p *PersistentPlayer) Stop() error {
 		return fmt.Errorf("player is not running")
 	}
 	p.running = false
+	stop := p.stopChan
 	p.mu.Unlock()
 
 	// Send stop signal to goroutines
-	close(p.stopChan)
+	close(stop)
 
 	// Close stdin to signal FFmpeg to finish
 	p.mu.RLock()
@@ -796,12 +1208,11 @@ func (p *PersistentPlayer) Stop() error {
 	}
 
 	// Wait for FFmpeg to exit gracefully (with timeout)
-	if cmd != nil && cmd.Process != nil {
-		done := make(chan error, 1)
-		go func() {
-			done <- cmd.Wait()
-		}()
+	p.mu.RLock()
+	done := p.ffmpegDone
+	p.mu.RUnlock()
 
+	if cmd != nil && cmd.Process != nil && done != nil {
 		select {
 		case <-done:
 			p.logger.Info("✓ FFmpeg process exited gracefully")
@@ -813,18 +1224,54 @@ func (p *PersistentPlayer) Stop() error {
 		}
 	}
 
+	// Wait for the goroutines so a following Start() doesn't race with them
+	workersDone := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(workersDone)
+	}()
+
+	select {
+	case <-workersDone:
+	case <-time.After(10 * time.Second):
+		p.logger.Warn("Player goroutines did not exit in time")
+	}
+
 	p.logger.Info("✓ Persistent TV Streamer Player stopped successfully")
 	return nil
 }
 
+// currentPlayback returns the video being fed right now and its history record
+func (p *PersistentPlayer) currentPlayback() (*models.VideoQueue, *models.PlayHistory) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.currentFile, p.currentHistory
+}
+
 // GetStatus returns the current player status
 func (p *PersistentPlayer) GetStatus() map[string]interface{} {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	status := map[string]interface{}{
-		"running":        p.running,
-		"ffmpeg_running": p.ffmpegRunning,
+		"channel_id":                 p.channelID,
+		"channel_name":               p.channelName,
+		"output_dir":                 p.outputDir,
+		"running":                    p.running,
+		"ffmpeg_running":             p.ffmpegRunning,
+		"stream_mode":                p.streamMode,
+		"renditions":                 p.activeRenditions(),
+		"ffmpeg_restarts":            p.ffmpegRestarts,
+		"feed_stall_timeout_seconds": int64(p.feedStallTimeout.Seconds()),
+	}
+
+	if p.ffmpegRunning {
+		status["ffmpeg_started_at"] = p.ffmpegStartedAt.Format(time.RFC3339)
+	}
+
+	if !p.ffmpegLastExitAt.IsZero() {
+		status["ffmpeg_last_exit_reason"] = p.ffmpegLastExitReason
+		status["ffmpeg_last_exit_at"] = p.ffmpegLastExitAt.Format(time.RFC3339)
 	}
 
 	if p.currentFile != nil {
@@ -834,6 +1281,30 @@ func (p *PersistentPlayer) GetStatus() map[string]interface{} {
 		}
 	}
 
+	if p.adPod != nil {
+		status["current_ad_pod"] = map[string]interface{}{
+			"pod_id":                   p.adPod.ID,
+			"ad_count":                 p.adPod.AdCount,
+			"planned_duration_seconds": p.adPod.PlannedDurationSeconds,
+			"started_at":               time.Unix(p.adPod.StartedAt, 0).Format(time.RFC3339),
+		}
+	}
+
+	if p.feed != nil {
+		feed := map[string]interface{}{
+			"file_id":             p.feed.FileID,
+			"bytes_written":       p.feed.BytesWritten,
+			"bytes_total":         p.feed.BytesTotal,
+			"started_at":          p.feed.StartedAt.Format(time.RFC3339),
+			"last_progress_at":    p.feed.LastProgressAt.Format(time.RFC3339),
+			"stalled_for_seconds": int64(time.Since(p.feed.LastProgressAt).Seconds()),
+		}
+		if p.feed.BytesTotal > 0 {
+			feed["percent"] = float64(p.feed.BytesWritten) * 100 / float64(p.feed.BytesTotal)
+		}
+		status["feed_progress"] = feed
+	}
+
 	if p.currentHistory != nil {
 		status["playback_started_at"] = time.Unix(p.currentHistory.StartedAt, 0).Format(time.RFC3339)
 		status["playback_duration_seconds"] = time.Now().Unix() - p.currentHistory.StartedAt
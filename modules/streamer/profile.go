@@ -0,0 +1,377 @@
+package streamer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for streaming settings missing from config.yaml
+const (
+	defaultOutputDir      = "./out"
+	defaultHlsSegmentTime = 6
+	defaultHlsListSize    = 10
+	defaultFFmpegPreset   = "veryfast"
+	defaultVideoBitrate   = "2000k"
+	defaultAudioBitrate   = "128k"
+	defaultFeedStall      = 30
+	defaultNormalizeCache = "./cache/normalized"
+)
+
+// x264 presets accepted by ffmpeg_preset
+var ffmpegPresets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+
+// bitratePattern matches FFmpeg bitrates like "2000k", "5M" or "128000"
+var bitratePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?[kKmM]?$`)
+
+// renditionNamePattern restricts rendition names to characters safe in file names
+var renditionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var (
+	activeProfile   *helpers.StreamingConfig
+	activeProfileMu sync.RWMutex
+
+	// applyProfileMu serializes pipeline restarts caused by profile changes
+	applyProfileMu sync.Mutex
+)
+
+// ActiveStreamingProfile returns a copy of the streaming profile in use. Until a
+// profile is applied at runtime this is the streaming section of config.yaml.
+func ActiveStreamingProfile() helpers.StreamingConfig {
+	activeProfileMu.RLock()
+	profile := activeProfile
+	activeProfileMu.RUnlock()
+
+	if profile == nil {
+		activeProfileMu.Lock()
+		if activeProfile == nil {
+			loaded := copyStreamingProfile(helpers.GetConfig().Streaming)
+			// Invalid values are reported by ValidateStreamingProfile at startup
+			ValidateStreamingProfile(&loaded)
+			activeProfile = &loaded
+		}
+		profile = activeProfile
+		activeProfileMu.Unlock()
+	}
+
+	return copyStreamingProfile(*profile)
+}
+
+// ValidateStreamingProfile fills in defaults for empty settings and checks the
+// remaining values. All problems are reported in a single error.
+func ValidateStreamingProfile(profile *helpers.StreamingConfig) error {
+	if profile.OutputDir == "" {
+		profile.OutputDir = defaultOutputDir
+	}
+	if profile.HlsSegmentTime == 0 {
+		profile.HlsSegmentTime = defaultHlsSegmentTime
+	}
+	if profile.HlsListSize == 0 {
+		profile.HlsListSize = defaultHlsListSize
+	}
+	if profile.FFmpegPreset == "" {
+		profile.FFmpegPreset = defaultFFmpegPreset
+	}
+	if profile.VideoBitrate == "" {
+		profile.VideoBitrate = defaultVideoBitrate
+	}
+	if profile.AudioBitrate == "" {
+		profile.AudioBitrate = defaultAudioBitrate
+	}
+	if profile.FeedStallTimeout == 0 {
+		profile.FeedStallTimeout = defaultFeedStall
+	}
+	if profile.ResumePolicy == "" {
+		profile.ResumePolicy = ResumePolicyResume
+	}
+	if profile.ShutdownMarker == "" {
+		profile.ShutdownMarker = ShutdownMarkerRestart
+	}
+	if profile.AdMarkers == "" {
+		profile.AdMarkers = AdMarkersBoth
+	}
+	if profile.Mode == "" {
+		profile.Mode = StreamModeCopy
+	}
+	if profile.Normalize.Mode == "" {
+		profile.Normalize.Mode = NormalizeModeOff
+	}
+	if profile.Normalize.CacheDir == "" {
+		// Also used in abr mode for files that need a silent audio track
+		profile.Normalize.CacheDir = defaultNormalizeCache
+	}
+
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if profile.HlsSegmentTime < 1 || profile.HlsSegmentTime > 60 {
+		addProblem("streaming.hls_segment_time must be between 1 and 60 seconds (got %d)", profile.HlsSegmentTime)
+	}
+	if profile.HlsListSize < 1 || profile.HlsListSize > 1000 {
+		addProblem("streaming.hls_list_size must be between 1 and 1000 (got %d)", profile.HlsListSize)
+	}
+	if !containsString(ffmpegPresets, profile.FFmpegPreset) {
+		addProblem("streaming.ffmpeg_preset must be one of %s (got '%s')", strings.Join(ffmpegPresets, ", "), profile.FFmpegPreset)
+	}
+	if !bitratePattern.MatchString(profile.VideoBitrate) {
+		addProblem("streaming.video_bitrate must look like 2000k or 5M (got '%s')", profile.VideoBitrate)
+	}
+	if !bitratePattern.MatchString(profile.AudioBitrate) {
+		addProblem("streaming.audio_bitrate must look like 128k (got '%s')", profile.AudioBitrate)
+	}
+	if profile.FeedStallTimeout < 5 || profile.FeedStallTimeout > 600 {
+		addProblem("streaming.feed_stall_timeout must be between 5 and 600 seconds (got %d)", profile.FeedStallTimeout)
+	}
+
+	switch profile.ResumePolicy {
+	case ResumePolicyResume, ResumePolicyRestart, ResumePolicySkip:
+	default:
+		addProblem("streaming.resume_policy must be '%s', '%s' or '%s' (got '%s')",
+			ResumePolicyResume, ResumePolicyRestart, ResumePolicySkip, profile.ResumePolicy)
+	}
+
+	switch profile.ShutdownMarker {
+	case ShutdownMarkerRestart, ShutdownMarkerEndlist:
+	default:
+		addProblem("streaming.shutdown_marker must be '%s' or '%s' (got '%s')",
+			ShutdownMarkerRestart, ShutdownMarkerEndlist, profile.ShutdownMarker)
+	}
+
+	switch profile.AdMarkers {
+	case AdMarkersCue, AdMarkersDateRange, AdMarkersBoth, AdMarkersOff:
+	default:
+		addProblem("streaming.ad_markers must be '%s', '%s', '%s' or '%s' (got '%s')",
+			AdMarkersCue, AdMarkersDateRange, AdMarkersBoth, AdMarkersOff, profile.AdMarkers)
+	}
+
+	switch profile.Mode {
+	case StreamModeCopy:
+	case StreamModeABR:
+		if len(profile.Renditions) == 0 {
+			addProblem("streaming.renditions must contain at least one rendition in abr mode")
+		}
+	default:
+		addProblem("streaming.mode must be '%s' or '%s' (got '%s')", StreamModeCopy, StreamModeABR, profile.Mode)
+	}
+
+	names := make(map[string]bool)
+	for i, r := range profile.Renditions {
+		prefix := fmt.Sprintf("streaming.renditions[%d]", i)
+		if !renditionNamePattern.MatchString(r.Name) {
+			addProblem("%s.name must only contain letters, digits, '-' and '_' (got '%s')", prefix, r.Name)
+		} else if names[r.Name] {
+			addProblem("%s.name '%s' is used more than once", prefix, r.Name)
+		}
+		names[r.Name] = true
+		if r.Width <= 0 || r.Height <= 0 || r.Width%2 != 0 || r.Height%2 != 0 {
+			addProblem("%s resolution must be positive and even (got %dx%d)", prefix, r.Width, r.Height)
+		}
+		if !bitratePattern.MatchString(r.VideoBitrate) {
+			addProblem("%s.video_bitrate must look like 2800k (got '%s')", prefix, r.VideoBitrate)
+		}
+		if !bitratePattern.MatchString(r.AudioBitrate) {
+			addProblem("%s.audio_bitrate must look like 128k (got '%s')", prefix, r.AudioBitrate)
+		}
+	}
+
+	normalize := profile.Normalize
+	switch normalize.Mode {
+	case NormalizeModeOff:
+	case NormalizeModeAuto, NormalizeModeAlways:
+		if normalize.VideoCodec == "" || normalize.AudioCodec == "" {
+			addProblem("streaming.normalize.video_codec and audio_codec are required when normalization is enabled")
+		}
+		if normalize.Width <= 0 || normalize.Height <= 0 || normalize.Width%2 != 0 || normalize.Height%2 != 0 {
+			addProblem("streaming.normalize resolution must be positive and even (got %dx%d)", normalize.Width, normalize.Height)
+		}
+		if normalize.FrameRate < 1 || normalize.FrameRate > 120 {
+			addProblem("streaming.normalize.frame_rate must be between 1 and 120 (got %d)", normalize.FrameRate)
+		}
+		if normalize.AudioSampleRate <= 0 {
+			addProblem("streaming.normalize.audio_sample_rate must be positive (got %d)", normalize.AudioSampleRate)
+		}
+		if normalize.AudioChannels < 1 || normalize.AudioChannels > 8 {
+			addProblem("streaming.normalize.audio_channels must be between 1 and 8 (got %d)", normalize.AudioChannels)
+		}
+	default:
+		addProblem("streaming.normalize.mode must be '%s', '%s' or '%s' (got '%s')",
+			NormalizeModeOff, NormalizeModeAuto, NormalizeModeAlways, normalize.Mode)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid streaming configuration: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// ApplyStreamingProfile validates a new streaming profile, makes it the active
+// one and restarts the pipeline of every running channel with it. Stopped
+// channels pick it up when they are started.
+func ApplyStreamingProfile(profile helpers.StreamingConfig) (map[int64]error, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "ApplyStreamingProfile",
+	})
+
+	logger.Info("Applying streaming profile...")
+
+	profile = copyStreamingProfile(profile)
+	if err := ValidateStreamingProfile(&profile); err != nil {
+		logger.WithError(err).Warn("Rejected streaming profile")
+		return nil, err
+	}
+
+	applyProfileMu.Lock()
+	defer applyProfileMu.Unlock()
+
+	activeProfileMu.Lock()
+	activeProfile = &profile
+	activeProfileMu.Unlock()
+
+	playersMu.Lock()
+	channelPlayers := make([]*PersistentPlayer, 0, len(players))
+	for _, player := range players {
+		channelPlayers = append(channelPlayers, player)
+	}
+	playersMu.Unlock()
+
+	results := make(map[int64]error, len(channelPlayers))
+	for _, player := range channelPlayers {
+		results[player.ChannelID()] = player.restartWithProfile(profile)
+	}
+
+	// Copies prepared for the old profile are no longer used
+	go func() {
+		if _, err := PruneNormalizedCache(); err != nil {
+			logger.WithError(err).Warn("Failed to prune normalize cache")
+		}
+	}()
+
+	logger.WithFields(logrus.Fields{
+		"mode":             profile.Mode,
+		"hls_segment_time": profile.HlsSegmentTime,
+		"hls_list_size":    profile.HlsListSize,
+		"channels":         len(channelPlayers),
+	}).Info("✓ Streaming profile applied")
+
+	return results, nil
+}
+
+// applyProfile copies the streaming settings of a profile into the player.
+// Must only be called while the player is stopped.
+func (p *PersistentPlayer) applyProfile(profile helpers.StreamingConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.outputDir = p.channel.ResolveOutputDir(profile.OutputDir)
+	p.hlsSegmentTime = profile.HlsSegmentTime
+	p.hlsListSize = profile.HlsListSize
+	p.ffmpegPreset = profile.FFmpegPreset
+	p.videoBitrate = profile.VideoBitrate
+	p.audioBitrate = profile.AudioBitrate
+	p.feedStallTimeout = time.Duration(profile.FeedStallTimeout) * time.Second
+	p.resumePolicy = profile.ResumePolicy
+	p.adMarkers = profile.AdMarkers
+	p.normalize = profile.Normalize
+	p.normalizeKey = normalizeProfileKey(profile)
+	p.streamMode = profile.Mode
+	p.renditions = nil
+	if profile.Mode == StreamModeABR {
+		p.renditions = profile.Renditions
+	}
+}
+
+// restartWithProfile stops the pipeline, switches to the new profile and starts
+// it again. The HLS output is cleared when the playlist layout changes so
+// clients don't keep loading renditions that no longer exist.
+func (p *PersistentPlayer) restartWithProfile(profile helpers.StreamingConfig) error {
+	wasRunning := p.IsRunning()
+	if wasRunning {
+		if err := p.Stop(); err != nil {
+			return fmt.Errorf("failed to stop pipeline: %w", err)
+		}
+	}
+
+	var renditions []helpers.StreamRendition
+	if profile.Mode == StreamModeABR {
+		renditions = profile.Renditions
+	}
+
+	p.mu.RLock()
+	layoutChanged := p.streamMode != profile.Mode || !sameRenditions(p.renditions, renditions)
+	oldOutputDir := p.outputDir
+	p.mu.RUnlock()
+
+	p.applyProfile(profile)
+
+	if layoutChanged {
+		clearHLSOutput(oldOutputDir, p.logger)
+	}
+
+	if !wasRunning {
+		return nil
+	}
+
+	if err := p.Start(); err != nil {
+		return fmt.Errorf("failed to restart pipeline: %w", err)
+	}
+
+	p.logger.Info("✓ Pipeline restarted with new streaming profile")
+	return nil
+}
+
+// clearHLSOutput removes playlists and segments from an output directory
+func clearHLSOutput(dir string, logger *logrus.Entry) {
+	for _, pattern := range []string{"*.m3u8", "*.ts"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if err := os.Remove(match); err != nil {
+				logger.WithError(err).WithField("path", match).Warn("Failed to remove HLS file")
+			}
+		}
+	}
+	logger.WithField("output_dir", dir).Info("✓ HLS output cleared for new playlist layout")
+}
+
+// sameRenditions compares two rendition ladders
+func sameRenditions(a, b []helpers.StreamRendition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyStreamingProfile returns a copy that doesn't share the renditions slice
+func copyStreamingProfile(profile helpers.StreamingConfig) helpers.StreamingConfig {
+	profile.Renditions = append([]helpers.StreamRendition(nil), profile.Renditions...)
+	return profile
+}
+
+// containsString reports whether a list contains a value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -3,7 +3,6 @@ package streamer
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"time"
 	"tv_streamer/helpers"
 	"tv_streamer/helpers/logs"
@@ -12,11 +11,12 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// AddToQueue adds a video file to the streaming queue
-func AddToQueue(filepath string, isAd bool) error {
+// AddToQueue adds a video file to the streaming queue of a channel
+func AddToQueue(channelID int64, filepath string, isAd bool) error {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "AddToQueue",
+		"module":     "streamer",
+		"function":   "AddToQueue",
+		"channel_id": channelID,
 	})
 
 	logger.WithFields(logrus.Fields{
@@ -63,9 +63,14 @@ func AddToQueue(filepath string, isAd bool) error {
 	fileID := availFile.FileID
 	logger.WithField("file_id", fileID).Debug("File found in available files")
 
+	if err := checkFileApproved(&availFile); err != nil {
+		logger.WithField("review_status", availFile.ReviewStatus).Warn("File is not approved")
+		return err
+	}
+
 	// Get next queue position
 	var maxPosition int
-	_, err = helpers.GetXORM().SQL("SELECT COALESCE(MAX(queue_position), 0) FROM video_queue").Get(&maxPosition)
+	_, err = helpers.GetXORM().SQL("SELECT COALESCE(MAX(queue_position), 0) FROM video_queue WHERE channel_id = ?", channelID).Get(&maxPosition)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get max queue position")
 		return fmt.Errorf("failed to get queue position: %w", err)
@@ -81,6 +86,7 @@ func AddToQueue(filepath string, isAd bool) error {
 		Played:        0,
 		QueuePosition: nextPosition,
 		IsAd:          0,
+		ChannelID:     channelID,
 	}
 
 	if isAd {
@@ -103,17 +109,19 @@ func AddToQueue(filepath string, isAd bool) error {
 	return nil
 }
 
-// GetQueue returns all items in the queue
-func GetQueue() ([]models.VideoQueue, error) {
+// GetQueue returns all items in the queue of a channel
+func GetQueue(channelID int64) ([]models.VideoQueue, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "GetQueue",
+		"module":     "streamer",
+		"function":   "GetQueue",
+		"channel_id": channelID,
 	})
 
 	logger.Debug("Fetching queue...")
 
 	var queue []models.VideoQueue
 	err := helpers.GetXORM().
+		Where("channel_id = ?", channelID).
 		OrderBy("queue_position ASC, id ASC").
 		Find(&queue)
 
@@ -131,18 +139,20 @@ func GetQueue() ([]models.VideoQueue, error) {
 	return queue, nil
 }
 
-// GetPlayHistory returns recent play history
-func GetPlayHistory(limit int) ([]models.PlayHistory, error) {
+// GetPlayHistory returns recent play history of a channel
+func GetPlayHistory(channelID int64, limit int) ([]models.PlayHistory, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "GetPlayHistory",
-		"limit":    limit,
+		"module":     "streamer",
+		"function":   "GetPlayHistory",
+		"channel_id": channelID,
+		"limit":      limit,
 	})
 
 	logger.Debug("Fetching play history...")
 
 	var history []models.PlayHistory
 	err := helpers.GetXORM().
+		Where("channel_id = ?", channelID).
 		OrderBy("started_at DESC").
 		Limit(limit).
 		Find(&history)
@@ -157,16 +167,17 @@ func GetPlayHistory(limit int) ([]models.PlayHistory, error) {
 	return history, nil
 }
 
-// ClearPlayedFromQueue removes all played items from the queue
-func ClearPlayedFromQueue() (int64, error) {
+// ClearPlayedFromQueue removes all played items from the queue of a channel
+func ClearPlayedFromQueue(channelID int64) (int64, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "ClearPlayedFromQueue",
+		"module":     "streamer",
+		"function":   "ClearPlayedFromQueue",
+		"channel_id": channelID,
 	})
 
 	logger.Info("Clearing played items from queue...")
 
-	result, err := helpers.GetXORM().Where("played = ?", 1).Delete(&models.VideoQueue{})
+	result, err := helpers.GetXORM().Where("played = ? AND channel_id = ?", 1, channelID).Delete(&models.VideoQueue{})
 	if err != nil {
 		logger.WithError(err).Error("Failed to clear played items")
 		return 0, fmt.Errorf("failed to clear played items: %w", err)
@@ -177,82 +188,13 @@ func ClearPlayedFromQueue() (int64, error) {
 	return result, nil
 }
 
-// ScanAndAddVideos scans a directory for video files and adds them to the queue
-func ScanAndAddVideos(directory string, extensions []string) (int, error) {
+// InjectAd adds a single-ad pod to the front of the queue of a channel
+func InjectAd(channelID int64, filepath string) (*models.AdPod, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
 		"module":     "streamer",
-		"function":   "ScanAndAddVideos",
-		"directory":  directory,
-		"extensions": extensions,
-	})
-
-	logger.Info("Scanning directory for videos...")
-
-	if extensions == nil || len(extensions) == 0 {
-		extensions = []string{".ts", ".mp4", ".mkv", ".avi", ".mov"}
-	}
-
-	addedCount := 0
-	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			logger.WithError(err).WithField("path", path).Warn("Error accessing path")
-			return nil // Continue walking
-		}
-
-		if info.IsDir() {
-			return nil
-		}
-
-		// Check if file has valid extension
-		ext := filepath.Ext(path)
-		validExt := false
-		for _, validExtension := range extensions {
-			if ext == validExtension {
-				validExt = true
-				break
-			}
-		}
-
-		if !validExt {
-			return nil
-		}
-
-		// First, add to available_files table
-		_, err = AddToAvailableFiles(path)
-		if err != nil {
-			logger.WithError(err).WithField("path", path).Warn("Failed to add video to available files")
-			return nil // Continue walking
-		}
-
-		// Then add to queue
-		if err := AddToQueue(path, false); err != nil {
-			logger.WithError(err).WithField("path", path).Warn("Failed to add video to queue")
-			return nil // Continue walking
-		}
-
-		addedCount++
-		return nil
-	})
-
-	if err != nil {
-		logger.WithError(err).Error("Error walking directory")
-		return addedCount, fmt.Errorf("error walking directory: %w", err)
-	}
-
-	logger.WithFields(logrus.Fields{
-		"added_count": addedCount,
-		"directory":   directory,
-	}).Info("✓ Directory scan completed")
-
-	return addedCount, nil
-}
-
-// InjectAd adds an ad to the front of the queue
-func InjectAd(filepath string) error {
-	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "InjectAd",
-		"filepath": filepath,
+		"function":   "InjectAd",
+		"channel_id": channelID,
+		"filepath":   filepath,
 	})
 
 	logger.Info("Injecting ad into queue...")
@@ -261,7 +203,7 @@ func InjectAd(filepath string) error {
 	normalizedPath, err := NormalizeFilePath(filepath)
 	if err != nil {
 		logger.WithError(err).Error("Failed to normalize filepath")
-		return fmt.Errorf("failed to normalize filepath: %w", err)
+		return nil, fmt.Errorf("failed to normalize filepath: %w", err)
 	}
 
 	// Use normalized path from here on
@@ -272,7 +214,7 @@ func InjectAd(filepath string) error {
 	fileInfo, err := os.Stat(filepath)
 	if err != nil {
 		logger.WithError(err).Error("Ad file does not exist")
-		return fmt.Errorf("file does not exist: %w", err)
+		return nil, fmt.Errorf("file does not exist: %w", err)
 	}
 
 	logger.WithField("file_size", fileInfo.Size()).Debug("Ad file validated")
@@ -282,47 +224,35 @@ func InjectAd(filepath string) error {
 	has, err := helpers.GetXORM().Where("filepath = ?", filepath).Get(&availFile)
 	if err != nil {
 		logger.WithError(err).Error("Failed to query available files")
-		return fmt.Errorf("database error: %w", err)
+		return nil, fmt.Errorf("database error: %w", err)
 	}
 
 	if !has {
 		logger.WithField("filepath", filepath).Error("Ad file not found in available files")
-		return fmt.Errorf("ad file must be scanned and added to available files before injecting (filepath: %s)", filepath)
+		return nil, fmt.Errorf("ad file must be scanned and added to available files before injecting (filepath: %s)", filepath)
 	}
 
 	fileID := availFile.FileID
 	logger.WithField("file_id", fileID).Debug("Ad file found in available files")
 
-	// Shift all queue positions up by 1
-	_, err = helpers.GetXORM().Exec("UPDATE video_queue SET queue_position = queue_position + 1 WHERE played = 0")
-	if err != nil {
-		logger.WithError(err).Error("Failed to shift queue positions")
-		return fmt.Errorf("failed to shift queue positions: %w", err)
+	if err := checkFileApproved(&availFile); err != nil {
+		logger.WithField("review_status", availFile.ReviewStatus).Warn("Ad file is not approved")
+		return nil, err
 	}
 
-	logger.Debug("Queue positions shifted for ad injection")
-
-	// Insert ad at position 0 (front of queue)
-	adItem := &models.VideoQueue{
-		FileID:        fileID,
-		AddedAt:       time.Now().Unix(),
-		Played:        0,
-		QueuePosition: 0,
-		IsAd:          1,
-	}
-
-	if _, err := helpers.GetXORM().Insert(adItem); err != nil {
+	pod, err := CreateAdPod(channelID, []string{fileID}, 0)
+	if err != nil {
 		logger.WithError(err).Error("Failed to inject ad into queue")
-		return fmt.Errorf("failed to inject ad: %w", err)
+		return nil, fmt.Errorf("failed to inject ad: %w", err)
 	}
 
 	logger.WithFields(logrus.Fields{
-		"queue_id": adItem.ID,
+		"pod_id":   pod.ID,
 		"file_id":  fileID,
 		"filepath": filepath,
 	}).Info("✓ Ad injected into queue successfully")
 
-	return nil
+	return pod, nil
 }
 
 // Helper function to count unplayed items
@@ -0,0 +1,152 @@
+package streamer
+
+import (
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// What Start does with a video that was interrupted by a restart
+const (
+	ResumePolicyResume  = "resume"  // continue near the saved position
+	ResumePolicyRestart = "restart" // play it again from the beginning
+	ResumePolicySkip    = "skip"    // mark it as played and move on
+)
+
+const (
+	// resumeSaveInterval is how often the playback position is persisted
+	resumeSaveInterval = 5 * time.Second
+
+	// resumeRewindBytes is subtracted from the saved offset on resume. Bytes
+	// written to stdin sit in the write buffer, the pipe and the demuxer before
+	// they air, so resuming a little earlier avoids losing content.
+	resumeRewindBytes = 1024 * 1024
+
+	// tsPacketSize is the MPEG-TS packet size, resume offsets are aligned to it
+	tsPacketSize = 188
+)
+
+// recoverInterruptedPlayback closes play history rows left open by a crash and
+// applies the resume policy to the video that was airing when the channel
+// stopped. Called by Start before the player loop runs.
+func (p *PersistentPlayer) recoverInterruptedPlayback() {
+	p.logger.Info("Recovering interrupted playback...")
+
+	// Rows without finished_at belong to a process that didn't shut down cleanly
+	var dangling []models.PlayHistory
+	if err := helpers.GetXORM().
+		Where("channel_id = ? AND (finished_at IS NULL OR finished_at = 0)", p.channelID).
+		Find(&dangling); err != nil {
+		p.logger.WithError(err).Error("Failed to query unfinished play history")
+	}
+
+	for i := range dangling {
+		history := &dangling[i]
+		history.Interrupted = 1
+		history.FinishedAt = history.StartedAt + history.PositionSeconds
+		history.DurationSeconds = history.PositionSeconds
+		if _, err := helpers.GetXORM().ID(history.ID).Cols("finished_at", "duration_seconds", "interrupted").Update(history); err != nil {
+			p.logger.WithError(err).WithField("history_id", history.ID).Error("Failed to close unfinished play history")
+			continue
+		}
+		closeAsRun(history, SkipReasonInterrupted)
+		p.logger.WithFields(logrus.Fields{
+			"history_id":       history.ID,
+			"file_id":          history.FileID,
+			"position_seconds": history.PositionSeconds,
+		}).Info("✓ Closed unfinished play history record")
+	}
+
+	p.closeDanglingAdPods()
+
+	var interrupted []models.VideoQueue
+	if err := helpers.GetXORM().
+		Where("channel_id = ? AND played = ? AND resume_byte_offset > ?", p.channelID, 0, 0).
+		Find(&interrupted); err != nil {
+		p.logger.WithError(err).Error("Failed to query interrupted videos")
+		return
+	}
+
+	p.mu.RLock()
+	policy := p.resumePolicy
+	p.mu.RUnlock()
+
+	for i := range interrupted {
+		video := &interrupted[i]
+		logger := p.logger.WithFields(logrus.Fields{
+			"video_id":         video.ID,
+			"file_id":          video.FileID,
+			"byte_offset":      video.ResumeByteOffset,
+			"position_seconds": video.ResumePositionSeconds,
+			"resume_policy":    policy,
+		})
+
+		switch policy {
+		case ResumePolicyRestart:
+			video.ClearResumePosition()
+			logger.Info("Interrupted video will start from the beginning")
+		case ResumePolicySkip:
+			video.ClearResumePosition()
+			video.MarkAsPlayed()
+			logger.Info("Interrupted video will be skipped")
+		default:
+			logger.Info("Interrupted video will be resumed")
+			continue
+		}
+
+		if _, err := helpers.GetXORM().ID(video.ID).
+			Cols("played", "played_at", "resume_byte_offset", "resume_position_seconds", "resume_saved_at").
+			Update(video); err != nil {
+			logger.WithError(err).Error("Failed to apply resume policy")
+		}
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"closed_history": len(dangling),
+		"interrupted":    len(interrupted),
+	}).Info("✓ Interrupted playback recovered")
+}
+
+// savePlaybackPosition persists how far the video has been fed. basePosition
+// is the position the current playback started at when it was resumed.
+func (p *PersistentPlayer) savePlaybackPosition(video *models.VideoQueue, history *models.PlayHistory, basePosition int64) {
+	p.mu.RLock()
+	var bytesWritten int64
+	if p.feed != nil && p.feed.FileID == video.FileID {
+		bytesWritten = p.feed.BytesWritten
+	}
+	p.mu.RUnlock()
+
+	// Nothing fed yet, keep the previous position
+	if bytesWritten == 0 {
+		return
+	}
+
+	now := time.Now().Unix()
+	elapsed := now - history.StartedAt
+
+	video.ResumeByteOffset = bytesWritten
+	video.ResumePositionSeconds = basePosition + elapsed
+	video.ResumeSavedAt = now
+	if _, err := helpers.GetXORM().ID(video.ID).Cols("resume_byte_offset", "resume_position_seconds", "resume_saved_at").Update(video); err != nil {
+		p.logger.WithError(err).WithField("video_id", video.ID).Warn("Failed to save playback position")
+	}
+
+	if history.ID != 0 {
+		history.PositionSeconds = elapsed
+		if _, err := helpers.GetXORM().ID(history.ID).Cols("position_seconds").Update(history); err != nil {
+			p.logger.WithError(err).WithField("history_id", history.ID).Warn("Failed to save history position")
+		}
+	}
+}
+
+// resumeOffset returns the byte offset to resume a video from
+func resumeOffset(savedOffset int64) int64 {
+	offset := savedOffset - resumeRewindBytes
+	if offset < 0 {
+		return 0
+	}
+	return offset - offset%tsPacketSize
+}
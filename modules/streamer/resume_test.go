@@ -0,0 +1,31 @@
+package streamer
+
+import "testing"
+
+func TestResumeOffset(t *testing.T) {
+	tests := []struct {
+		name        string
+		savedOffset int64
+		want        int64
+	}{
+		{name: "start of the file", savedOffset: 0, want: 0},
+		{name: "within the rewind", savedOffset: resumeRewindBytes - 1, want: 0},
+		{name: "exactly the rewind", savedOffset: resumeRewindBytes, want: 0},
+		{name: "packet aligned", savedOffset: resumeRewindBytes + 10*tsPacketSize, want: 10 * tsPacketSize},
+		{name: "rounded down to a packet", savedOffset: resumeRewindBytes + 10*tsPacketSize + 100, want: 10 * tsPacketSize},
+		{name: "less than a packet past the rewind", savedOffset: resumeRewindBytes + tsPacketSize - 1, want: 0},
+		{name: "negative", savedOffset: -5, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resumeOffset(tt.savedOffset)
+			if got != tt.want {
+				t.Errorf("resumeOffset(%d) = %d, want %d", tt.savedOffset, got, tt.want)
+			}
+			if got%tsPacketSize != 0 {
+				t.Errorf("resumeOffset(%d) = %d is not a multiple of %d", tt.savedOffset, got, tsPacketSize)
+			}
+		})
+	}
+}
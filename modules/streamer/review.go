@@ -0,0 +1,141 @@
+package streamer
+
+import (
+	"fmt"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// autoApproveReviewer is recorded as reviewer of files approved by
+// library.auto_approve
+const autoApproveReviewer = "auto"
+
+// applyLibraryReview sets the review state of a file added by a scan or the
+// library watcher: approved with library.auto_approve, pending otherwise
+func applyLibraryReview(file *models.AvailableFiles) {
+	file.IsActive = 0
+	file.ReviewStatus = models.ReviewStatusPending
+
+	if helpers.GetConfig().Library.AutoApprove {
+		file.IsActive = 1
+		file.ReviewStatus = models.ReviewStatusApproved
+		file.ReviewedBy = autoApproveReviewer
+		file.ReviewedAt = file.AddedTime
+	}
+}
+
+// checkFileApproved returns an error if the file may not go on air
+func checkFileApproved(file *models.AvailableFiles) error {
+	if !file.IsApproved() {
+		return fmt.Errorf("file is not approved for airing (review status: %s)", file.ReviewStatus)
+	}
+	return nil
+}
+
+// isFileApproved returns true if the file is in the library and approved
+func isFileApproved(fileID string) (bool, error) {
+	approved, err := helpers.GetXORM().
+		Where("file_id = ? AND is_active = 1", fileID).
+		Exist(&models.AvailableFiles{})
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	return approved, nil
+}
+
+// GetFilesForReview returns the files with the given review status, oldest
+// first so the review queue is worked through in order
+func GetFilesForReview(status string) ([]models.AvailableFiles, error) {
+	switch status {
+	case models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusRejected:
+	default:
+		return nil, fmt.Errorf("invalid review status %q: use %s, %s or %s", status,
+			models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusRejected)
+	}
+
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().
+		Where("review_status = ?", status).
+		OrderBy("added_time ASC").
+		Find(&files); err != nil {
+		return nil, fmt.Errorf("failed to fetch files for review: %w", err)
+	}
+	return files, nil
+}
+
+// ApproveFile approves a file for airing
+func ApproveFile(fileID, reviewer, note string) (*models.AvailableFiles, error) {
+	return reviewFile(fileID, models.ReviewStatusApproved, reviewer, note)
+}
+
+// RejectFile rejects a file. It is taken off air: its unplayed queue entries
+// are removed and the schedule, programming grid and ad campaigns skip it
+// until it is approved again. Returns the number of queue entries removed.
+func RejectFile(fileID, reviewer, note string) (*models.AvailableFiles, int64, error) {
+	file, err := reviewFile(fileID, models.ReviewStatusRejected, reviewer, note)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	removed, err := helpers.GetXORM().
+		Where("file_id = ? AND played = 0", fileID).
+		Delete(&models.VideoQueue{})
+	if err != nil {
+		return file, 0, fmt.Errorf("failed to remove rejected file from queue: %w", err)
+	}
+
+	return file, removed, nil
+}
+
+// reviewFile records a review decision
+func reviewFile(fileID, status, reviewer, note string) (*models.AvailableFiles, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "reviewFile",
+		"file_id":  fileID,
+		"status":   status,
+		"reviewer": reviewer,
+	})
+
+	if reviewer == "" {
+		return nil, fmt.Errorf("reviewer is required")
+	}
+	if len(note) > 500 {
+		return nil, fmt.Errorf("review note must be at most 500 characters")
+	}
+
+	file, err := GetFileInfoByID(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if status == models.ReviewStatusApproved {
+		if err := checkFileValidated(file); err != nil {
+			return nil, err
+		}
+	}
+
+	file.ReviewStatus = status
+	file.ReviewedBy = reviewer
+	file.ReviewedAt = time.Now().Unix()
+	file.ReviewNote = note
+	file.IsActive = 0
+	if status == models.ReviewStatusApproved {
+		file.IsActive = 1
+	}
+
+	if _, err := helpers.GetXORM().
+		Where("file_id = ?", fileID).
+		Cols("is_active", "review_status", "reviewed_by", "reviewed_at", "review_note").
+		Update(file); err != nil {
+		logger.WithError(err).Error("Failed to store review")
+		return nil, fmt.Errorf("failed to store review: %w", err)
+	}
+
+	logger.Info("✓ File reviewed")
+
+	return file, nil
+}
@@ -1,26 +1,160 @@
 package streamer
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"tv_streamer/helpers/logs"
+
+	"github.com/sirupsen/logrus"
 )
 
-// StartStream initializes and starts the TV streaming service
+// StartStream initializes and starts the TV streaming service for every enabled channel
 func StartStream() {
 	logger := logs.GetLogger().WithField("module", "streamer")
 	logger.Info("========================================")
 	logger.Info("Starting TV Streaming Service...")
 	logger.Info("========================================")
 
-	// Get persistent player instance
-	player := GetPersistentPlayer()
+	channels, err := GetChannels()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load channels")
+		return
+	}
+
+	started := 0
+	for i := range channels {
+		channel := &channels[i]
+		channelLogger := logger.WithFields(logrus.Fields{
+			"channel_id": channel.ID,
+			"channel":    channel.Name,
+		})
+
+		if !channel.IsEnabled() {
+			channelLogger.Info("Channel is disabled, skipping")
+			continue
+		}
 
-	// Start the player
-	if err := player.Start(); err != nil {
-		logger.WithError(err).Fatal("Failed to start streaming player")
+		// A failing channel must not take the others down
+		if err := registerChannelPlayer(channel).Start(); err != nil {
+			channelLogger.WithError(err).Error("Failed to start channel player")
+			continue
+		}
+		started++
+	}
+
+	if started == 0 {
+		logger.Fatal("Failed to start streaming player for any channel")
 		return
 	}
 
+	// Expired as-run entries are removed in the background
+	startAsRunRetention()
+
+	// Files added before fingerprints existed are hashed in the background
+	go backfillFingerprints()
+
+	// New files in the library folders are ingested automatically
+	startLibraryWatcher()
+
+	// The library is compared with the disk periodically
+	startConsistencyCheck()
+
+	// Abandoned uploads are removed in the background
+	startUploadCleanup()
+
+	// Uploads whose validation was interrupted are validated again
+	resumeUploadValidations()
+
+	// Prepared copies of deleted or changed files are removed in the background
+	startNormalizeCachePruning()
+
 	logger.Info("========================================")
-	logger.Info("✓ TV Streaming Service Started Successfully")
+	logger.WithField("channels", started).Info("✓ TV Streaming Service Started Successfully")
 	logger.Info("========================================")
 }
+
+// StopStream stops every running channel in parallel and finalizes its
+// playlists with the configured shutdown marker. Channels that don't stop
+// before the context expires are reported in the error and left to the
+// process exit.
+func StopStream(ctx context.Context) error {
+	logger := logs.GetLogger().WithField("module", "streamer")
+	marker := ActiveStreamingProfile().ShutdownMarker
+
+	logger.WithField("shutdown_marker", marker).Info("Stopping TV Streaming Service...")
+
+	stopAsRunRetention()
+	stopLibraryWatcher()
+	stopConsistencyCheck()
+	stopUploadCleanup()
+	stopNormalizeCachePruning()
+
+	playersMu.Lock()
+	running := make([]*PersistentPlayer, 0, len(players))
+	for _, player := range players {
+		if player.IsRunning() {
+			running = append(running, player)
+		}
+	}
+	playersMu.Unlock()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		failed  []string
+		pending = make(map[int64]bool, len(running))
+	)
+
+	for _, player := range running {
+		pending[player.ChannelID()] = true
+	}
+
+	for _, player := range running {
+		wg.Add(1)
+		go func(player *PersistentPlayer) {
+			defer wg.Done()
+
+			err := player.Stop()
+			if err == nil {
+				err = player.finalizePlaylists(marker)
+			}
+
+			mu.Lock()
+			delete(pending, player.ChannelID())
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("channel %d: %v", player.ChannelID(), err))
+			}
+			mu.Unlock()
+		}(player)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		mu.Lock()
+		for channelID := range pending {
+			failed = append(failed, fmt.Sprintf("channel %d: did not stop in time", channelID))
+		}
+		mu.Unlock()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to stop channels: %s", strings.Join(failed, "; "))
+	}
+
+	logger.WithField("channels", len(running)).Info("✓ TV Streaming Service stopped")
+	return nil
+}
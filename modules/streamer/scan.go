@@ -0,0 +1,317 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Scan modes select what a directory scan does with the files it finds
+const (
+	ScanModeLibrary  = "library"  // add to the library only
+	ScanModeQueue    = "queue"    // add to the library and the queue
+	ScanModeSchedule = "schedule" // add to the library and append to the schedule
+)
+
+// What a scan did, or would do in a dry run, with a file
+const (
+	ScanActionNone      = "none"      // already in the library (library mode) or schedule
+	ScanActionAdded     = "added"     // added to the library
+	ScanActionMoved     = "moved"     // known file at a new path, filepath updated
+	ScanActionQueued    = "queued"    // added to the queue
+	ScanActionScheduled = "scheduled" // appended to the schedule
+	ScanActionFailed    = "failed"
+)
+
+// DefaultVideoExtensions are the file extensions picked up by directory scans
+// and the library watcher
+var DefaultVideoExtensions = []string{".ts", ".mp4", ".mkv", ".avi", ".mov"}
+
+// ScanOptions select the files of a directory scan and what is done with them
+type ScanOptions struct {
+	Mode       string
+	Extensions []string // defaults to DefaultVideoExtensions
+	Include    []string // glob patterns, when set a file must match one of them
+	Exclude    []string // glob patterns, matching files and directories are skipped
+	MaxDepth   int      // directory levels below the scanned one, negative for no limit
+	DryRun     bool     // report what would change without changing anything
+}
+
+// ScanFileResult is what a scan did, or would do, with a file
+type ScanFileResult struct {
+	FilePath     string `json:"filepath"`
+	FileID       string `json:"file_id,omitempty"`
+	NewInLibrary bool   `json:"new_in_library"`
+	PreviousPath string `json:"previous_path,omitempty"` // set for a moved file
+	Action       string `json:"action"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ScanResult summarizes a directory scan
+type ScanResult struct {
+	Mode         string           `json:"mode"`
+	DryRun       bool             `json:"dry_run"`
+	FilesFound   int              `json:"files_found"`
+	LibraryAdded int              `json:"library_added"`
+	Moved        int              `json:"moved"`
+	Queued       int              `json:"queued"`
+	Scheduled    int              `json:"scheduled"`
+	Failed       int              `json:"failed"`
+	Files        []ScanFileResult `json:"files"`
+}
+
+// hasVideoExtension returns true if the file has one of the extensions,
+// ignoring case
+func hasVideoExtension(path string, extensions []string) bool {
+	ext := filepath.Ext(path)
+	for _, validExtension := range extensions {
+		if strings.EqualFold(ext, validExtension) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns true if the path relative to the scanned directory
+// matches one of the glob patterns. Patterns without a separator are matched
+// against the file or directory name, others against the relative path.
+func matchesAny(relPath string, patterns []string) bool {
+	name := filepath.Base(relPath)
+	for _, pattern := range patterns {
+		target := name
+		if strings.ContainsRune(pattern, '/') {
+			target = filepath.ToSlash(relPath)
+		}
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateScanOptions fills the defaults and checks the mode and patterns
+func validateScanOptions(options *ScanOptions) error {
+	if options.Mode == "" {
+		options.Mode = ScanModeQueue
+	}
+	switch options.Mode {
+	case ScanModeLibrary, ScanModeQueue, ScanModeSchedule:
+	default:
+		return fmt.Errorf("invalid scan mode %q: use %s, %s or %s", options.Mode, ScanModeLibrary, ScanModeQueue, ScanModeSchedule)
+	}
+
+	if len(options.Extensions) == 0 {
+		options.Extensions = DefaultVideoExtensions
+	}
+
+	for _, pattern := range append(append([]string{}, options.Include...), options.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid scan pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// ScanVideos scans a directory for video files and adds them to the library
+// and, depending on the mode, to the queue or the schedule of a channel
+func ScanVideos(channelID int64, directory string, options ScanOptions) (*ScanResult, error) {
+	if err := validateScanOptions(&options); err != nil {
+		return nil, err
+	}
+
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "ScanVideos",
+		"channel_id": channelID,
+		"directory":  directory,
+		"mode":       options.Mode,
+		"dry_run":    options.DryRun,
+	})
+
+	root, err := NormalizeFilePath(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize directory: %w", err)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("directory does not exist: %s", directory)
+	}
+
+	logger.Info("Scanning directory for videos...")
+
+	// Collect the files first so a dry run and a real scan see the same set
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			logger.WithError(err).WithField("path", path).Warn("Error accessing path")
+			return nil // Continue walking
+		}
+		if path == root {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(root, path)
+		if len(options.Exclude) > 0 && matchesAny(relPath, options.Exclude) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if entry.IsDir() {
+			depth := strings.Count(relPath, string(filepath.Separator)) + 1
+			if options.MaxDepth >= 0 && depth > options.MaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !hasVideoExtension(path, options.Extensions) {
+			return nil
+		}
+		if len(options.Include) > 0 && !matchesAny(relPath, options.Include) {
+			return nil
+		}
+
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		logger.WithError(err).Error("Error walking directory")
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+
+	result := &ScanResult{
+		Mode:       options.Mode,
+		DryRun:     options.DryRun,
+		FilesFound: len(paths),
+		Files:      make([]ScanFileResult, 0, len(paths)),
+	}
+
+	for _, path := range paths {
+		fileResult := scanFile(channelID, path, options)
+		if fileResult.Error != "" {
+			logger.WithField("path", path).WithField("error", fileResult.Error).Warn("Failed to scan video")
+		}
+
+		if fileResult.NewInLibrary {
+			result.LibraryAdded++
+		}
+		if fileResult.PreviousPath != "" {
+			result.Moved++
+		}
+		switch fileResult.Action {
+		case ScanActionQueued:
+			result.Queued++
+		case ScanActionScheduled:
+			result.Scheduled++
+		case ScanActionFailed:
+			result.Failed++
+		}
+		result.Files = append(result.Files, fileResult)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"files_found":   result.FilesFound,
+		"library_added": result.LibraryAdded,
+		"moved":         result.Moved,
+		"queued":        result.Queued,
+		"scheduled":     result.Scheduled,
+		"failed":        result.Failed,
+	}).Info("✓ Directory scan completed")
+
+	return result, nil
+}
+
+// scanFile adds a single file found by a scan according to the mode
+func scanFile(channelID int64, path string, options ScanOptions) ScanFileResult {
+	fileResult := ScanFileResult{FilePath: path}
+	fail := func(err error) ScanFileResult {
+		fileResult.Action = ScanActionFailed
+		fileResult.Error = err.Error()
+		return fileResult
+	}
+
+	// Library
+	existing, err := GetFileInfoByPath(path)
+	switch {
+	case err == nil:
+		fileResult.FileID = existing.FileID
+		if existing.IsMissing() && !options.DryRun {
+			if err := RefreshAvailableFile(existing); err != nil {
+				return fail(err)
+			}
+		}
+	case !errors.Is(err, ErrFileNotFound):
+		return fail(err)
+	case options.DryRun:
+		moved, err := FindMovedFile(path)
+		if err != nil {
+			return fail(err)
+		}
+		if moved != nil {
+			fileResult.FileID = moved.FileID
+			fileResult.PreviousPath = moved.FilePath
+		} else {
+			fileResult.NewInLibrary = true
+		}
+	default:
+		file, previousPath, err := addAvailableFile(path)
+		if err != nil {
+			return fail(err)
+		}
+		fileResult.FileID = file.FileID
+		fileResult.PreviousPath = previousPath
+		fileResult.NewInLibrary = previousPath == ""
+	}
+
+	switch options.Mode {
+	case ScanModeQueue:
+		fileResult.Action = ScanActionQueued
+		if !options.DryRun {
+			if err := AddToQueue(channelID, path, false); err != nil {
+				return fail(err)
+			}
+		}
+
+	case ScanModeSchedule:
+		// The schedule holds every file once
+		if !fileResult.NewInLibrary {
+			scheduled, err := helpers.GetXORM().
+				Where("file_id = ? AND channel_id = ?", fileResult.FileID, channelID).
+				Exist(&models.Schedule{})
+			if err != nil {
+				return fail(err)
+			}
+			if scheduled {
+				fileResult.Action = ScanActionNone
+				return fileResult
+			}
+		}
+		fileResult.Action = ScanActionScheduled
+		if !options.DryRun {
+			if err := AddToSchedule(channelID, path); err != nil {
+				return fail(err)
+			}
+		}
+
+	default:
+		switch {
+		case fileResult.NewInLibrary:
+			fileResult.Action = ScanActionAdded
+		case fileResult.PreviousPath != "":
+			fileResult.Action = ScanActionMoved
+		default:
+			fileResult.Action = ScanActionNone
+		}
+	}
+
+	return fileResult
+}
@@ -11,11 +11,12 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// AddToSchedule adds a video file to the schedule
-func AddToSchedule(filepath string) error {
+// AddToSchedule adds a video file to the schedule of a channel
+func AddToSchedule(channelID int64, filepath string) error {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "AddToSchedule",
+		"module":     "streamer",
+		"function":   "AddToSchedule",
+		"channel_id": channelID,
 	})
 
 	logger.WithField("filepath", filepath).Info("Adding video to schedule...")
@@ -58,9 +59,14 @@ func AddToSchedule(filepath string) error {
 	fileID := availFile.FileID
 	logger.WithField("file_id", fileID).Debug("File found in available files")
 
+	if err := checkFileApproved(&availFile); err != nil {
+		logger.WithField("review_status", availFile.ReviewStatus).Warn("File is not approved")
+		return err
+	}
+
 	// Check if already in schedule
 	var existingSchedule models.Schedule
-	has, err = helpers.GetXORM().Where("file_id = ?", fileID).Get(&existingSchedule)
+	has, err = helpers.GetXORM().Where("file_id = ? AND channel_id = ?", fileID, channelID).Get(&existingSchedule)
 	if err != nil {
 		logger.WithError(err).Error("Failed to query schedule")
 		return fmt.Errorf("database error: %w", err)
@@ -73,7 +79,7 @@ func AddToSchedule(filepath string) error {
 
 	// Get next schedule position
 	var maxPosition int
-	_, err = helpers.GetXORM().SQL("SELECT COALESCE(MAX(schedule_position), -1) FROM schedule").Get(&maxPosition)
+	_, err = helpers.GetXORM().SQL("SELECT COALESCE(MAX(schedule_position), -1) FROM schedule WHERE channel_id = ?", channelID).Get(&maxPosition)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get max schedule position")
 		return fmt.Errorf("failed to get schedule position: %w", err)
@@ -88,6 +94,7 @@ func AddToSchedule(filepath string) error {
 		SchedulePosition: nextPosition,
 		IsCurrent:        0,
 		AddedAt:          time.Now().Unix(),
+		ChannelID:        channelID,
 	}
 
 	if _, err := helpers.GetXORM().Insert(scheduleItem); err != nil {
@@ -104,18 +111,19 @@ func AddToSchedule(filepath string) error {
 	return nil
 }
 
-// GetNextFromSchedule gets the next video from the schedule (with endless loop)
-func GetNextFromSchedule() (*models.Schedule, error) {
+// GetNextFromSchedule gets the next video from the schedule of a channel (with endless loop)
+func GetNextFromSchedule(channelID int64) (*models.Schedule, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "GetNextFromSchedule",
+		"module":     "streamer",
+		"function":   "GetNextFromSchedule",
+		"channel_id": channelID,
 	})
 
 	logger.Debug("Getting next video from schedule...")
 
 	// First, check if there's a current item
 	var current models.Schedule
-	has, err := helpers.GetXORM().Where("is_current = ?", 1).Get(&current)
+	has, err := helpers.GetXORM().Where("is_current = ? AND channel_id = ?", 1, channelID).Get(&current)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current schedule item: %w", err)
 	}
@@ -124,7 +132,7 @@ func GetNextFromSchedule() (*models.Schedule, error) {
 	if has {
 		// Get the next item after current
 		hasNext, err := helpers.GetXORM().
-			Where("schedule_position > ?", current.SchedulePosition).
+			Where("schedule_position > ? AND channel_id = ?", current.SchedulePosition, channelID).
 			OrderBy("schedule_position ASC").
 			Get(&nextItem)
 
@@ -134,7 +142,7 @@ func GetNextFromSchedule() (*models.Schedule, error) {
 
 		if hasNext {
 			// Found next item, unmark current and mark next as current
-			_, err = helpers.GetXORM().Where("is_current = ?", 1).
+			_, err = helpers.GetXORM().Where("is_current = ? AND channel_id = ?", 1, channelID).
 				Cols("is_current").
 				Update(&models.Schedule{IsCurrent: 0})
 			if err != nil {
@@ -159,6 +167,7 @@ func GetNextFromSchedule() (*models.Schedule, error) {
 		// No next item found, loop back to the beginning
 		logger.Info("Reached end of schedule, looping back to beginning")
 		hasFirst, err := helpers.GetXORM().
+			Where("channel_id = ?", channelID).
 			OrderBy("schedule_position ASC").
 			Get(&nextItem)
 
@@ -172,7 +181,7 @@ func GetNextFromSchedule() (*models.Schedule, error) {
 		}
 
 		// Unmark current and mark first as current
-		_, err = helpers.GetXORM().Where("is_current = ?", 1).
+		_, err = helpers.GetXORM().Where("is_current = ? AND channel_id = ?", 1, channelID).
 			Cols("is_current").
 			Update(&models.Schedule{IsCurrent: 0})
 		if err != nil {
@@ -197,6 +206,7 @@ func GetNextFromSchedule() (*models.Schedule, error) {
 	// No current item, start from the beginning
 	logger.Info("No current item, starting from beginning of schedule")
 	hasFirst, err := helpers.GetXORM().
+		Where("channel_id = ?", channelID).
 		OrderBy("schedule_position ASC").
 		Get(&nextItem)
 
@@ -225,17 +235,19 @@ func GetNextFromSchedule() (*models.Schedule, error) {
 	return &nextItem, nil
 }
 
-// GetSchedule returns all items in the schedule
-func GetSchedule() ([]models.Schedule, error) {
+// GetSchedule returns all items in the schedule of a channel
+func GetSchedule(channelID int64) ([]models.Schedule, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "GetSchedule",
+		"module":     "streamer",
+		"function":   "GetSchedule",
+		"channel_id": channelID,
 	})
 
 	logger.Debug("Fetching schedule...")
 
 	var schedule []models.Schedule
 	err := helpers.GetXORM().
+		Where("channel_id = ?", channelID).
 		OrderBy("schedule_position ASC").
 		Find(&schedule)
 
@@ -249,17 +261,19 @@ func GetSchedule() ([]models.Schedule, error) {
 	return schedule, nil
 }
 
-// ResetSchedulePosition resets the schedule position to the beginning
-func ResetSchedulePosition() error {
+// ResetSchedulePosition resets the schedule position of a channel to the beginning
+func ResetSchedulePosition(channelID int64) error {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "ResetSchedulePosition",
+		"module":     "streamer",
+		"function":   "ResetSchedulePosition",
+		"channel_id": channelID,
 	})
 
 	logger.Info("Resetting schedule position...")
 
 	// Unmark all items
 	_, err := helpers.GetXORM().
+		Where("channel_id = ?", channelID).
 		Cols("is_current").
 		Update(&models.Schedule{IsCurrent: 0})
 
@@ -272,17 +286,18 @@ func ResetSchedulePosition() error {
 	return nil
 }
 
-// RemoveFromSchedule removes a video from the schedule by file_id
-func RemoveFromSchedule(fileID string) error {
+// RemoveFromSchedule removes a video from the schedule of a channel by file_id
+func RemoveFromSchedule(channelID int64, fileID string) error {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "RemoveFromSchedule",
-		"file_id":  fileID,
+		"module":     "streamer",
+		"function":   "RemoveFromSchedule",
+		"channel_id": channelID,
+		"file_id":    fileID,
 	})
 
 	logger.Info("Removing video from schedule...")
 
-	result, err := helpers.GetXORM().Where("file_id = ?", fileID).Delete(&models.Schedule{})
+	result, err := helpers.GetXORM().Where("file_id = ? AND channel_id = ?", fileID, channelID).Delete(&models.Schedule{})
 	if err != nil {
 		logger.WithError(err).Error("Failed to remove from schedule")
 		return fmt.Errorf("failed to remove from schedule: %w", err)
@@ -297,16 +312,17 @@ func RemoveFromSchedule(fileID string) error {
 	return nil
 }
 
-// ClearSchedule removes all items from the schedule
-func ClearSchedule() (int64, error) {
+// ClearSchedule removes all items from the schedule of a channel
+func ClearSchedule(channelID int64) (int64, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "streamer",
-		"function": "ClearSchedule",
+		"module":     "streamer",
+		"function":   "ClearSchedule",
+		"channel_id": channelID,
 	})
 
 	logger.Info("Clearing schedule...")
 
-	result, err := helpers.GetXORM().Where("1=1").Delete(&models.Schedule{})
+	result, err := helpers.GetXORM().Where("channel_id = ?", channelID).Delete(&models.Schedule{})
 	if err != nil {
 		logger.WithError(err).Error("Failed to clear schedule")
 		return 0, fmt.Errorf("failed to clear schedule: %w", err)
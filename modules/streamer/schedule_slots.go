@@ -0,0 +1,372 @@
+package streamer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slotTimeLayout is the layout of slot start and end times (local time)
+const slotTimeLayout = "15:04"
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// slotDayShortcuts expands recurrence shortcuts to weekday lists
+var slotDayShortcuts = map[string]string{
+	"daily":    "sun,mon,tue,wed,thu,fri,sat",
+	"weekdays": "mon,tue,wed,thu,fri",
+	"weekends": "sun,sat",
+}
+
+// NormalizeSlotDays validates a recurrence rule and returns it as a comma separated
+// list of weekdays. Accepts "daily", "weekdays", "weekends" or weekdays like "mon,wed,fri".
+func NormalizeSlotDays(days string) (string, error) {
+	days = strings.ToLower(strings.TrimSpace(days))
+	if expanded, ok := slotDayShortcuts[days]; ok {
+		return expanded, nil
+	}
+
+	selected := make(map[string]bool)
+	for _, day := range strings.Split(days, ",") {
+		day = strings.TrimSpace(day)
+		if len(day) > 3 {
+			day = day[:3]
+		}
+		valid := false
+		for _, name := range weekdayNames {
+			if day == name {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("invalid day '%s' (use daily, weekdays, weekends or mon..sun)", day)
+		}
+		selected[day] = true
+	}
+
+	// Keep a stable order
+	normalized := make([]string, 0, len(selected))
+	for _, name := range weekdayNames {
+		if selected[name] {
+			normalized = append(normalized, name)
+		}
+	}
+	return strings.Join(normalized, ","), nil
+}
+
+// CreateScheduleSlot validates and stores a new time slot with its block playlist.
+// The slot belongs to the channel set in slot.ChannelID.
+func CreateScheduleSlot(slot *models.ScheduleSlot, fileIDs []string) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "CreateScheduleSlot",
+		"channel_id": slot.ChannelID,
+		"name":       slot.Name,
+	})
+
+	logger.Info("Creating schedule slot...")
+
+	if slot.Name == "" {
+		return fmt.Errorf("slot name is required")
+	}
+
+	if _, err := time.Parse(slotTimeLayout, slot.StartTime); err != nil {
+		return fmt.Errorf("invalid start_time '%s', expected HH:MM", slot.StartTime)
+	}
+
+	if slot.HasEndTime() {
+		if _, err := time.Parse(slotTimeLayout, slot.EndTime); err != nil {
+			return fmt.Errorf("invalid end_time '%s', expected HH:MM", slot.EndTime)
+		}
+		if slot.EndTime == slot.StartTime {
+			return fmt.Errorf("end_time must differ from start_time")
+		}
+	}
+
+	days, err := NormalizeSlotDays(slot.Days)
+	if err != nil {
+		return err
+	}
+	slot.Days = days
+
+	if len(fileIDs) == 0 {
+		return fmt.Errorf("slot block playlist must contain at least one file")
+	}
+
+	// All files of the block must be in the library
+	for _, fileID := range fileIDs {
+		has, err := helpers.GetXORM().Where("file_id = ?", fileID).Exist(&models.AvailableFiles{})
+		if err != nil {
+			logger.WithError(err).Error("Failed to query available files")
+			return fmt.Errorf("database error: %w", err)
+		}
+		if !has {
+			return fmt.Errorf("file not found in available files (file_id: %s)", fileID)
+		}
+	}
+
+	slot.Enabled = 1
+	slot.LastRunDate = ""
+	slot.NextPosition = 0
+	slot.CreatedAt = time.Now().Unix()
+
+	session := helpers.GetXORM().NewSession()
+	defer session.Close()
+
+	if err := session.Begin(); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := session.Insert(slot); err != nil {
+		session.Rollback()
+		logger.WithError(err).Error("Failed to insert schedule slot")
+		return fmt.Errorf("failed to create slot: %w", err)
+	}
+
+	for i, fileID := range fileIDs {
+		item := &models.ScheduleSlotItem{
+			SlotID:   slot.ID,
+			FileID:   fileID,
+			Position: i,
+		}
+		if _, err := session.Insert(item); err != nil {
+			session.Rollback()
+			logger.WithError(err).Error("Failed to insert schedule slot item")
+			return fmt.Errorf("failed to add file to slot: %w", err)
+		}
+	}
+
+	if err := session.Commit(); err != nil {
+		return fmt.Errorf("failed to commit slot: %w", err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"slot_id":    slot.ID,
+		"start_time": slot.StartTime,
+		"end_time":   slot.EndTime,
+		"days":       slot.Days,
+		"items":      len(fileIDs),
+	}).Info("✓ Schedule slot created successfully")
+
+	return nil
+}
+
+// GetScheduleSlots returns all time slots of a channel ordered by start time
+func GetScheduleSlots(channelID int64) ([]models.ScheduleSlot, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "GetScheduleSlots",
+		"channel_id": channelID,
+	})
+
+	var slots []models.ScheduleSlot
+	if err := helpers.GetXORM().Where("channel_id = ?", channelID).OrderBy("start_time ASC, priority DESC").Find(&slots); err != nil {
+		logger.WithError(err).Error("Failed to fetch schedule slots")
+		return nil, fmt.Errorf("failed to fetch schedule slots: %w", err)
+	}
+
+	logger.WithField("total_slots", len(slots)).Debug("✓ Schedule slots fetched successfully")
+
+	return slots, nil
+}
+
+// GetScheduleSlotItems returns the block playlist of a slot
+func GetScheduleSlotItems(slotID int64) ([]models.ScheduleSlotItem, error) {
+	var items []models.ScheduleSlotItem
+	err := helpers.GetXORM().
+		Where("slot_id = ?", slotID).
+		OrderBy("position ASC").
+		Find(&items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch slot items: %w", err)
+	}
+	return items, nil
+}
+
+// DeleteScheduleSlot removes a time slot of a channel and its block playlist
+func DeleteScheduleSlot(channelID int64, slotID int64) error {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "DeleteScheduleSlot",
+		"channel_id": channelID,
+		"slot_id":    slotID,
+	})
+
+	logger.Info("Deleting schedule slot...")
+
+	has, err := helpers.GetXORM().Where("id = ? AND channel_id = ?", slotID, channelID).Exist(&models.ScheduleSlot{})
+	if err != nil {
+		logger.WithError(err).Error("Failed to query schedule slots")
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !has {
+		logger.Warn("Schedule slot not found")
+		return fmt.Errorf("slot not found")
+	}
+
+	if _, err := helpers.GetXORM().Where("slot_id = ?", slotID).Delete(&models.ScheduleSlotItem{}); err != nil {
+		logger.WithError(err).Error("Failed to delete slot items")
+		return fmt.Errorf("failed to delete slot items: %w", err)
+	}
+
+	result, err := helpers.GetXORM().ID(slotID).Delete(&models.ScheduleSlot{})
+	if err != nil {
+		logger.WithError(err).Error("Failed to delete schedule slot")
+		return fmt.Errorf("failed to delete slot: %w", err)
+	}
+
+	if result == 0 {
+		logger.Warn("Schedule slot not found")
+		return fmt.Errorf("slot not found")
+	}
+
+	logger.Info("✓ Schedule slot deleted")
+	return nil
+}
+
+// GetNextFromSlots returns the next block item of the channel slot airing at the
+// given time, or nil if no slot is active and the endless loop should be used
+func GetNextFromSlots(channelID int64, now time.Time) (*models.ScheduleSlotItem, *models.ScheduleSlot, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "GetNextFromSlots",
+		"channel_id": channelID,
+	})
+
+	var slots []models.ScheduleSlot
+	err := helpers.GetXORM().
+		Where("enabled = ? AND channel_id = ?", 1, channelID).
+		OrderBy("priority DESC, start_time DESC").
+		Find(&slots)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query schedule slots: %w", err)
+	}
+
+	for i := range slots {
+		slot := &slots[i]
+
+		items, err := GetScheduleSlotItems(slot.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		position, occurrence, ok := pickSlotPosition(slot, len(items), now, slotBlockDuration(slot.ID))
+		if !ok {
+			continue
+		}
+
+		item := items[position]
+
+		slot.LastRunDate = occurrence
+		slot.NextPosition = position + 1
+		if _, err := helpers.GetXORM().ID(slot.ID).Cols("last_run_date", "next_position").Update(slot); err != nil {
+			logger.WithError(err).Warn("Failed to update slot position")
+		}
+
+		logger.WithFields(logrus.Fields{
+			"slot_id":  slot.ID,
+			"slot":     slot.Name,
+			"file_id":  item.FileID,
+			"position": position,
+		}).Info("✓ Next video retrieved from programming grid")
+
+		return &item, slot, nil
+	}
+
+	return nil, nil, nil
+}
+
+// pickSlotPosition decides which block position the slot airs next at the given
+// time. It returns false if the slot is not airing or its open-ended block is done.
+func pickSlotPosition(slot *models.ScheduleSlot, itemCount int, now time.Time, blockDuration time.Duration) (int, string, bool) {
+	if itemCount == 0 {
+		return 0, "", false
+	}
+
+	windowStart, _, ok := slotWindow(slot, now, blockDuration)
+	if !ok {
+		return 0, "", false
+	}
+
+	// A new occurrence always starts from the top of the block
+	occurrence := windowStart.Format("2006-01-02")
+	position := slot.NextPosition
+	if slot.LastRunDate != occurrence {
+		position = 0
+	}
+
+	if position >= itemCount {
+		if !slot.HasEndTime() {
+			// Open-ended block already aired for this occurrence
+			return 0, "", false
+		}
+		// Loop the block until the slot ends
+		position = 0
+	}
+
+	return position, occurrence, true
+}
+
+// slotWindow returns the airing window of the slot occurrence covering now.
+// Yesterday's occurrence is checked too so windows crossing midnight work.
+func slotWindow(slot *models.ScheduleSlot, now time.Time, blockDuration time.Duration) (time.Time, time.Time, bool) {
+	start, err := time.Parse(slotTimeLayout, slot.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	for _, dayOffset := range []int{0, -1} {
+		day := now.AddDate(0, 0, dayOffset)
+		if !strings.Contains(slot.Days, weekdayNames[day.Weekday()]) {
+			continue
+		}
+
+		windowStart := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, now.Location())
+
+		var windowEnd time.Time
+		if slot.HasEndTime() {
+			end, err := time.Parse(slotTimeLayout, slot.EndTime)
+			if err != nil {
+				return time.Time{}, time.Time{}, false
+			}
+			windowEnd = time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, now.Location())
+			if !windowEnd.After(windowStart) {
+				windowEnd = windowEnd.AddDate(0, 0, 1)
+			}
+		} else if blockDuration > 0 {
+			windowEnd = windowStart.Add(blockDuration)
+		} else {
+			// Unknown durations, the block may start until midnight
+			windowEnd = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, now.Location())
+		}
+
+		if !now.Before(windowStart) && now.Before(windowEnd) {
+			return windowStart, windowEnd, true
+		}
+	}
+
+	return time.Time{}, time.Time{}, false
+}
+
+// slotBlockDuration sums the video lengths of a slot's block playlist
+func slotBlockDuration(slotID int64) time.Duration {
+	var total int64
+	_, err := helpers.GetXORM().SQL(`
+		SELECT COALESCE(SUM(f.video_length), 0)
+		FROM schedule_slot_items i
+		JOIN availible_files f ON f.file_id = i.file_id
+		WHERE i.slot_id = ?`, slotID).Get(&total)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(total) * time.Second
+}
@@ -0,0 +1,154 @@
+package streamer
+
+import (
+	"testing"
+	"time"
+	"tv_streamer/modules/streamer/models"
+)
+
+func TestNormalizeSlotDays(t *testing.T) {
+	tests := []struct {
+		name    string
+		days    string
+		want    string
+		wantErr bool
+	}{
+		{name: "daily", days: "daily", want: "sun,mon,tue,wed,thu,fri,sat"},
+		{name: "weekdays", days: " Weekdays ", want: "mon,tue,wed,thu,fri"},
+		{name: "weekends", days: "weekends", want: "sun,sat"},
+		{name: "list is sorted", days: "fri,mon,wed", want: "mon,wed,fri"},
+		{name: "long names and duplicates", days: "monday,Mon, tuesday", want: "mon,tue"},
+		{name: "unknown day", days: "mon,xyz", wantErr: true},
+		{name: "empty", days: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeSlotDays(tt.days)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeSlotDays(%q) error = %v, wantErr %v", tt.days, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeSlotDays(%q) = %q, want %q", tt.days, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickSlotPosition(t *testing.T) {
+	// Friday
+	friday := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 16, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name           string
+		slot           models.ScheduleSlot
+		itemCount      int
+		now            time.Time
+		blockDuration  time.Duration
+		wantPosition   int
+		wantOccurrence string
+		wantOK         bool
+	}{
+		{
+			name:      "empty block",
+			slot:      models.ScheduleSlot{StartTime: "18:00", EndTime: "19:00", Days: "fri"},
+			itemCount: 0,
+			now:       friday(18, 30),
+		},
+		{
+			name:      "before the window",
+			slot:      models.ScheduleSlot{StartTime: "18:00", EndTime: "19:00", Days: "fri"},
+			itemCount: 3,
+			now:       friday(17, 59),
+		},
+		{
+			name:      "window end is exclusive",
+			slot:      models.ScheduleSlot{StartTime: "18:00", EndTime: "19:00", Days: "fri"},
+			itemCount: 3,
+			now:       friday(19, 0),
+		},
+		{
+			name:      "other day",
+			slot:      models.ScheduleSlot{StartTime: "18:00", EndTime: "19:00", Days: "mon,tue"},
+			itemCount: 3,
+			now:       friday(18, 30),
+		},
+		{
+			name:           "new occurrence starts at the top",
+			slot:           models.ScheduleSlot{StartTime: "18:00", EndTime: "19:00", Days: "fri", LastRunDate: "2026-10-09", NextPosition: 2},
+			itemCount:      3,
+			now:            friday(18, 0),
+			wantPosition:   0,
+			wantOccurrence: "2026-10-16",
+			wantOK:         true,
+		},
+		{
+			name:           "same occurrence continues",
+			slot:           models.ScheduleSlot{StartTime: "18:00", EndTime: "19:00", Days: "fri", LastRunDate: "2026-10-16", NextPosition: 2},
+			itemCount:      3,
+			now:            friday(18, 40),
+			wantPosition:   2,
+			wantOccurrence: "2026-10-16",
+			wantOK:         true,
+		},
+		{
+			name:           "block loops until the end time",
+			slot:           models.ScheduleSlot{StartTime: "18:00", EndTime: "19:00", Days: "fri", LastRunDate: "2026-10-16", NextPosition: 3},
+			itemCount:      3,
+			now:            friday(18, 50),
+			wantPosition:   0,
+			wantOccurrence: "2026-10-16",
+			wantOK:         true,
+		},
+		{
+			name:          "open-ended block aired once",
+			slot:          models.ScheduleSlot{StartTime: "18:00", Days: "fri", LastRunDate: "2026-10-16", NextPosition: 3},
+			itemCount:     3,
+			now:           friday(18, 20),
+			blockDuration: time.Hour,
+		},
+		{
+			name:          "open-ended block ends after its duration",
+			slot:          models.ScheduleSlot{StartTime: "18:00", Days: "fri"},
+			itemCount:     3,
+			now:           friday(18, 30),
+			blockDuration: 30 * time.Minute,
+		},
+		{
+			name:           "open-ended block without durations may start until midnight",
+			slot:           models.ScheduleSlot{StartTime: "18:00", Days: "fri"},
+			itemCount:      3,
+			now:            friday(23, 59),
+			wantPosition:   0,
+			wantOccurrence: "2026-10-16",
+			wantOK:         true,
+		},
+		{
+			name:           "window crossing midnight belongs to the previous day",
+			slot:           models.ScheduleSlot{StartTime: "23:00", EndTime: "01:00", Days: "thu", LastRunDate: "2026-10-15", NextPosition: 1},
+			itemCount:      3,
+			now:            friday(0, 30),
+			wantPosition:   1,
+			wantOccurrence: "2026-10-15",
+			wantOK:         true,
+		},
+		{
+			name:      "invalid start time",
+			slot:      models.ScheduleSlot{StartTime: "6pm", EndTime: "19:00", Days: "fri"},
+			itemCount: 3,
+			now:       friday(18, 30),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, occurrence, ok := pickSlotPosition(&tt.slot, tt.itemCount, tt.now, tt.blockDuration)
+			if ok != tt.wantOK || position != tt.wantPosition || occurrence != tt.wantOccurrence {
+				t.Errorf("pickSlotPosition() = (%d, %q, %v), want (%d, %q, %v)",
+					position, occurrence, ok, tt.wantPosition, tt.wantOccurrence, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ffmpegRestartBaseDelay is the delay before the first restart attempt
+	ffmpegRestartBaseDelay = 1 * time.Second
+
+	// ffmpegRestartMaxDelay caps the exponential backoff between restart attempts
+	ffmpegRestartMaxDelay = 60 * time.Second
+
+	// ffmpegStableUptime is how long FFmpeg must run before the backoff is reset
+	ffmpegStableUptime = 60 * time.Second
+)
+
+// errFFmpegUnavailable is returned by the feeder when the persistent FFmpeg
+// process is down. The video is retried once the supervisor restarted FFmpeg
+// instead of being marked as played.
+var errFFmpegUnavailable = errors.New("FFmpeg is not running")
+
+// errFeedStalled is returned by the feeder when FFmpeg stopped reading its
+// input for longer than the configured stall timeout
+var errFeedStalled = errors.New("FFmpeg stalled while feeding")
+
+// errVideoSkipped is returned by playVideo when the video was skipped
+var errVideoSkipped = errors.New("video skipped by user")
+
+// errPlayerStopped is returned by playVideo when the player is stopped while a
+// video is airing. The video stays in the queue.
+var errPlayerStopped = errors.New("player stopped")
+
+// superviseFFmpeg restarts the persistent FFmpeg process with exponential
+// backoff whenever it exits while the player is running. The HLS muxer runs
+// with append_list, so a restarted process continues the segment numbering of
+// the existing playlist and marks the gap with #EXT-X-DISCONTINUITY.
+func (p *PersistentPlayer) superviseFFmpeg(stop <-chan struct{}) {
+	defer p.workers.Done()
+	p.logger.Info("Starting FFmpeg supervisor...")
+
+	failures := 0
+
+	for {
+		p.mu.RLock()
+		done := p.ffmpegDone
+		startedAt := p.ffmpegStartedAt
+		p.mu.RUnlock()
+
+		select {
+		case <-stop:
+			p.logger.Info("Stop signal received in FFmpeg supervisor, exiting")
+			return
+		case <-done:
+		}
+
+		// Stop() closes stdin on purpose, that exit is not a crash
+		select {
+		case <-stop:
+			p.logger.Info("Stop signal received in FFmpeg supervisor, exiting")
+			return
+		default:
+		}
+
+		// A cut on skip is restarted right away and doesn't count as a crash
+		p.mu.Lock()
+		planned := p.ffmpegPlannedExit
+		p.ffmpegPlannedExit = false
+		p.mu.Unlock()
+
+		if planned {
+			err := p.startPersistentFFmpeg()
+			if err == nil {
+				p.logger.Info("✓ Persistent FFmpeg restarted after cut")
+				continue
+			}
+			p.logger.WithError(err).Error("Failed to restart persistent FFmpeg after cut")
+			p.mu.Lock()
+			p.ffmpegLastExitReason = err.Error()
+			p.ffmpegLastExitAt = time.Now()
+			p.mu.Unlock()
+		}
+
+		if time.Since(startedAt) >= ffmpegStableUptime {
+			failures = 0
+		}
+
+		for {
+			delay := ffmpegRestartDelay(failures)
+
+			p.mu.RLock()
+			reason := p.ffmpegLastExitReason
+			p.mu.RUnlock()
+
+			p.logger.WithFields(logrus.Fields{
+				"exit_reason": reason,
+				"failures":    failures,
+				"delay":       delay.String(),
+			}).Warn("⚠ Persistent FFmpeg exited unexpectedly, restarting...")
+
+			select {
+			case <-stop:
+				p.logger.Info("Stop signal received in FFmpeg supervisor, exiting")
+				return
+			case <-time.After(delay):
+			}
+
+			failures++
+
+			if err := p.startPersistentFFmpeg(); err != nil {
+				p.mu.Lock()
+				p.ffmpegLastExitReason = err.Error()
+				p.mu.Unlock()
+				p.logger.WithError(err).Error("Failed to restart persistent FFmpeg")
+				continue
+			}
+
+			p.mu.Lock()
+			p.ffmpegRestarts++
+			restarts := p.ffmpegRestarts
+			p.mu.Unlock()
+
+			p.logger.WithField("restarts", restarts).Info("✓ Persistent FFmpeg restarted, HLS numbering resumed")
+			break
+		}
+	}
+}
+
+// ffmpegRestartDelay returns the backoff delay after the given number of
+// consecutive failures
+func ffmpegRestartDelay(failures int) time.Duration {
+	delay := ffmpegRestartBaseDelay
+	for i := 0; i < failures && delay < ffmpegRestartMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > ffmpegRestartMaxDelay {
+		delay = ffmpegRestartMaxDelay
+	}
+	return delay
+}
+
+// waitForFFmpeg blocks until the persistent FFmpeg process is running. It
+// returns false if the player is stopped first.
+func (p *PersistentPlayer) waitForFFmpeg(stop <-chan struct{}) bool {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		p.mu.RLock()
+		running := p.ffmpegRunning
+		p.mu.RUnlock()
+
+		if running {
+			return true
+		}
+
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// cutFFmpeg interrupts FFmpeg so the input it has buffered (pipe and demuxer)
+// is dropped instead of aired. FFmpeg finalizes the current segment on the
+// interrupt and the supervisor restarts it without backoff, so the next video
+// follows a #EXT-X-DISCONTINUITY within one segment.
+func (p *PersistentPlayer) cutFFmpeg(stop <-chan struct{}) error {
+	p.mu.Lock()
+	cmd := p.cmd
+	done := p.ffmpegDone
+	running := p.ffmpegRunning
+	if running {
+		p.ffmpegPlannedExit = true
+	}
+	p.mu.Unlock()
+
+	if !running || cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	p.logger.Info("✂ Cutting FFmpeg input...")
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		// Interrupts aren't supported everywhere, killing drops the buffer too
+		if err := cmd.Process.Kill(); err != nil {
+			return fmt.Errorf("failed to stop FFmpeg: %w", err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		p.logger.Warn("FFmpeg did not exit after interrupt, killing process")
+		if err := cmd.Process.Kill(); err != nil {
+			return fmt.Errorf("failed to kill FFmpeg: %w", err)
+		}
+		<-done
+	}
+
+	// Wait for the supervisor to bring FFmpeg back
+	ready := make(chan bool, 1)
+	go func() {
+		ready <- p.waitForFFmpeg(stop)
+	}()
+
+	select {
+	case ok := <-ready:
+		if !ok {
+			return errPlayerStopped
+		}
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("FFmpeg did not restart in time after cut")
+	}
+
+	p.logger.Info("✓ FFmpeg input cut")
+	return nil
+}
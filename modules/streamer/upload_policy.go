@@ -0,0 +1,521 @@
+package streamer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Rules of the upload validation policy
+const (
+	PolicyRuleVideoCodec = "video_codec"
+	PolicyRuleAudioCodec = "audio_codec"
+	PolicyRuleResolution = "resolution"
+	PolicyRuleFrameRate  = "frame_rate"
+	PolicyRuleDuration   = "duration"
+	PolicyRuleAudio      = "audio"
+	PolicyRuleInterlaced = "interlaced"
+	PolicyRuleLoudness   = "loudness"
+)
+
+// Actions taken when a file violates a rule
+const (
+	PolicyActionReject  = "reject"
+	PolicyActionWarn    = "warn"
+	PolicyActionConform = "conform"
+)
+
+// Results of the validation of an upload, uploads are processing until the
+// background validation finished
+const (
+	ValidationProcessing = "processing"
+	ValidationPassed     = "passed"
+	ValidationWarned     = "warned"
+	ValidationConformed  = "conformed"
+	ValidationRejected   = "rejected"
+)
+
+// defaultLoudnessTolerance is used when upload.policy.loudness_tolerance_lu is not set
+const defaultLoudnessTolerance = 2.0
+
+var policyRules = []string{PolicyRuleVideoCodec, PolicyRuleAudioCodec, PolicyRuleResolution, PolicyRuleFrameRate,
+	PolicyRuleDuration, PolicyRuleAudio, PolicyRuleInterlaced, PolicyRuleLoudness}
+
+var policyActions = []string{PolicyActionReject, PolicyActionWarn, PolicyActionConform}
+
+// loudnessPattern finds the integrated loudness in the output of the
+// loudnorm filter
+var loudnessPattern = regexp.MustCompile(`"input_i"\s*:\s*"(-?[0-9.]+|-inf)"`)
+
+// MediaInfo is what the policy checks of a file
+type MediaInfo struct {
+	VideoCodec string   `json:"video_codec"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+	FrameRate  int      `json:"frame_rate"`
+	Interlaced bool     `json:"interlaced"`
+	Duration   float64  `json:"duration"`
+	HasAudio   bool     `json:"has_audio"`
+	AudioCodec string   `json:"audio_codec,omitempty"`
+	Loudness   *float64 `json:"loudness_lufs,omitempty"`
+}
+
+// PolicyFinding is a rule a file violates and what was done about it
+type PolicyFinding struct {
+	Rule    string `json:"rule"`
+	Action  string `json:"action"`
+	Message string `json:"message"`
+}
+
+// ValidationReport is the result of the validation of an upload, stored
+// with the file. Media describes the file as it was uploaded, Error why a
+// rejected file failed.
+type ValidationReport struct {
+	Status    string          `json:"status"`
+	Media     MediaInfo       `json:"media"`
+	Findings  []PolicyFinding `json:"findings"`
+	Error     string          `json:"error,omitempty"`
+	CheckedAt int64           `json:"checked_at"`
+}
+
+var (
+	uploadPolicy     helpers.UploadPolicyConfig
+	uploadPolicyErr  error
+	uploadPolicyOnce sync.Once
+)
+
+// UploadPolicy returns the validation policy of uploads with defaults filled
+// in. It is built and validated once, an invalid policy returns the error of
+// ValidateUploadPolicy on every call.
+func UploadPolicy() (helpers.UploadPolicyConfig, error) {
+	uploadPolicyOnce.Do(func() {
+		uploadPolicy = buildUploadPolicy()
+		uploadPolicyErr = ValidateUploadPolicy(&uploadPolicy)
+	})
+	return uploadPolicy, uploadPolicyErr
+}
+
+// buildUploadPolicy reads the upload policy from the configuration.
+// upload.required_width and upload.required_height are the resolution range
+// when the policy sets none.
+func buildUploadPolicy() helpers.UploadPolicyConfig {
+	upload := helpers.GetConfig().Upload
+	policy := upload.Policy
+	if policy.MinWidth == 0 && policy.MaxWidth == 0 && upload.RequiredWidth > 0 {
+		policy.MinWidth = upload.RequiredWidth
+		policy.MaxWidth = upload.RequiredWidth
+	}
+	if policy.MinHeight == 0 && policy.MaxHeight == 0 && upload.RequiredHeight > 0 {
+		policy.MinHeight = upload.RequiredHeight
+		policy.MaxHeight = upload.RequiredHeight
+	}
+	return policy
+}
+
+// ValidateUploadPolicy fills in defaults for empty settings and checks the
+// remaining values. All problems are reported in a single error.
+func ValidateUploadPolicy(policy *helpers.UploadPolicyConfig) error {
+	if policy.LoudnessToleranceLU == 0 {
+		policy.LoudnessToleranceLU = defaultLoudnessTolerance
+	}
+
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	checkRange := func(name string, min, max int) {
+		if min < 0 || max < 0 {
+			addProblem("upload.policy.min_%s and max_%s can't be negative", name, name)
+		} else if max > 0 && min > max {
+			addProblem("upload.policy.min_%s must not be larger than max_%s (got %d and %d)", name, name, min, max)
+		}
+	}
+	checkRange("width", policy.MinWidth, policy.MaxWidth)
+	checkRange("height", policy.MinHeight, policy.MaxHeight)
+	checkRange("fps", policy.MinFrameRate, policy.MaxFrameRate)
+	checkRange("duration_seconds", policy.MinDurationSeconds, policy.MaxDurationSeconds)
+
+	if policy.LoudnessTargetLUFS > 0 {
+		addProblem("upload.policy.loudness_target_lufs must be negative (got %g)", policy.LoudnessTargetLUFS)
+	}
+	if policy.LoudnessToleranceLU < 0 {
+		addProblem("upload.policy.loudness_tolerance_lu can't be negative (got %g)", policy.LoudnessToleranceLU)
+	}
+
+	for rule, action := range policy.Actions {
+		if !containsString(policyRules, rule) {
+			addProblem("upload.policy.actions has unknown rule '%s', rules are %s", rule, strings.Join(policyRules, ", "))
+			continue
+		}
+		if !containsString(policyActions, action) {
+			addProblem("upload.policy.actions.%s must be one of %s (got '%s')", rule, strings.Join(policyActions, ", "), action)
+		}
+	}
+	if policyAction(*policy, PolicyRuleDuration) == PolicyActionConform {
+		addProblem("upload.policy.actions.duration can't be conform, the length of a file can't be changed")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid upload policy: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// policyAction returns the action of a rule, rules without one reject
+func policyAction(policy helpers.UploadPolicyConfig, rule string) string {
+	if action := policy.Actions[rule]; action != "" {
+		return action
+	}
+	return PolicyActionReject
+}
+
+// mediaInfoFromProbe reads the values the policy checks from ffprobe data
+func mediaInfoFromProbe(probeDataJSON string) (MediaInfo, error) {
+	var probeData FFProbeData
+	if err := json.Unmarshal([]byte(probeDataJSON), &probeData); err != nil {
+		return MediaInfo{}, fmt.Errorf("invalid ffprobe output: %w", err)
+	}
+
+	var info MediaInfo
+	hasVideo := false
+	for _, stream := range probeData.Streams {
+		if stream.CodecType == "video" && !hasVideo {
+			hasVideo = true
+			info.VideoCodec = stream.CodecName
+			info.Width = stream.Width
+			info.Height = stream.Height
+			info.FrameRate = parseFrameRate(stream.FrameRate)
+			switch stream.FieldOrder {
+			case "tt", "bb", "tb", "bt":
+				info.Interlaced = true
+			}
+		} else if stream.CodecType == "audio" && !info.HasAudio {
+			info.HasAudio = true
+			info.AudioCodec = stream.CodecName
+		}
+	}
+	fmt.Sscanf(probeData.Format.Duration, "%f", &info.Duration)
+
+	if !hasVideo || info.Width == 0 || info.Height == 0 {
+		return info, fmt.Errorf("no video stream found or invalid dimensions")
+	}
+	return info, nil
+}
+
+// measureLoudness returns the integrated loudness (LUFS) of the first audio
+// track, measured with the loudnorm filter over the whole file
+func measureLoudness(path string) (float64, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-i", path,
+		"-map", "0:a:0", "-af", "loudnorm=print_format=json", "-f", "null", "-")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure loudness: %w", err)
+	}
+
+	match := loudnessPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, fmt.Errorf("failed to measure loudness: no result in ffmpeg output")
+	}
+	if match[1] == "-inf" {
+		// Silence
+		return -70, nil
+	}
+	return strconv.ParseFloat(match[1], 64)
+}
+
+// checkUploadPolicy returns the rules a file violates with their actions
+func checkUploadPolicy(info MediaInfo, policy helpers.UploadPolicyConfig) []PolicyFinding {
+	var findings []PolicyFinding
+	addFinding := func(rule, format string, args ...interface{}) {
+		findings = append(findings, PolicyFinding{
+			Rule:    rule,
+			Action:  policyAction(policy, rule),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(policy.VideoCodecs) > 0 && !containsCodec(policy.VideoCodecs, info.VideoCodec) {
+		addFinding(PolicyRuleVideoCodec, "video codec %s is not one of %s", info.VideoCodec, strings.Join(policy.VideoCodecs, ", "))
+	}
+	if outOfRange(info.Width, policy.MinWidth, policy.MaxWidth) || outOfRange(info.Height, policy.MinHeight, policy.MaxHeight) {
+		addFinding(PolicyRuleResolution, "resolution %dx%d is outside %s x %s", info.Width, info.Height,
+			describeRange(policy.MinWidth, policy.MaxWidth), describeRange(policy.MinHeight, policy.MaxHeight))
+	}
+	if outOfRange(info.FrameRate, policy.MinFrameRate, policy.MaxFrameRate) {
+		addFinding(PolicyRuleFrameRate, "frame rate %d fps is outside %s fps", info.FrameRate, describeRange(policy.MinFrameRate, policy.MaxFrameRate))
+	}
+	if outOfRange(int(info.Duration), policy.MinDurationSeconds, policy.MaxDurationSeconds) {
+		addFinding(PolicyRuleDuration, "duration %.0f seconds is outside %s seconds", info.Duration, describeRange(policy.MinDurationSeconds, policy.MaxDurationSeconds))
+	}
+	if policy.RequireProgressive && info.Interlaced {
+		addFinding(PolicyRuleInterlaced, "video is interlaced")
+	}
+
+	if !info.HasAudio {
+		if policy.RequireAudio {
+			addFinding(PolicyRuleAudio, "file has no audio track")
+		}
+		return findings
+	}
+	if len(policy.AudioCodecs) > 0 && !containsCodec(policy.AudioCodecs, info.AudioCodec) {
+		addFinding(PolicyRuleAudioCodec, "audio codec %s is not one of %s", info.AudioCodec, strings.Join(policy.AudioCodecs, ", "))
+	}
+	if policy.LoudnessTargetLUFS != 0 && info.Loudness != nil {
+		if diff := *info.Loudness - policy.LoudnessTargetLUFS; diff > policy.LoudnessToleranceLU || -diff > policy.LoudnessToleranceLU {
+			addFinding(PolicyRuleLoudness, "loudness %.1f LUFS is more than %g LU from %g LUFS", *info.Loudness, policy.LoudnessToleranceLU, policy.LoudnessTargetLUFS)
+		}
+	}
+
+	return findings
+}
+
+// ApplyUploadPolicy checks an uploaded file against the upload policy and
+// conforms it in place where the policy says so. It returns the ffprobe data
+// of the file to store and the report; files violating a rule that rejects
+// return an error naming every violation.
+func ApplyUploadPolicy(path, filename string) (string, *ValidationReport, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "ApplyUploadPolicy",
+		"filename": filename,
+	})
+
+	policy, err := UploadPolicy()
+	if err != nil {
+		return "", nil, err
+	}
+
+	probeData, err := GetFFProbeData(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get video metadata: %w", err)
+	}
+	info, err := mediaInfoFromProbe(probeData)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if policy.LoudnessTargetLUFS != 0 && info.HasAudio {
+		loudness, err := measureLoudness(path)
+		if err != nil {
+			return "", nil, err
+		}
+		info.Loudness = &loudness
+	}
+
+	report := &ValidationReport{
+		Status:    ValidationPassed,
+		Media:     info,
+		Findings:  checkUploadPolicy(info, policy),
+		CheckedAt: time.Now().Unix(),
+	}
+
+	logger.WithFields(logrus.Fields{
+		"width":       info.Width,
+		"height":      info.Height,
+		"video_codec": info.VideoCodec,
+		"frame_rate":  info.FrameRate,
+		"duration":    info.Duration,
+		"audio_codec": info.AudioCodec,
+		"findings":    len(report.Findings),
+	}).Info("Upload checked against the validation policy")
+
+	var rejected, conform []string
+	for _, finding := range report.Findings {
+		switch finding.Action {
+		case PolicyActionReject:
+			rejected = append(rejected, finding.Message)
+		case PolicyActionConform:
+			conform = append(conform, finding.Rule)
+		case PolicyActionWarn:
+			report.Status = ValidationWarned
+		}
+	}
+	if len(rejected) > 0 {
+		report.Status = ValidationRejected
+		return "", report, fmt.Errorf("file violates the upload policy: %s", strings.Join(rejected, "; "))
+	}
+	if len(conform) == 0 {
+		return probeData, report, nil
+	}
+
+	if err := conformUpload(path, filename, info, conform, policy); err != nil {
+		return "", report, err
+	}
+
+	// Check the result again, the loudness is not measured twice
+	probeData, err = GetFFProbeData(path)
+	if err != nil {
+		return "", report, fmt.Errorf("failed to get video metadata of conformed file: %w", err)
+	}
+	conformed, err := mediaInfoFromProbe(probeData)
+	if err != nil {
+		return "", report, fmt.Errorf("conformed file is invalid: %w", err)
+	}
+	for _, finding := range checkUploadPolicy(conformed, policy) {
+		if finding.Action != PolicyActionWarn {
+			return "", report, fmt.Errorf("file violates the upload policy after conforming: %s", finding.Message)
+		}
+	}
+
+	report.Status = ValidationConformed
+	logger.WithField("rules", strings.Join(conform, ", ")).Info("✓ Upload conformed to the validation policy")
+
+	return probeData, report, nil
+}
+
+// conformUpload transcodes a file in place so it satisfies the rules, only
+// the streams a rule applies to are encoded again
+func conformUpload(path, filename string, info MediaInfo, rules []string, policy helpers.UploadPolicyConfig) error {
+	profile := ActiveStreamingProfile()
+	has := func(rule string) bool { return containsString(rules, rule) }
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-i", path}
+	if has(PolicyRuleAudio) {
+		// Add a silent track to files without audio
+		args = append(args, "-f", "lavfi", "-i", "anullsrc=r=48000:cl=stereo",
+			"-map", "0:v:0", "-map", "1:a:0", "-shortest")
+	} else {
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+	}
+
+	var videoFilters []string
+	if has(PolicyRuleInterlaced) {
+		videoFilters = append(videoFilters, "yadif")
+	}
+	if has(PolicyRuleResolution) {
+		width := evenSize(clampInt(info.Width, policy.MinWidth, policy.MaxWidth))
+		height := evenSize(clampInt(info.Height, policy.MinHeight, policy.MaxHeight))
+		videoFilters = append(videoFilters, fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1",
+			width, height, width, height))
+	}
+	if has(PolicyRuleFrameRate) {
+		videoFilters = append(videoFilters, fmt.Sprintf("fps=%d", clampInt(info.FrameRate, policy.MinFrameRate, policy.MaxFrameRate)))
+	}
+
+	if len(videoFilters) > 0 || has(PolicyRuleVideoCodec) {
+		codec := info.VideoCodec
+		if has(PolicyRuleVideoCodec) || !containsCodec(policy.VideoCodecs, codec) && len(policy.VideoCodecs) > 0 {
+			codec = firstOr(policy.VideoCodecs, "h264")
+		}
+		encoder := encoderForCodec(codec)
+		if len(videoFilters) > 0 {
+			args = append(args, "-vf", strings.Join(videoFilters, ","))
+		}
+		args = append(args, "-c:v", encoder, "-b:v", profile.VideoBitrate)
+		if encoder == "libx264" || encoder == "libx265" {
+			args = append(args, "-preset", profile.FFmpegPreset, "-pix_fmt", "yuv420p")
+		}
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+
+	if has(PolicyRuleAudio) || has(PolicyRuleAudioCodec) || has(PolicyRuleLoudness) {
+		codec := info.AudioCodec
+		if has(PolicyRuleAudio) || has(PolicyRuleAudioCodec) || !containsCodec(policy.AudioCodecs, codec) && len(policy.AudioCodecs) > 0 {
+			codec = firstOr(policy.AudioCodecs, "aac")
+		}
+		if has(PolicyRuleLoudness) {
+			args = append(args, "-af", fmt.Sprintf("loudnorm=I=%g:TP=-1.5:LRA=11", policy.LoudnessTargetLUFS))
+		}
+		args = append(args, "-c:a", encoderForCodec(codec), "-b:a", profile.AudioBitrate)
+	} else {
+		args = append(args, "-c:a", "copy")
+	}
+
+	// The extension of the upload tells ffmpeg the container, the file is
+	// hidden so the library watcher doesn't ingest it
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	tempPath := filepath.Join(filepath.Dir(path), "."+base+".conform"+filepath.Ext(filename))
+	args = append(args, tempPath)
+
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "conformUpload",
+		"filename": filename,
+		"rules":    strings.Join(rules, ", "),
+	})
+	logger.Info("Conforming upload to the validation policy...")
+	startTime := time.Now()
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(tempPath)
+		logger.WithError(err).WithField("ffmpeg_output", lastLines(string(output), 5)).Error("Failed to conform upload")
+		return fmt.Errorf("failed to conform file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace file with conformed file: %w", err)
+	}
+
+	logger.WithField("duration", time.Since(startTime).String()).Debug("Upload transcoded")
+	return nil
+}
+
+// containsCodec compares codec names case-insensitively
+func containsCodec(codecs []string, codec string) bool {
+	for _, c := range codecs {
+		if codecMatches(codec, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// outOfRange returns true if value is below min or above max, zero bounds
+// are not checked
+func outOfRange(value, min, max int) bool {
+	return (min > 0 && value < min) || (max > 0 && value > max)
+}
+
+// describeRange formats the bounds of a range for messages
+func describeRange(min, max int) string {
+	switch {
+	case min > 0 && max > 0 && min == max:
+		return strconv.Itoa(min)
+	case min > 0 && max > 0:
+		return fmt.Sprintf("%d-%d", min, max)
+	case min > 0:
+		return fmt.Sprintf("at least %d", min)
+	case max > 0:
+		return fmt.Sprintf("at most %d", max)
+	default:
+		return "any"
+	}
+}
+
+// clampInt moves value into [min, max], zero bounds are not applied
+func clampInt(value, min, max int) int {
+	if min > 0 && value < min {
+		value = min
+	}
+	if max > 0 && value > max {
+		value = max
+	}
+	return value
+}
+
+// evenSize rounds a dimension down to an even number, most encoders need it
+func evenSize(size int) int {
+	return size - size%2
+}
+
+// firstOr returns the first value of values, or fallback if it is empty
+func firstOr(values []string, fallback string) string {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return fallback
+}
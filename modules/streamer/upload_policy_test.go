@@ -0,0 +1,374 @@
+package streamer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"tv_streamer/helpers"
+	"tv_streamer/modules/streamer/models"
+)
+
+// testPolicy is a broadcast policy: 1080p h264/hevc at 24-60 fps with audio
+func testPolicy() helpers.UploadPolicyConfig {
+	return helpers.UploadPolicyConfig{
+		VideoCodecs:         []string{"h264", "hevc"},
+		AudioCodecs:         []string{"aac", "mp2"},
+		MinWidth:            1920,
+		MaxWidth:            1920,
+		MinHeight:           1080,
+		MaxHeight:           1080,
+		MinFrameRate:        24,
+		MaxFrameRate:        60,
+		MinDurationSeconds:  1,
+		RequireAudio:        true,
+		RequireProgressive:  true,
+		LoudnessTargetLUFS:  -23,
+		LoudnessToleranceLU: 2,
+		Actions: map[string]string{
+			PolicyRuleVideoCodec: PolicyActionConform,
+			PolicyRuleFrameRate:  PolicyActionWarn,
+			PolicyRuleAudio:      PolicyActionConform,
+		},
+	}
+}
+
+// testMedia is a file that satisfies testPolicy
+func testMedia() MediaInfo {
+	loudness := -23.5
+	return MediaInfo{
+		VideoCodec: "h264",
+		Width:      1920,
+		Height:     1080,
+		FrameRate:  25,
+		Duration:   600,
+		HasAudio:   true,
+		AudioCodec: "aac",
+		Loudness:   &loudness,
+	}
+}
+
+func TestCheckUploadPolicy(t *testing.T) {
+	loudness := func(lufs float64) *float64 { return &lufs }
+
+	tests := []struct {
+		name   string
+		change func(info *MediaInfo, policy *helpers.UploadPolicyConfig)
+		want   []PolicyFinding
+	}{
+		{
+			name:   "compliant file",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) {},
+		},
+		{
+			name:   "codec names are case-insensitive",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.VideoCodec = "H264" },
+		},
+		{
+			name:   "video codec with its action",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.VideoCodec = "mpeg2video" },
+			want:   []PolicyFinding{{Rule: PolicyRuleVideoCodec, Action: PolicyActionConform, Message: "video codec mpeg2video is not one of h264, hevc"}},
+		},
+		{
+			name:   "resolution rejects without an action",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.Width, info.Height = 1280, 720 },
+			want:   []PolicyFinding{{Rule: PolicyRuleResolution, Action: PolicyActionReject, Message: "resolution 1280x720 is outside 1920 x 1080"}},
+		},
+		{
+			name:   "frame rate",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.FrameRate = 15 },
+			want:   []PolicyFinding{{Rule: PolicyRuleFrameRate, Action: PolicyActionWarn, Message: "frame rate 15 fps is outside 24-60 fps"}},
+		},
+		{
+			name:   "duration",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.Duration = 0.4 },
+			want:   []PolicyFinding{{Rule: PolicyRuleDuration, Action: PolicyActionReject, Message: "duration 0 seconds is outside at least 1 seconds"}},
+		},
+		{
+			name:   "interlaced",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.Interlaced = true },
+			want:   []PolicyFinding{{Rule: PolicyRuleInterlaced, Action: PolicyActionReject, Message: "video is interlaced"}},
+		},
+		{
+			name: "interlaced allowed",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) {
+				info.Interlaced, policy.RequireProgressive = true, false
+			},
+		},
+		{
+			name: "no audio skips the audio checks",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) {
+				info.HasAudio, info.AudioCodec, info.Loudness = false, "", nil
+			},
+			want: []PolicyFinding{{Rule: PolicyRuleAudio, Action: PolicyActionConform, Message: "file has no audio track"}},
+		},
+		{
+			name: "no audio allowed",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) {
+				info.HasAudio, info.AudioCodec, info.Loudness = false, "", nil
+				policy.RequireAudio = false
+			},
+		},
+		{
+			name:   "audio codec",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.AudioCodec = "opus" },
+			want:   []PolicyFinding{{Rule: PolicyRuleAudioCodec, Action: PolicyActionReject, Message: "audio codec opus is not one of aac, mp2"}},
+		},
+		{
+			name:   "loudness within the tolerance",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.Loudness = loudness(-21) },
+		},
+		{
+			name:   "too loud",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.Loudness = loudness(-14.2) },
+			want:   []PolicyFinding{{Rule: PolicyRuleLoudness, Action: PolicyActionReject, Message: "loudness -14.2 LUFS is more than 2 LU from -23 LUFS"}},
+		},
+		{
+			name:   "too quiet",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.Loudness = loudness(-30) },
+			want:   []PolicyFinding{{Rule: PolicyRuleLoudness, Action: PolicyActionReject, Message: "loudness -30.0 LUFS is more than 2 LU from -23 LUFS"}},
+		},
+		{
+			name:   "loudness not measured",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) { info.Loudness = nil },
+		},
+		{
+			name: "empty policy checks nothing",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) {
+				*info = MediaInfo{VideoCodec: "vp9", Width: 320, Height: 240, FrameRate: 5, Interlaced: true}
+				*policy = helpers.UploadPolicyConfig{}
+			},
+		},
+		{
+			name: "every violation is reported",
+			change: func(info *MediaInfo, policy *helpers.UploadPolicyConfig) {
+				info.VideoCodec, info.Width, info.FrameRate, info.AudioCodec = "vp9", 3840, 120, "opus"
+			},
+			want: []PolicyFinding{
+				{Rule: PolicyRuleVideoCodec, Action: PolicyActionConform, Message: "video codec vp9 is not one of h264, hevc"},
+				{Rule: PolicyRuleResolution, Action: PolicyActionReject, Message: "resolution 3840x1080 is outside 1920 x 1080"},
+				{Rule: PolicyRuleFrameRate, Action: PolicyActionWarn, Message: "frame rate 120 fps is outside 24-60 fps"},
+				{Rule: PolicyRuleAudioCodec, Action: PolicyActionReject, Message: "audio codec opus is not one of aac, mp2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, policy := testMedia(), testPolicy()
+			tt.change(&info, &policy)
+
+			got := checkUploadPolicy(info, policy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkUploadPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUploadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(policy *helpers.UploadPolicyConfig)
+		wantErr []string
+	}{
+		{name: "valid", change: func(policy *helpers.UploadPolicyConfig) {}},
+		{name: "empty", change: func(policy *helpers.UploadPolicyConfig) { *policy = helpers.UploadPolicyConfig{} }},
+		{
+			name:    "negative bound",
+			change:  func(policy *helpers.UploadPolicyConfig) { policy.MinWidth = -1 },
+			wantErr: []string{"upload.policy.min_width and max_width can't be negative"},
+		},
+		{
+			name:    "min above max",
+			change:  func(policy *helpers.UploadPolicyConfig) { policy.MinFrameRate, policy.MaxFrameRate = 60, 24 },
+			wantErr: []string{"upload.policy.min_fps must not be larger than max_fps (got 60 and 24)"},
+		},
+		{
+			name:   "min without max",
+			change: func(policy *helpers.UploadPolicyConfig) { policy.MinDurationSeconds, policy.MaxDurationSeconds = 60, 0 },
+		},
+		{
+			name:    "positive loudness target",
+			change:  func(policy *helpers.UploadPolicyConfig) { policy.LoudnessTargetLUFS = 23 },
+			wantErr: []string{"loudness_target_lufs must be negative"},
+		},
+		{
+			name:    "negative tolerance",
+			change:  func(policy *helpers.UploadPolicyConfig) { policy.LoudnessToleranceLU = -1 },
+			wantErr: []string{"loudness_tolerance_lu can't be negative"},
+		},
+		{
+			name:    "unknown rule",
+			change:  func(policy *helpers.UploadPolicyConfig) { policy.Actions["bitrate"] = PolicyActionWarn },
+			wantErr: []string{"upload.policy.actions has unknown rule 'bitrate'"},
+		},
+		{
+			name:    "unknown action",
+			change:  func(policy *helpers.UploadPolicyConfig) { policy.Actions[PolicyRuleResolution] = "ignore" },
+			wantErr: []string{"upload.policy.actions.resolution must be one of reject, warn, conform (got 'ignore')"},
+		},
+		{
+			name:    "duration can't be conformed",
+			change:  func(policy *helpers.UploadPolicyConfig) { policy.Actions[PolicyRuleDuration] = PolicyActionConform },
+			wantErr: []string{"upload.policy.actions.duration can't be conform"},
+		},
+		{
+			name: "all problems in one error",
+			change: func(policy *helpers.UploadPolicyConfig) {
+				policy.MinHeight, policy.MaxHeight = 1080, 720
+				policy.LoudnessTargetLUFS = 1
+			},
+			wantErr: []string{"min_height must not be larger than max_height", "loudness_target_lufs must be negative"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testPolicy()
+			tt.change(&policy)
+
+			err := ValidateUploadPolicy(&policy)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateUploadPolicy() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateUploadPolicy() returned no error, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateUploadPolicy() error = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+
+	t.Run("default tolerance", func(t *testing.T) {
+		policy := helpers.UploadPolicyConfig{}
+		if err := ValidateUploadPolicy(&policy); err != nil {
+			t.Fatalf("ValidateUploadPolicy() error = %v", err)
+		}
+		if policy.LoudnessToleranceLU != defaultLoudnessTolerance {
+			t.Errorf("LoudnessToleranceLU = %g, want %g", policy.LoudnessToleranceLU, defaultLoudnessTolerance)
+		}
+	})
+}
+
+func TestDescribeRange(t *testing.T) {
+	tests := []struct {
+		min, max int
+		want     string
+	}{
+		{min: 1920, max: 1920, want: "1920"},
+		{min: 24, max: 60, want: "24-60"},
+		{min: 1, max: 0, want: "at least 1"},
+		{min: 0, max: 3600, want: "at most 3600"},
+		{min: 0, max: 0, want: "any"},
+	}
+
+	for _, tt := range tests {
+		if got := describeRange(tt.min, tt.max); got != tt.want {
+			t.Errorf("describeRange(%d, %d) = %q, want %q", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestOutOfRange(t *testing.T) {
+	tests := []struct {
+		value, min, max int
+		want            bool
+	}{
+		{value: 25, min: 24, max: 60, want: false},
+		{value: 24, min: 24, max: 60, want: false},
+		{value: 60, min: 24, max: 60, want: false},
+		{value: 23, min: 24, max: 60, want: true},
+		{value: 61, min: 24, max: 60, want: true},
+		{value: 1000, min: 24, max: 0, want: false},
+		{value: 0, min: 0, max: 60, want: false},
+		{value: 0, min: 0, max: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := outOfRange(tt.value, tt.min, tt.max); got != tt.want {
+			t.Errorf("outOfRange(%d, %d, %d) = %v, want %v", tt.value, tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestMediaInfoFromProbe(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   string
+		want    MediaInfo
+		wantErr bool
+	}{
+		{
+			name: "video and audio",
+			probe: `{"format": {"duration": "600.5"}, "streams": [
+				{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080, "r_frame_rate": "30000/1001", "field_order": "progressive"},
+				{"codec_type": "audio", "codec_name": "aac"}]}`,
+			want: MediaInfo{VideoCodec: "h264", Width: 1920, Height: 1080, FrameRate: 30, Duration: 600.5, HasAudio: true, AudioCodec: "aac"},
+		},
+		{
+			name: "interlaced without audio",
+			probe: `{"format": {"duration": "60"}, "streams": [
+				{"codec_type": "video", "codec_name": "mpeg2video", "width": 720, "height": 576, "r_frame_rate": "25/1", "field_order": "tt"}]}`,
+			want: MediaInfo{VideoCodec: "mpeg2video", Width: 720, Height: 576, FrameRate: 25, Interlaced: true, Duration: 60},
+		},
+		{
+			name: "first streams count",
+			probe: `{"format": {"duration": "10"}, "streams": [
+				{"codec_type": "audio", "codec_name": "mp2"},
+				{"codec_type": "video", "codec_name": "hevc", "width": 3840, "height": 2160, "r_frame_rate": "50/1"},
+				{"codec_type": "video", "codec_name": "mjpeg", "width": 300, "height": 300},
+				{"codec_type": "audio", "codec_name": "ac3"}]}`,
+			want: MediaInfo{VideoCodec: "hevc", Width: 3840, Height: 2160, FrameRate: 50, Duration: 10, HasAudio: true, AudioCodec: "mp2"},
+		},
+		{
+			name:    "audio only",
+			probe:   `{"format": {"duration": "10"}, "streams": [{"codec_type": "audio", "codec_name": "mp3"}]}`,
+			want:    MediaInfo{Duration: 10, HasAudio: true, AudioCodec: "mp3"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			probe:   `{`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mediaInfoFromProbe(tt.probe)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mediaInfoFromProbe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mediaInfoFromProbe() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFileValidated(t *testing.T) {
+	tests := []struct {
+		status string
+		want   error
+	}{
+		{status: "", want: nil},
+		{status: ValidationPassed, want: nil},
+		{status: ValidationWarned, want: nil},
+		{status: ValidationConformed, want: nil},
+		{status: ValidationProcessing, want: ErrFileValidating},
+		{status: ValidationRejected, want: ErrFileFailedValidation},
+	}
+
+	for _, tt := range tests {
+		file := &models.AvailableFiles{ValidationStatus: tt.status}
+		if got := checkFileValidated(file); got != tt.want {
+			t.Errorf("checkFileValidated(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
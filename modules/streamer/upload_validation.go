@@ -0,0 +1,236 @@
+package streamer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// uploadPolicyReviewer is recorded as reviewer of uploads rejected by the
+// upload policy
+const uploadPolicyReviewer = "upload-policy"
+
+var (
+	// ErrFileValidating is returned when an upload is approved before its
+	// validation finished
+	ErrFileValidating = errors.New("file is still being validated against the upload policy")
+
+	// ErrFileFailedValidation is returned when an upload rejected by the
+	// upload policy is approved
+	ErrFileFailedValidation = errors.New("file failed the upload policy")
+)
+
+// uploadValidation is a stored upload waiting for validation
+type uploadValidation struct {
+	sessionID string
+	fileID    string
+	filename  string
+}
+
+var (
+	uploadValidationQueue []uploadValidation
+	uploadValidationMu    sync.Mutex
+	uploadValidationWake  = make(chan struct{}, 1)
+	uploadValidationOnce  sync.Once
+)
+
+// QueueUploadValidation validates a stored upload in the background. The
+// policy check, the loudness measurement and conforming can take as long as
+// the file plays, uploads are validated one at a time so they don't compete
+// with the channels for the CPU. The result is stored with the file and
+// broadcast as an upload_validated event.
+func QueueUploadValidation(sessionID, fileID, filename string) {
+	uploadValidationOnce.Do(func() {
+		go runUploadValidations()
+	})
+
+	uploadValidationMu.Lock()
+	uploadValidationQueue = append(uploadValidationQueue, uploadValidation{
+		sessionID: sessionID,
+		fileID:    fileID,
+		filename:  filename,
+	})
+	uploadValidationMu.Unlock()
+
+	select {
+	case uploadValidationWake <- struct{}{}:
+	default:
+	}
+}
+
+// resumeUploadValidations queues the uploads whose validation was cut short
+// by a restart again
+func resumeUploadValidations() {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "resumeUploadValidations",
+	})
+
+	var files []models.AvailableFiles
+	if err := helpers.GetXORM().
+		Where("validation_status = ?", ValidationProcessing).
+		OrderBy("added_time ASC").
+		Find(&files); err != nil {
+		logger.WithError(err).Error("Failed to query uploads waiting for validation")
+		return
+	}
+
+	for _, file := range files {
+		QueueUploadValidation("", file.FileID, filepath.Base(file.FilePath))
+	}
+	if len(files) > 0 {
+		logger.WithField("file_count", len(files)).Info("Validation of uploads resumed")
+	}
+}
+
+// runUploadValidations works through the queue, it waits for new uploads
+// when the queue is empty
+func runUploadValidations() {
+	for range uploadValidationWake {
+		for {
+			uploadValidationMu.Lock()
+			if len(uploadValidationQueue) == 0 {
+				uploadValidationMu.Unlock()
+				break
+			}
+			job := uploadValidationQueue[0]
+			uploadValidationQueue = uploadValidationQueue[1:]
+			uploadValidationMu.Unlock()
+
+			validateUpload(job)
+		}
+	}
+}
+
+// validateUpload applies the upload policy to a stored upload and records
+// the result. Uploads that violate a rejecting rule or can't be validated are
+// rejected, they stay in the review queue with the report until they are
+// deleted.
+func validateUpload(job uploadValidation) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "validateUpload",
+		"session_id": job.sessionID,
+		"file_id":    job.fileID,
+		"filename":   job.filename,
+	})
+
+	file, err := GetFileInfoByID(job.fileID)
+	if err != nil {
+		logger.WithError(err).Warn("Upload is gone before it was validated")
+		return
+	}
+
+	logger.Info("Validating upload against the upload policy...")
+	startTime := time.Now()
+
+	probeData, report, err := ApplyUploadPolicy(file.FilePath, job.filename)
+	if err != nil {
+		if report == nil {
+			report = &ValidationReport{CheckedAt: time.Now().Unix()}
+		}
+		report.Status = ValidationRejected
+		report.Error = err.Error()
+	}
+
+	encodedReport, encodeErr := json.Marshal(report)
+	if encodeErr != nil {
+		logger.WithError(encodeErr).Error("Failed to encode validation report")
+		return
+	}
+
+	file.ValidationStatus = report.Status
+	file.ValidationReport = string(encodedReport)
+	cols := []string{"validation_status", "validation_report"}
+
+	if err == nil {
+		// Conforming changes the size and the content
+		file.FFProbeData = probeData
+		file.VideoLength = ParseDuration(probeData)
+		if info, statErr := os.Stat(file.FilePath); statErr == nil {
+			file.FileSize = info.Size()
+		}
+		cols = append(cols, "ffprobe_data", "video_length", "file_size")
+
+		// A failure is not fatal, the file is hashed again on the next startup
+		if fingerprint, fpErr := ComputeFingerprint(file.FilePath); fpErr == nil {
+			file.Fingerprint = fingerprint
+			cols = append(cols, "fingerprint")
+		} else {
+			logger.WithError(fpErr).Warn("Failed to fingerprint uploaded file")
+		}
+	} else {
+		file.IsActive = 0
+		file.ReviewStatus = models.ReviewStatusRejected
+		file.ReviewedBy = uploadPolicyReviewer
+		file.ReviewedAt = report.CheckedAt
+		// Review notes are at most 500 characters
+		file.ReviewNote = err.Error()
+		if len(file.ReviewNote) > 500 {
+			file.ReviewNote = file.ReviewNote[:500]
+		}
+		cols = append(cols, "is_active", "review_status", "reviewed_by", "reviewed_at", "review_note")
+	}
+
+	if _, dbErr := helpers.GetXORM().
+		Where("file_id = ?", file.FileID).
+		Cols(cols...).
+		Update(file); dbErr != nil {
+		logger.WithError(dbErr).Error("Failed to store validation result")
+		return
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"validation_status": report.Status,
+		"duration":          time.Since(startTime).String(),
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Upload rejected by the upload policy")
+	} else {
+		logger.Info("✓ Upload validated")
+	}
+
+	BroadcastUploadValidated(UploadValidatedEvent{
+		Type:       UploadEventValidated,
+		SessionID:  job.sessionID,
+		FileID:     file.FileID,
+		Success:    err == nil,
+		Message:    validationMessage(report),
+		Validation: report,
+		Timestamp:  time.Now().Unix(),
+	})
+}
+
+// validationMessage describes the validation result of an upload
+func validationMessage(report *ValidationReport) string {
+	switch report.Status {
+	case ValidationRejected:
+		return "File validation failed: " + report.Error
+	case ValidationWarned:
+		return "File validated with policy warnings. File is waiting for approval."
+	case ValidationConformed:
+		return "File conformed to the upload policy. File is waiting for approval."
+	default:
+		return "File validated successfully. File is waiting for approval."
+	}
+}
+
+// checkFileValidated returns an error if the upload policy did not accept
+// the file (yet), files that were not uploaded have no validation
+func checkFileValidated(file *models.AvailableFiles) error {
+	switch file.ValidationStatus {
+	case ValidationProcessing:
+		return ErrFileValidating
+	case ValidationRejected:
+		return ErrFileFailedValidation
+	}
+	return nil
+}
@@ -0,0 +1,551 @@
+package streamer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultUploadSessionTTL is used when upload.session_ttl_minutes is not set
+	defaultUploadSessionTTL = 24 * time.Hour
+
+	// uploadCleanupInterval is how often expired upload sessions are removed
+	uploadCleanupInterval = 10 * time.Minute
+
+	// uploadTempSuffix is the extension of the files uploads are written to
+	uploadTempSuffix = ".tmp"
+
+	// uploadStreamPieceSize is the size streamed uploads are written in, an
+	// interrupted request keeps the pieces that arrived
+	uploadStreamPieceSize = 1024 * 1024
+)
+
+var (
+	// ErrUploadSessionNotFound is returned for unknown, finished or expired sessions
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+
+	// ErrUploadOutOfRange is returned for chunks that end after the file
+	ErrUploadOutOfRange = errors.New("chunk exceeds the file size")
+)
+
+// ByteRange is the byte range [start, end) of a file
+type ByteRange [2]int64
+
+var (
+	uploadLocks   = make(map[string]*sync.Mutex)
+	uploadLocksMu sync.Mutex
+
+	uploadCleanupStop chan struct{}
+	uploadCleanupOnce sync.Once
+)
+
+// lockUploadSession serializes the changes to one session and returns it
+// locked, chunks of different sessions are written in parallel. Locks are
+// only created for sessions that exist, unknown IDs don't leave one behind.
+func lockUploadSession(sessionID string) (*models.UploadSession, *sync.Mutex, error) {
+	uploadLocksMu.Lock()
+	lock, ok := uploadLocks[sessionID]
+	if !ok {
+		if _, err := GetUploadSession(sessionID); err != nil {
+			uploadLocksMu.Unlock()
+			return nil, nil, err
+		}
+		lock = &sync.Mutex{}
+		uploadLocks[sessionID] = lock
+	}
+	uploadLocksMu.Unlock()
+
+	lock.Lock()
+
+	// The session may have ended while waiting for the lock
+	session, err := GetUploadSession(sessionID)
+	if err != nil {
+		lock.Unlock()
+		if errors.Is(err, ErrUploadSessionNotFound) {
+			forgetUploadLock(sessionID)
+		}
+		return nil, nil, err
+	}
+	return session, lock, nil
+}
+
+// forgetUploadLock drops the lock of a session that no longer exists
+func forgetUploadLock(sessionID string) {
+	uploadLocksMu.Lock()
+	delete(uploadLocks, sessionID)
+	uploadLocksMu.Unlock()
+}
+
+// uploadSessionTTL returns how long a session is kept without new chunks
+func uploadSessionTTL() time.Duration {
+	if minutes := helpers.GetConfig().Upload.SessionTTLMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultUploadSessionTTL
+}
+
+// ValidateUpload checks the size and format of a file before it is uploaded
+// against upload.max_file_size_mb and upload.allowed_formats
+func ValidateUpload(filename string, size int64) error {
+	config := helpers.GetConfig()
+
+	if size <= 0 {
+		return fmt.Errorf("file size must be greater than 0")
+	}
+	maxSize := int64(config.Upload.MaxFileSizeMB) * 1024 * 1024
+	if size > maxSize {
+		return fmt.Errorf("file size exceeds maximum allowed size of %d MB", config.Upload.MaxFileSizeMB)
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	for _, allowedExt := range config.Upload.AllowedFormats {
+		if ext == allowedExt {
+			return nil
+		}
+	}
+	return fmt.Errorf("file format '%s' not allowed, allowed formats: %v", ext, config.Upload.AllowedFormats)
+}
+
+// VerifyChunkChecksum compares the hex SHA-256 of a chunk with checksum. An
+// empty checksum is not verified.
+func VerifyChunkChecksum(data []byte, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), checksum) {
+		return fmt.Errorf("chunk checksum mismatch")
+	}
+	return nil
+}
+
+// UploadSessionRanges returns the received byte ranges of a session
+func UploadSessionRanges(session *models.UploadSession) ([]ByteRange, error) {
+	var ranges []ByteRange
+	if err := json.Unmarshal([]byte(session.ReceivedRanges), &ranges); err != nil {
+		return nil, fmt.Errorf("invalid received ranges of session %s: %w", session.SessionID, err)
+	}
+	return ranges, nil
+}
+
+// MissingUploadRanges returns the byte ranges a session still waits for
+func MissingUploadRanges(session *models.UploadSession) ([]ByteRange, error) {
+	ranges, err := UploadSessionRanges(session)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []ByteRange{}
+	next := int64(0)
+	for _, r := range ranges {
+		if r[0] > next {
+			missing = append(missing, ByteRange{next, r[0]})
+		}
+		next = r[1]
+	}
+	if next < session.TotalSize {
+		missing = append(missing, ByteRange{next, session.TotalSize})
+	}
+	return missing, nil
+}
+
+// addByteRange adds [start, end) to sorted, non-overlapping ranges and
+// merges what touches
+func addByteRange(ranges []ByteRange, start, end int64) []ByteRange {
+	ranges = append(ranges, ByteRange{start, end})
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1] {
+			if r[1] > last[1] {
+				last[1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// newUploadSessionID returns a random session ID
+func newUploadSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// CreateUploadSession validates an upload and starts its session. The
+// session belongs to userID, only that user or an admin may continue it.
+func CreateUploadSession(userID int64, filename string, totalSize int64) (*models.UploadSession, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "CreateUploadSession",
+		"filename": filename,
+		"size":     totalSize,
+	})
+
+	if err := ValidateUpload(filename, totalSize); err != nil {
+		return nil, err
+	}
+
+	uploadDir := helpers.GetConfig().Upload.UploadDir
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		logger.WithError(err).Error("Failed to create upload directory")
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	sessionID, err := newUploadSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	tempPath := filepath.Join(uploadDir, sessionID+uploadTempSuffix)
+	file, err := os.Create(tempPath)
+	if err != nil {
+		logger.WithError(err).Error("Failed to create temporary file")
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	file.Close()
+
+	now := time.Now().Unix()
+	session := &models.UploadSession{
+		SessionID:      sessionID,
+		UserID:         userID,
+		Filename:       filepath.Base(filename),
+		TotalSize:      totalSize,
+		ReceivedRanges: "[]",
+		TempPath:       tempPath,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if _, err := helpers.GetXORM().Insert(session); err != nil {
+		os.Remove(tempPath)
+		logger.WithError(err).Error("Failed to insert upload session")
+		return nil, fmt.Errorf("failed to create upload session: %w", err)
+	}
+
+	logger.WithField("session_id", sessionID).Info("✓ Upload session created")
+
+	return session, nil
+}
+
+// GetUploadSession returns a session
+func GetUploadSession(sessionID string) (*models.UploadSession, error) {
+	var session models.UploadSession
+	has, err := helpers.GetXORM().Where("session_id = ?", sessionID).Get(&session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query upload session: %w", err)
+	}
+	if !has {
+		return nil, ErrUploadSessionNotFound
+	}
+	return &session, nil
+}
+
+// GetUploadSessions returns the sessions of a user, or of every user with
+// allUsers, newest first
+func GetUploadSessions(userID int64, allUsers bool) ([]models.UploadSession, error) {
+	query := helpers.GetXORM().OrderBy("created_at DESC")
+	if !allUsers {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var sessions []models.UploadSession
+	if err := query.Find(&sessions); err != nil {
+		return nil, fmt.Errorf("failed to fetch upload sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// WriteUploadChunk writes a chunk at its offset. Chunks may arrive in any
+// order and be sent again; a negative offset appends at the received size.
+// A checksum (hex SHA-256) is verified before anything is written.
+func WriteUploadChunk(sessionID string, offset int64, data []byte, checksum string) (*models.UploadSession, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("chunk is empty")
+	}
+	if err := VerifyChunkChecksum(data, checksum); err != nil {
+		return nil, err
+	}
+
+	session, lock, err := lockUploadSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	if offset < 0 {
+		offset = session.ReceivedSize
+	}
+	end := offset + int64(len(data))
+	if end > session.TotalSize {
+		return nil, fmt.Errorf("%w: chunk at offset %d with %d bytes, file size is %d bytes", ErrUploadOutOfRange, offset, len(data), session.TotalSize)
+	}
+
+	ranges, err := UploadSessionRanges(session)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(session.TempPath, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open temporary file: %w", err)
+	}
+	if _, err := file.WriteAt(data, offset); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write chunk: %w", err)
+	}
+
+	ranges = addByteRange(ranges, offset, end)
+	encoded, err := json.Marshal(ranges)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode received ranges: %w", err)
+	}
+
+	session.ReceivedRanges = string(encoded)
+	session.ReceivedSize = 0
+	if ranges[0][0] == 0 {
+		session.ReceivedSize = ranges[0][1]
+	}
+	session.UpdatedAt = time.Now().Unix()
+
+	if _, err := helpers.GetXORM().
+		Where("session_id = ?", sessionID).
+		Cols("received_size", "received_ranges", "updated_at").
+		Update(session); err != nil {
+		return nil, fmt.Errorf("failed to update upload session: %w", err)
+	}
+
+	return session, nil
+}
+
+// WriteUploadStream writes a stream at offset in pieces until it ends. The
+// session returned reflects the pieces written, also when err is set.
+func WriteUploadStream(sessionID string, offset int64, r io.Reader) (*models.UploadSession, error) {
+	session, err := GetUploadSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, uploadStreamPieceSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			written, err := WriteUploadChunk(sessionID, offset, buf[:n], "")
+			if err != nil {
+				return session, err
+			}
+			session = written
+			offset += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			return session, nil
+		}
+		if readErr != nil {
+			return session, fmt.Errorf("failed to read upload: %w", readErr)
+		}
+	}
+}
+
+// UploadSessionExpiry returns when a session is removed if no chunk arrives
+func UploadSessionExpiry(session *models.UploadSession) time.Time {
+	return time.Unix(session.UpdatedAt, 0).Add(uploadSessionTTL())
+}
+
+// CompleteUploadSession ends a session whose file was received completely.
+// The temporary file is handed over to the caller, which stores or removes
+// it.
+func CompleteUploadSession(sessionID string) (*models.UploadSession, error) {
+	session, lock, err := lockUploadSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	if !session.IsComplete() {
+		return nil, fmt.Errorf("upload is incomplete: received %d of %d bytes", session.ReceivedSize, session.TotalSize)
+	}
+
+	if _, err := helpers.GetXORM().Where("session_id = ?", sessionID).Delete(&models.UploadSession{}); err != nil {
+		return nil, fmt.Errorf("failed to end upload session: %w", err)
+	}
+	forgetUploadLock(sessionID)
+
+	logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "CompleteUploadSession",
+		"session_id": sessionID,
+	}).Info("✓ Upload session completed")
+
+	return session, nil
+}
+
+// DeleteUploadSession aborts a session and removes its temporary file
+func DeleteUploadSession(sessionID string) error {
+	session, lock, err := lockUploadSession(sessionID)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if _, err := helpers.GetXORM().Where("session_id = ?", sessionID).Delete(&models.UploadSession{}); err != nil {
+		return fmt.Errorf("failed to delete upload session: %w", err)
+	}
+	forgetUploadLock(sessionID)
+
+	if err := os.Remove(session.TempPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove temporary file: %w", err)
+	}
+
+	logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "streamer",
+		"function":   "DeleteUploadSession",
+		"session_id": sessionID,
+	}).Info("✓ Upload session deleted")
+
+	return nil
+}
+
+// CleanupUploadSessions removes sessions without a chunk for longer than
+// upload.session_ttl_minutes together with their temporary files, and
+// temporary files of no session that are as old
+func CleanupUploadSessions(now time.Time) (int64, error) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "CleanupUploadSessions",
+	})
+
+	cutoff := now.Add(-uploadSessionTTL())
+
+	var expired []models.UploadSession
+	if err := helpers.GetXORM().Where("updated_at < ?", cutoff.Unix()).Find(&expired); err != nil {
+		return 0, fmt.Errorf("failed to query expired upload sessions: %w", err)
+	}
+
+	var removed int64
+	for _, session := range expired {
+		err := DeleteUploadSession(session.SessionID)
+		if errors.Is(err, ErrUploadSessionNotFound) {
+			continue
+		}
+		if err != nil {
+			logger.WithError(err).WithField("session_id", session.SessionID).Warn("Failed to remove expired upload session")
+			continue
+		}
+		removed++
+	}
+
+	// Locks of sessions that ended without going through this package
+	uploadLocksMu.Lock()
+	for sessionID, lock := range uploadLocks {
+		if !lock.TryLock() {
+			continue
+		}
+		if _, err := GetUploadSession(sessionID); errors.Is(err, ErrUploadSessionNotFound) {
+			delete(uploadLocks, sessionID)
+		}
+		lock.Unlock()
+	}
+	uploadLocksMu.Unlock()
+
+	// Temporary files left behind by a crash or an older version
+	uploadDir := helpers.GetConfig().Upload.UploadDir
+	entries, err := os.ReadDir(uploadDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return removed, nil
+		}
+		return removed, fmt.Errorf("failed to read upload directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), uploadTempSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		sessionID := strings.TrimSuffix(entry.Name(), uploadTempSuffix)
+		if _, err := GetUploadSession(sessionID); !errors.Is(err, ErrUploadSessionNotFound) {
+			continue
+		}
+
+		path := filepath.Join(uploadDir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			logger.WithError(err).WithField("path", path).Warn("Failed to remove orphaned upload file")
+			continue
+		}
+		logger.WithField("path", path).Info("Removed orphaned upload file")
+	}
+
+	return removed, nil
+}
+
+// startUploadCleanup removes expired upload sessions now and then every
+// uploadCleanupInterval until stopUploadCleanup is called
+func startUploadCleanup() {
+	uploadCleanupOnce.Do(func() {
+		uploadCleanupStop = make(chan struct{})
+		go runUploadCleanup(uploadCleanupStop)
+	})
+}
+
+// stopUploadCleanup stops the cleanup job started by startUploadCleanup
+func stopUploadCleanup() {
+	if uploadCleanupStop != nil {
+		select {
+		case <-uploadCleanupStop:
+		default:
+			close(uploadCleanupStop)
+		}
+	}
+}
+
+func runUploadCleanup(stop <-chan struct{}) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "streamer",
+		"function": "runUploadCleanup",
+	})
+
+	ticker := time.NewTicker(uploadCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := CleanupUploadSessions(time.Now())
+		if err != nil {
+			logger.WithError(err).Error("Failed to clean up upload sessions")
+		} else if removed > 0 {
+			logger.WithField("removed", removed).Info("✓ Expired upload sessions removed")
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package streamer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"tv_streamer/modules/streamer/models"
+)
+
+func TestAddByteRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		ranges     []ByteRange
+		start, end int64
+		want       []ByteRange
+	}{
+		{name: "first range", ranges: nil, start: 0, end: 100, want: []ByteRange{{0, 100}}},
+		{name: "appended range merges", ranges: []ByteRange{{0, 100}}, start: 100, end: 200, want: []ByteRange{{0, 200}}},
+		{name: "range before merges", ranges: []ByteRange{{100, 200}}, start: 0, end: 100, want: []ByteRange{{0, 200}}},
+		{name: "gap stays", ranges: []ByteRange{{0, 100}}, start: 200, end: 300, want: []ByteRange{{0, 100}, {200, 300}}},
+		{name: "out of order stays sorted", ranges: []ByteRange{{200, 300}}, start: 0, end: 100, want: []ByteRange{{0, 100}, {200, 300}}},
+		{name: "gap filled", ranges: []ByteRange{{0, 100}, {200, 300}}, start: 100, end: 200, want: []ByteRange{{0, 300}}},
+		{name: "overlap extends", ranges: []ByteRange{{0, 100}}, start: 50, end: 150, want: []ByteRange{{0, 150}}},
+		{name: "contained range", ranges: []ByteRange{{0, 300}}, start: 100, end: 200, want: []ByteRange{{0, 300}}},
+		{name: "duplicate range", ranges: []ByteRange{{0, 100}, {200, 300}}, start: 200, end: 300, want: []ByteRange{{0, 100}, {200, 300}}},
+		{name: "covers several ranges", ranges: []ByteRange{{10, 20}, {30, 40}, {50, 60}}, start: 0, end: 55, want: []ByteRange{{0, 60}}},
+		{name: "between ranges without touching", ranges: []ByteRange{{0, 100}, {300, 400}}, start: 150, end: 250, want: []ByteRange{{0, 100}, {150, 250}, {300, 400}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := addByteRange(tt.ranges, tt.start, tt.end)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addByteRange(%v, %d, %d) = %v, want %v", tt.ranges, tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingUploadRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		received string
+		total    int64
+		want     []ByteRange
+		wantErr  bool
+	}{
+		{name: "nothing received", received: "[]", total: 300, want: []ByteRange{{0, 300}}},
+		{name: "complete", received: "[[0,300]]", total: 300, want: []ByteRange{}},
+		{name: "start missing", received: "[[100,300]]", total: 300, want: []ByteRange{{0, 100}}},
+		{name: "end missing", received: "[[0,100]]", total: 300, want: []ByteRange{{100, 300}}},
+		{name: "gaps", received: "[[0,100],[150,200]]", total: 300, want: []ByteRange{{100, 150}, {200, 300}}},
+		{name: "empty file", received: "[]", total: 0, want: []ByteRange{}},
+		{name: "invalid ranges", received: "not json", total: 300, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &models.UploadSession{SessionID: "test", ReceivedRanges: tt.received, TotalSize: tt.total}
+			got, err := MissingUploadRanges(session)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MissingUploadRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MissingUploadRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChunkChecksum(t *testing.T) {
+	data := []byte("chunk data")
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  bool
+	}{
+		{name: "no checksum", checksum: ""},
+		{name: "matching", checksum: checksum},
+		{name: "upper case", checksum: strings.ToUpper(checksum)},
+		{name: "mismatch", checksum: strings.Repeat("0", 64), wantErr: true},
+		{name: "truncated", checksum: checksum[:32], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyChunkChecksum(data, tt.checksum); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyChunkChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AdPodResponse is an ad break together with its on-air state
+type AdPodResponse struct {
+	ID                     int64  `json:"id"`
+	ChannelID              int64  `json:"channel_id"`
+	AdCount                int    `json:"ad_count"`
+	PlannedDurationSeconds int64  `json:"planned_duration_seconds"`
+	DurationSeconds        int64  `json:"duration_seconds"`
+	CreatedAt              int64  `json:"created_at"`
+	StartedAt              int64  `json:"started_at"`
+	EndedAt                int64  `json:"ended_at"`
+	State                  string `json:"state"`
+}
+
+func enrichAdPod(pod *models.AdPod) AdPodResponse {
+	state := "queued"
+	switch {
+	case pod.IsEnded():
+		state = "ended"
+	case pod.IsStarted():
+		state = "on_air"
+	}
+
+	return AdPodResponse{
+		ID:                     pod.ID,
+		ChannelID:              pod.ChannelID,
+		AdCount:                pod.AdCount,
+		PlannedDurationSeconds: pod.PlannedDurationSeconds,
+		DurationSeconds:        pod.DurationSeconds(),
+		CreatedAt:              pod.CreatedAt,
+		StartedAt:              pod.StartedAt,
+		EndedAt:                pod.EndedAt,
+		State:                  state,
+	}
+}
+
+// handleAdBreak queues an ad pod at the front of the queue
+func handleAdBreak(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAdBreak",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	var req struct {
+		FileIDs         []string `json:"file_ids" binding:"required"`
+		PlannedDuration int64    `json:"planned_duration"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: file_ids is required",
+		})
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"ad_count":         len(req.FileIDs),
+		"planned_duration": req.PlannedDuration,
+	}).Info("Received request to queue ad break")
+
+	pod, err := streamer.CreateAdPod(channelID, req.FileIDs, req.PlannedDuration)
+	if err != nil {
+		logger.WithError(err).Error("Failed to queue ad break")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("pod_id", pod.ID).Info("✓ Successfully queued ad break")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Ad break queued",
+		"pod":     enrichAdPod(pod),
+	})
+}
+
+// handleAdPodList returns the most recent ad pods of a channel
+func handleAdPodList(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAdPodList",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	pods, err := streamer.GetAdPods(channelID, limit)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get ad pods")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	enrichedPods := make([]AdPodResponse, len(pods))
+	for i, pod := range pods {
+		enrichedPods[i] = enrichAdPod(&pod)
+	}
+
+	logger.WithField("pod_count", len(pods)).Debug("✓ Successfully retrieved ad pods")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"pods":    enrichedPods,
+		"count":   len(enrichedPods),
+	})
+}
@@ -0,0 +1,162 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handleStreamingProfileGet returns the active streaming profile
+func handleStreamingProfileGet(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleStreamingProfileGet",
+		"client_ip": c.ClientIP(),
+	})
+
+	logger.Debug("Received request to get streaming profile")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"profile": streamer.ActiveStreamingProfile(),
+	})
+}
+
+// handleStreamingProfileApply applies a new streaming profile and restarts the
+// pipeline of every running channel. Fields missing from the body keep their
+// current value.
+func handleStreamingProfileApply(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleStreamingProfileApply",
+		"client_ip": c.ClientIP(),
+	})
+
+	profile := streamer.ActiveStreamingProfile()
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"mode":             profile.Mode,
+		"hls_segment_time": profile.HlsSegmentTime,
+		"hls_list_size":    profile.HlsListSize,
+	}).Info("Received request to apply streaming profile")
+
+	results, err := streamer.ApplyStreamingProfile(profile)
+	if err != nil {
+		logger.WithError(err).Warn("Streaming profile rejected")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	channelIDs := make([]int64, 0, len(results))
+	for channelID := range results {
+		channelIDs = append(channelIDs, channelID)
+	}
+	sort.Slice(channelIDs, func(i, j int) bool { return channelIDs[i] < channelIDs[j] })
+
+	success := true
+	channels := make([]gin.H, 0, len(channelIDs))
+	for _, channelID := range channelIDs {
+		entry := gin.H{
+			"channel_id": channelID,
+			"running":    streamer.IsChannelRunning(channelID),
+		}
+		if err := results[channelID]; err != nil {
+			success = false
+			entry["error"] = err.Error()
+		}
+		channels = append(channels, entry)
+	}
+
+	status := http.StatusOK
+	message := "Streaming profile applied"
+	if !success {
+		status = http.StatusInternalServerError
+		message = "Streaming profile applied, but some channels failed to restart"
+	}
+
+	logger.WithField("channels", len(channels)).Info("✓ Streaming profile applied")
+	c.JSON(status, gin.H{
+		"success":  success,
+		"message":  message,
+		"profile":  streamer.ActiveStreamingProfile(),
+		"channels": channels,
+	})
+}
+
+// handleConsistencyReport returns the report of the last library consistency
+// check
+func handleConsistencyReport(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleConsistencyReport",
+		"client_ip": c.ClientIP(),
+	})
+
+	report := streamer.LastConsistencyReport()
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "No consistency check has run yet",
+		})
+		return
+	}
+
+	logger.Debug("✓ Successfully retrieved consistency report")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// handleConsistencyCheck runs a library consistency check, with fix=true the
+// issues found are repaired
+func handleConsistencyCheck(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleConsistencyCheck",
+		"client_ip": c.ClientIP(),
+	})
+
+	fix := c.Query("fix") == "true" || c.Query("fix") == "1"
+	logger = logger.WithField("fix", fix)
+	logger.Info("Received request to check library consistency")
+
+	report, err := streamer.CheckLibraryConsistency(fix)
+	if err != nil {
+		logger.WithError(err).Warn("Library consistency check failed")
+		status := http.StatusInternalServerError
+		if errors.Is(err, streamer.ErrConsistencyCheckRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"issues": len(report.Issues),
+		"fixed":  report.Fixed,
+	}).Info("✓ Library consistency checked")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"report":  report,
+	})
+}
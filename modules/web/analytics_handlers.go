@@ -0,0 +1,233 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// analyticsDefaultDays is the range of the analytics without from and to
+	analyticsDefaultDays = 7
+
+	// analyticsDefaultTop is the number of files of the top list without limit
+	analyticsDefaultTop = 10
+)
+
+// PlayStatsResponse is the daily playback summary of a file enriched with
+// filepath
+type PlayStatsResponse struct {
+	Day            string  `json:"day"`
+	FileID         string  `json:"file_id"`
+	FilePath       string  `json:"filepath"`
+	IsAd           int     `json:"is_ad"`
+	Plays          int64   `json:"plays"`
+	Skips          int64   `json:"skips"`
+	Interruptions  int64   `json:"interruptions"`
+	AirtimeSeconds int64   `json:"airtime_seconds"`
+	SkipRate       float64 `json:"skip_rate"`
+}
+
+func enrichPlayStats(stats *models.PlayStatsDaily) PlayStatsResponse {
+	filePath, _ := streamer.GetFilePathByID(stats.FileID)
+	response := PlayStatsResponse{
+		Day:            stats.Day,
+		FileID:         stats.FileID,
+		FilePath:       filePath,
+		IsAd:           stats.IsAd,
+		Plays:          stats.Plays,
+		Skips:          stats.Skips,
+		Interruptions:  stats.Interruptions,
+		AirtimeSeconds: stats.AirtimeSeconds,
+	}
+	if stats.Plays > 0 {
+		response.SkipRate = float64(stats.Skips) / float64(stats.Plays)
+	}
+	return response
+}
+
+// parseAnalyticsRange reads the report range and converts it to the days of
+// the summary tables. On failure the error response has already been written.
+func parseAnalyticsRange(c *gin.Context) (string, string, bool) {
+	from, until, ok := parseReportRange(c, analyticsDefaultDays)
+	if !ok {
+		return "", "", false
+	}
+	return from.In(time.Local).Format("2006-01-02"), until.Add(-time.Second).In(time.Local).Format("2006-01-02"), true
+}
+
+// handleAnalyticsSummary returns the playback totals of a channel with the
+// skip rate and the ad/content ratio
+func handleAnalyticsSummary(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAnalyticsSummary",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	fromDay, toDay, ok := parseAnalyticsRange(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"channel_id": channelID,
+		"from":       fromDay,
+		"to":         toDay,
+	})
+
+	summary, err := streamer.GetPlaybackSummary(channelID, fromDay, toDay)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get playback summary")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Debug("✓ Successfully retrieved playback summary")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"from":    fromDay,
+		"to":      toDay,
+		"summary": summary,
+	})
+}
+
+// handleAnalyticsDaily returns the airtime and skips per file and day
+func handleAnalyticsDaily(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAnalyticsDaily",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	fromDay, toDay, ok := parseAnalyticsRange(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"channel_id": channelID,
+		"from":       fromDay,
+		"to":         toDay,
+	})
+
+	stats, err := streamer.GetDailyPlayStats(channelID, fromDay, toDay)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get daily play stats")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	enrichedStats := make([]PlayStatsResponse, len(stats))
+	for i := range stats {
+		enrichedStats[i] = enrichPlayStats(&stats[i])
+	}
+
+	logger.WithField("row_count", len(stats)).Debug("✓ Successfully retrieved daily play stats")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"from":    fromDay,
+		"to":      toDay,
+		"days":    enrichedStats,
+		"count":   len(enrichedStats),
+	})
+}
+
+// handleAnalyticsFiles returns the playback summary per file, ordered by
+// airtime, plays or skip rate
+func handleAnalyticsFiles(c *gin.Context) {
+	respondFileStats(c, "handleAnalyticsFiles", c.Query("sort"), 0)
+}
+
+// handleAnalyticsTop returns the most-aired files
+func handleAnalyticsTop(c *gin.Context) {
+	respondFileStats(c, "handleAnalyticsTop", streamer.StatsOrderAirtime, analyticsDefaultTop)
+}
+
+// respondFileStats writes the per-file statistics in the given order. The
+// limit query parameter overrides defaultLimit, 0 lists every file.
+func respondFileStats(c *gin.Context, handler, order string, defaultLimit int) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   handler,
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	fromDay, toDay, ok := parseAnalyticsRange(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid limit",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"channel_id": channelID,
+		"from":       fromDay,
+		"to":         toDay,
+		"sort":       order,
+		"limit":      limit,
+	})
+
+	stats, err := streamer.GetFileStats(channelID, fromDay, toDay, order, limit)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get file stats")
+		status := http.StatusInternalServerError
+		if strings.HasPrefix(err.Error(), "invalid order") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if stats == nil {
+		stats = []streamer.FileStats{}
+	}
+
+	logger.WithField("file_count", len(stats)).Debug("✓ Successfully retrieved file stats")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"from":    fromDay,
+		"to":      toDay,
+		"files":   stats,
+		"count":   len(stats),
+	})
+}
@@ -0,0 +1,276 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AsRunResponse is an as-run entry with its times also in RFC 3339
+type AsRunResponse struct {
+	ID                     int64  `json:"id"`
+	ChannelID              int64  `json:"channel_id"`
+	FileID                 string `json:"file_id"`
+	FilePath               string `json:"filepath"`
+	IsAd                   int    `json:"is_ad"`
+	PodID                  int64  `json:"pod_id"`
+	CampaignID             int64  `json:"campaign_id"`
+	PlannedStart           int64  `json:"planned_start"`
+	PlannedStartTime       string `json:"planned_start_time"`
+	ActualStart            int64  `json:"actual_start"`
+	ActualStartTime        string `json:"actual_start_time"`
+	ActualEnd              int64  `json:"actual_end"`
+	ActualEndTime          string `json:"actual_end_time"`
+	StartDeviationSeconds  int64  `json:"start_deviation_seconds"`
+	PlannedDurationSeconds int64  `json:"planned_duration_seconds"`
+	DurationSeconds        int64  `json:"duration_seconds"`
+	SkipReason             string `json:"skip_reason"`
+	OnAir                  bool   `json:"on_air"`
+}
+
+func enrichAsRun(entry *models.AsRunEntry) AsRunResponse {
+	return AsRunResponse{
+		ID:                     entry.ID,
+		ChannelID:              entry.ChannelID,
+		FileID:                 entry.FileID,
+		FilePath:               entry.FilePath,
+		IsAd:                   entry.IsAd,
+		PodID:                  entry.PodID,
+		CampaignID:             entry.CampaignID,
+		PlannedStart:           entry.PlannedStart,
+		PlannedStartTime:       formatUnix(entry.PlannedStart),
+		ActualStart:            entry.ActualStart,
+		ActualStartTime:        formatUnix(entry.ActualStart),
+		ActualEnd:              entry.ActualEnd,
+		ActualEndTime:          formatUnix(entry.ActualEnd),
+		StartDeviationSeconds:  entry.StartDeviationSeconds(),
+		PlannedDurationSeconds: entry.PlannedDurationSeconds,
+		DurationSeconds:        entry.DurationSeconds,
+		SkipReason:             entry.SkipReason,
+		OnAir:                  entry.IsOnAir(),
+	}
+}
+
+// formatUnix formats a Unix timestamp in RFC 3339, empty for zero
+func formatUnix(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).Format(time.RFC3339)
+}
+
+// parseReportRange reads the from and to query parameters. Both accept a day
+// (YYYY-MM-DD, inclusive) or an RFC 3339 time. Without them the range covers
+// the last defaultDays days up to today. On failure the error response has
+// already been written.
+func parseReportRange(c *gin.Context, defaultDays int) (time.Time, time.Time, bool) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	parse := func(name string, endOfDay bool) (time.Time, error) {
+		value := c.Query(name)
+		if value == "" {
+			if endOfDay {
+				return today.AddDate(0, 0, 1), nil
+			}
+			return today.AddDate(0, 0, 1-defaultDays), nil
+		}
+		if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+			if endOfDay {
+				return day.AddDate(0, 0, 1), nil
+			}
+			return day, nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s: use YYYY-MM-DD or RFC 3339", name)
+		}
+		return t, nil
+	}
+
+	from, err := parse("from", false)
+	if err == nil {
+		var until time.Time
+		if until, err = parse("to", true); err == nil {
+			if !until.After(from) {
+				err = fmt.Errorf("to must be after from")
+			} else {
+				return from, until, true
+			}
+		}
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+	return time.Time{}, time.Time{}, false
+}
+
+// wantsCSV returns true if the client asked for a CSV export
+func wantsCSV(c *gin.Context) bool {
+	return c.Query("format") == "csv"
+}
+
+// writeCSV sends rows as a CSV attachment
+func writeCSV(c *gin.Context, filename string, rows [][]string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.WriteAll(rows)
+}
+
+// handleAsRunLog exports what went on air between two dates
+func handleAsRunLog(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAsRunLog",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	from, until, ok := parseReportRange(c, 1)
+	if !ok {
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"channel_id": channelID,
+		"from":       from.Format(time.RFC3339),
+		"to":         until.Format(time.RFC3339),
+	})
+
+	entries, err := streamer.GetAsRunLog(channelID, from, until)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get as-run log")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("entry_count", len(entries)).Debug("✓ Successfully retrieved as-run log")
+
+	if wantsCSV(c) {
+		rows := [][]string{{
+			"id", "channel_id", "file_id", "filepath", "is_ad", "pod_id", "campaign_id",
+			"planned_start", "actual_start", "actual_end", "start_deviation_seconds",
+			"planned_duration_seconds", "duration_seconds", "skip_reason",
+		}}
+		for _, entry := range entries {
+			rows = append(rows, []string{
+				strconv.FormatInt(entry.ID, 10),
+				strconv.FormatInt(entry.ChannelID, 10),
+				entry.FileID,
+				entry.FilePath,
+				strconv.Itoa(entry.IsAd),
+				strconv.FormatInt(entry.PodID, 10),
+				strconv.FormatInt(entry.CampaignID, 10),
+				formatUnix(entry.PlannedStart),
+				formatUnix(entry.ActualStart),
+				formatUnix(entry.ActualEnd),
+				strconv.FormatInt(entry.StartDeviationSeconds(), 10),
+				strconv.FormatInt(entry.PlannedDurationSeconds, 10),
+				strconv.FormatInt(entry.DurationSeconds, 10),
+				entry.SkipReason,
+			})
+		}
+		writeCSV(c, fmt.Sprintf("as-run_%d_%s.csv", channelID, from.Format("20060102")), rows)
+		return
+	}
+
+	enrichedEntries := make([]AsRunResponse, len(entries))
+	for i := range entries {
+		enrichedEntries[i] = enrichAsRun(&entries[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"from":    from.Format(time.RFC3339),
+		"to":      until.Format(time.RFC3339),
+		"entries": enrichedEntries,
+		"count":   len(enrichedEntries),
+	})
+}
+
+// handleAsRunDaily returns the airtime per file and day between two dates
+func handleAsRunDaily(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAsRunDaily",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	from, until, ok := parseReportRange(c, 1)
+	if !ok {
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"channel_id": channelID,
+		"from":       from.Format(time.RFC3339),
+		"to":         until.Format(time.RFC3339),
+	})
+
+	rows, err := streamer.GetAsRunDaily(channelID, from, until)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get as-run daily report")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("row_count", len(rows)).Debug("✓ Successfully built as-run daily report")
+
+	if wantsCSV(c) {
+		records := [][]string{{
+			"day", "file_id", "filepath", "is_ad", "plays", "skips", "incomplete", "airtime_seconds",
+		}}
+		for _, row := range rows {
+			records = append(records, []string{
+				row.Day,
+				row.FileID,
+				row.FilePath,
+				strconv.Itoa(row.IsAd),
+				strconv.FormatInt(row.Plays, 10),
+				strconv.FormatInt(row.Skips, 10),
+				strconv.FormatInt(row.Incomplete, 10),
+				strconv.FormatInt(row.AirtimeSeconds, 10),
+			})
+		}
+		writeCSV(c, fmt.Sprintf("as-run-daily_%d_%s.csv", channelID, from.Format("20060102")), records)
+		return
+	}
+
+	if rows == nil {
+		rows = []streamer.AsRunDailyRow{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"from":    from.Format(time.RFC3339),
+		"to":      until.Format(time.RFC3339),
+		"days":    rows,
+		"count":   len(rows),
+	})
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// identityKey is the gin context key of the authenticated identity
+const identityKey = "identity"
+
+// Role requirements of the routes
+var (
+	allowViewer   = requireRole(auth.RoleViewer)
+	allowOperator = requireRole(auth.RoleOperator)
+	allowAdmin    = requireRole(auth.RoleAdmin)
+)
+
+// credentialFromRequest returns the API key or access token of a request:
+// "Authorization: Bearer ...", "X-API-Key: ..." or, for WebSocket handshakes
+// where browsers can't set headers, the access_token query parameter
+func credentialFromRequest(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		if scheme, credential, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(credential)
+		}
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		return c.Query("access_token")
+	}
+	return ""
+}
+
+// requestLogger is gin's request log without the access_token query
+// parameter, access tokens must not end up in log files
+func requestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactAccessToken(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// redactAccessToken replaces the access_token query parameter of a request URI
+func redactAccessToken(uri string) string {
+	path, rawQuery, ok := strings.Cut(uri, "?")
+	if !ok || !strings.Contains(rawQuery, "access_token") {
+		return uri
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Can't tell where the token ends, drop the whole query
+		return path + "?REDACTED"
+	}
+	if query.Has("access_token") {
+		query.Set("access_token", "REDACTED")
+	}
+	return path + "?" + query.Encode()
+}
+
+// authenticate rejects requests without valid credentials and stores the
+// identity of the others in the context
+func authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !helpers.GetConfig().Auth.Enabled {
+			c.Set(identityKey, auth.DisabledIdentity())
+			c.Next()
+			return
+		}
+
+		logger := logs.GetLogger().WithFields(logrus.Fields{
+			"module":    "web",
+			"handler":   "authenticate",
+			"client_ip": c.ClientIP(),
+			"path":      c.Request.URL.Path,
+		})
+
+		credential := credentialFromRequest(c)
+		if credential == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="tv_streamer"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Authentication required",
+			})
+			return
+		}
+
+		identity, err := auth.Authenticate(credential)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				status = http.StatusUnauthorized
+				c.Header("WWW-Authenticate", `Bearer realm="tv_streamer", error="invalid_token"`)
+				logger.Warn("Rejected invalid credentials")
+			} else {
+				logger.WithError(err).Error("Failed to authenticate request")
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
+
+// requireRole rejects requests of identities below role
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := currentIdentity(c)
+		if identity == nil || !identity.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "This requires the " + role + " role",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentIdentity returns the identity stored by authenticate
+func currentIdentity(c *gin.Context) *auth.Identity {
+	value, ok := c.Get(identityKey)
+	if !ok {
+		return nil
+	}
+	identity, _ := value.(*auth.Identity)
+	return identity
+}
+
+// isAllowedOrigin returns true if origin is listed in app.allowed_origins
+func isAllowedOrigin(origin string) bool {
+	for _, allowed := range helpers.GetConfig().App.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebSocketOrigin accepts clients without an Origin header (not a
+// browser), pages served by this server and app.allowed_origins
+func checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || isAllowedOrigin(origin)
+}
@@ -0,0 +1,397 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth"
+	"tv_streamer/modules/auth/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UserResponse is a user of the API
+type UserResponse struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Disabled  bool   `json:"disabled"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func enrichUser(user *models.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Disabled:  user.IsDisabled(),
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// APIKeyResponse is an API key without the key itself
+type APIKeyResponse struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"`
+	Name       string `json:"name"`
+	KeyPrefix  string `json:"key_prefix"`
+	CreatedAt  int64  `json:"created_at"`
+	LastUsedAt int64  `json:"last_used_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+	RevokedAt  int64  `json:"revoked_at"`
+	Valid      bool   `json:"valid"`
+}
+
+func enrichAPIKey(key *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		UserID:     key.UserID,
+		Name:       key.Name,
+		KeyPrefix:  key.KeyPrefix,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		ExpiresAt:  key.ExpiresAt,
+		RevokedAt:  key.RevokedAt,
+		Valid:      key.IsValid(time.Now()),
+	}
+}
+
+// authErrorStatus maps auth errors to HTTP status codes
+func authErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, auth.ErrUserNotFound), errors.Is(err, auth.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, auth.ErrLastAdmin):
+		return http.StatusConflict
+	case strings.HasPrefix(err.Error(), "failed to"), strings.HasPrefix(err.Error(), "database error"):
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// parseIDParam reads a numeric route parameter. On failure the error
+// response has already been written.
+func parseIDParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid " + name,
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// handleAuthMe returns the identity of the request
+func handleAuthMe(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"identity": currentIdentity(c),
+	})
+}
+
+// handleAuthToken issues an access token for the API key of the request
+func handleAuthToken(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAuthToken",
+		"client_ip": c.ClientIP(),
+	})
+
+	identity := currentIdentity(c)
+	token, expiresAt, err := auth.IssueToken(identity)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to issue access token")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_at":   expiresAt,
+	})
+}
+
+// handleUserList returns all users
+func handleUserList(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleUserList",
+		"client_ip": c.ClientIP(),
+	})
+
+	users, err := auth.GetUsers()
+	if err != nil {
+		logger.WithError(err).Error("Failed to get users")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response := make([]UserResponse, len(users))
+	for i := range users {
+		response[i] = enrichUser(&users[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"users":   response,
+		"count":   len(response),
+	})
+}
+
+// handleUserCreate creates a user
+func handleUserCreate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleUserCreate",
+		"client_ip": c.ClientIP(),
+	})
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Role     string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: username and role are required",
+		})
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"username": req.Username,
+		"role":     req.Role,
+	}).Info("Received request to create user")
+
+	user, err := auth.CreateUser(req.Username, req.Role)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to create user")
+		c.JSON(authErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("user_id", user.ID).Info("✓ Successfully created user")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User created",
+		"user":    enrichUser(user),
+	})
+}
+
+// handleUserUpdate changes the role of a user or disables it
+func handleUserUpdate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleUserUpdate",
+		"client_ip": c.ClientIP(),
+	})
+
+	userID, ok := parseIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role     *string `json:"role"`
+		Disabled *bool   `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	logger = logger.WithField("user_id", userID)
+	logger.Info("Received request to update user")
+
+	user, err := auth.UpdateUser(userID, req.Role, req.Disabled)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to update user")
+		c.JSON(authErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("✓ Successfully updated user")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User updated",
+		"user":    enrichUser(user),
+	})
+}
+
+// handleUserDelete deletes a user and its API keys
+func handleUserDelete(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleUserDelete",
+		"client_ip": c.ClientIP(),
+	})
+
+	userID, ok := parseIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	logger = logger.WithField("user_id", userID)
+	logger.Info("Received request to delete user")
+
+	if err := auth.DeleteUser(userID); err != nil {
+		logger.WithError(err).Warn("Failed to delete user")
+		c.JSON(authErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("✓ Successfully deleted user")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User deleted",
+	})
+}
+
+// handleAPIKeyList returns the API keys of a user
+func handleAPIKeyList(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAPIKeyList",
+		"client_ip": c.ClientIP(),
+	})
+
+	userID, ok := parseIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	keys, err := auth.GetAPIKeys(userID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get api keys")
+		c.JSON(authErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response := make([]APIKeyResponse, len(keys))
+	for i := range keys {
+		response[i] = enrichAPIKey(&keys[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"keys":    response,
+		"count":   len(response),
+	})
+}
+
+// handleAPIKeyCreate creates an API key for a user. The key is only part of
+// this response.
+func handleAPIKeyCreate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAPIKeyCreate",
+		"client_ip": c.ClientIP(),
+	})
+
+	userID, ok := parseIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name          string `json:"name"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"name":    req.Name,
+	})
+	logger.Info("Received request to create api key")
+
+	key, apiKey, err := auth.CreateAPIKey(userID, req.Name, time.Duration(req.ExpiresInDays)*24*time.Hour)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to create api key")
+		c.JSON(authErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("key_id", apiKey.ID).Info("✓ Successfully created api key")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "API key created, it is not shown again",
+		"api_key": key,
+		"key":     enrichAPIKey(apiKey),
+	})
+}
+
+// handleAPIKeyRevoke revokes an API key and the access tokens issued for it
+func handleAPIKeyRevoke(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleAPIKeyRevoke",
+		"client_ip": c.ClientIP(),
+	})
+
+	keyID, ok := parseIDParam(c, "key_id")
+	if !ok {
+		return
+	}
+
+	logger = logger.WithField("key_id", keyID)
+	logger.Info("Received request to revoke api key")
+
+	apiKey, err := auth.RevokeAPIKey(keyID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to revoke api key")
+		c.JSON(authErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("✓ Successfully revoked api key")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "API key revoked",
+		"key":     enrichAPIKey(apiKey),
+	})
+}
@@ -0,0 +1,216 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tv_streamer/helpers"
+	"tv_streamer/modules/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useTestConfig loads a config with authentication enabled. The config is
+// read once per test binary, every test loads the same one.
+func useTestConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	config := "auth:\n  enabled: true\n  token_secret: \"test-secret\"\n  token_ttl_minutes: 60\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	helpers.GetConfig()
+}
+
+// useTestDB points the database at a fresh directory for the test
+func useTestDB(t *testing.T) {
+	t.Helper()
+	helpers.CloseXORM()
+	t.Setenv("DB_PATH", t.TempDir())
+	helpers.GetXORM()
+	t.Cleanup(func() { helpers.CloseXORM() })
+}
+
+// newTestKey creates a user with the given role and returns an API key for it
+func newTestKey(t *testing.T, role string) string {
+	t.Helper()
+	user, err := auth.CreateUser(role, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := auth.CreateAPIKey(user.ID, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestCredentialFromRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		want    string
+	}{
+		{name: "bearer", target: "/api/files", headers: map[string]string{"Authorization": "Bearer tvs_key"}, want: "tvs_key"},
+		{name: "bearer is case-insensitive", target: "/api/files", headers: map[string]string{"Authorization": "bearer  token "}, want: "token"},
+		{name: "api key header", target: "/api/files", headers: map[string]string{"X-API-Key": "tvs_key"}, want: "tvs_key"},
+		{name: "authorization wins", target: "/api/files", headers: map[string]string{"Authorization": "Bearer token", "X-API-Key": "tvs_key"}, want: "token"},
+		{name: "other scheme", target: "/api/files", headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}},
+		{name: "query ignored without upgrade", target: "/api/files?access_token=token"},
+		{
+			name:    "query of a websocket handshake",
+			target:  "/api/ws?access_token=token",
+			headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"},
+			want:    "token",
+		},
+		{name: "none", target: "/api/files"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, value := range tt.headers {
+				c.Request.Header.Set(name, value)
+			}
+
+			if got := credentialFromRequest(c); got != tt.want {
+				t.Errorf("credentialFromRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactAccessToken(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "/api/ws", want: "/api/ws"},
+		{uri: "/api/files?limit=10", want: "/api/files?limit=10"},
+		{uri: "/api/ws?access_token=eyJ.abc.def", want: "/api/ws?access_token=REDACTED"},
+		{uri: "/api/ws?channel=2&access_token=eyJ.abc.def", want: "/api/ws?access_token=REDACTED&channel=2"},
+		{uri: "/api/ws?access_token=a&access_token=b", want: "/api/ws?access_token=REDACTED"},
+		{uri: "/api/ws?access_token=a;b", want: "/api/ws?REDACTED"},
+		{uri: "/api/ws?note=access_token", want: "/api/ws?note=access_token"},
+	}
+
+	for _, tt := range tests {
+		if got := redactAccessToken(tt.uri); got != tt.want {
+			t.Errorf("redactAccessToken(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLoggerRedactsAccessToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var out bytes.Buffer
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = &out
+	t.Cleanup(func() { gin.DefaultWriter = defaultWriter })
+
+	router := gin.New()
+	router.Use(requestLogger())
+	router.GET("/api/ws", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/ws?access_token=secret-token", nil))
+
+	if strings.Contains(out.String(), "secret-token") {
+		t.Errorf("request log contains the access token: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "/api/ws?access_token=REDACTED") {
+		t.Errorf("request log misses the redacted path: %s", out.String())
+	}
+}
+
+func TestRouteRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestConfig(t)
+	useTestDB(t)
+
+	keys := map[string]string{
+		auth.RoleViewer:   newTestKey(t, auth.RoleViewer),
+		auth.RoleOperator: newTestKey(t, auth.RoleOperator),
+		auth.RoleAdmin:    newTestKey(t, auth.RoleAdmin),
+	}
+
+	router := gin.New()
+	registerAPIRoutes(router.Group("/api", authenticate()))
+
+	routes := []struct {
+		method string
+		path   string
+		role   string
+	}{
+		{method: http.MethodGet, path: "/api/auth/me", role: auth.RoleViewer},
+		{method: http.MethodPut, path: "/api/files/unknown/description", role: auth.RoleOperator},
+		{method: http.MethodPost, path: "/api/stream/next", role: auth.RoleOperator},
+		{method: http.MethodPost, path: "/api/channels/1/stop", role: auth.RoleOperator},
+		{method: http.MethodGet, path: "/api/auth/users", role: auth.RoleAdmin},
+		{method: http.MethodGet, path: "/api/admin/consistency", role: auth.RoleAdmin},
+		{method: http.MethodDelete, path: "/api/files/unknown", role: auth.RoleAdmin},
+	}
+
+	// Handlers of routes that change the channels are not run
+	runs := map[string]bool{
+		"/api/auth/me":                   true,
+		"/api/files/unknown/description": true,
+		"/api/auth/users":                true,
+		"/api/admin/consistency":         true,
+	}
+
+	for _, route := range routes {
+		for _, role := range []string{auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin} {
+			allowed := (&auth.Identity{Role: role}).HasRole(route.role)
+			if allowed && !runs[route.path] {
+				continue
+			}
+
+			t.Run(route.method+" "+route.path+" as "+role, func(t *testing.T) {
+				recorder := httptest.NewRecorder()
+				request := httptest.NewRequest(route.method, route.path, nil)
+				request.Header.Set("X-API-Key", keys[role])
+				router.ServeHTTP(recorder, request)
+
+				if denied := recorder.Code == http.StatusForbidden; denied == allowed {
+					t.Errorf("status = %d, want the %s role to be allowed: %v", recorder.Code, role, allowed)
+				}
+			})
+		}
+	}
+
+	unauthenticated := []struct {
+		name    string
+		target  string
+		headers map[string]string
+	}{
+		{name: "no credentials", target: "/api/auth/me"},
+		{name: "unknown key", target: "/api/auth/me", headers: map[string]string{"X-API-Key": "tvs_unknown"}},
+		{name: "invalid token", target: "/api/auth/me", headers: map[string]string{"Authorization": "Bearer not-a-token"}},
+		{name: "query token without upgrade", target: "/api/auth/me?access_token=" + keys[auth.RoleAdmin]},
+	}
+
+	for _, tt := range unauthenticated {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, value := range tt.headers {
+				request.Header.Set(name, value)
+			}
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
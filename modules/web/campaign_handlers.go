@@ -0,0 +1,328 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// AdCampaignCreativeResponse is a campaign creative enriched with filepath
+type AdCampaignCreativeResponse struct {
+	ID       int64  `json:"id"`
+	FileID   string `json:"file_id"`
+	FilePath string `json:"filepath"`
+}
+
+// AdCampaignResponse is a campaign together with its creatives and delivery
+type AdCampaignResponse struct {
+	ID                int64                        `json:"id"`
+	ChannelID         int64                        `json:"channel_id"`
+	Name              string                       `json:"name"`
+	StartDate         string                       `json:"start_date"`
+	EndDate           string                       `json:"end_date"`
+	DailyCap          int                          `json:"daily_cap"`
+	MinSpacingMinutes int                          `json:"min_spacing_minutes"`
+	Priority          int                          `json:"priority"`
+	Enabled           int                          `json:"enabled"`
+	CreatedAt         int64                        `json:"created_at"`
+	InFlight          bool                         `json:"in_flight"`
+	Stats             streamer.AdCampaignStats     `json:"stats"`
+	Creatives         []AdCampaignCreativeResponse `json:"creatives"`
+}
+
+// adCampaignRequest is the body of the create and update endpoints
+type adCampaignRequest struct {
+	Name              string   `json:"name" binding:"required"`
+	FileIDs           []string `json:"file_ids" binding:"required"`
+	StartDate         string   `json:"start_date" binding:"required"`
+	EndDate           string   `json:"end_date"`
+	DailyCap          int      `json:"daily_cap"`
+	MinSpacingMinutes int      `json:"min_spacing_minutes"`
+	Priority          int      `json:"priority"`
+	Enabled           *bool    `json:"enabled"`
+}
+
+// campaign builds the campaign of the request, campaigns are enabled unless
+// the request says otherwise
+func (r *adCampaignRequest) campaign(channelID int64) *models.AdCampaign {
+	enabled := 1
+	if r.Enabled != nil && !*r.Enabled {
+		enabled = 0
+	}
+	return &models.AdCampaign{
+		ChannelID:         channelID,
+		Name:              r.Name,
+		StartDate:         r.StartDate,
+		EndDate:           r.EndDate,
+		DailyCap:          r.DailyCap,
+		MinSpacingMinutes: r.MinSpacingMinutes,
+		Priority:          r.Priority,
+		Enabled:           enabled,
+	}
+}
+
+func enrichAdCampaign(campaign *models.AdCampaign) AdCampaignResponse {
+	now := time.Now()
+
+	response := AdCampaignResponse{
+		ID:                campaign.ID,
+		ChannelID:         campaign.ChannelID,
+		Name:              campaign.Name,
+		StartDate:         campaign.StartDate,
+		EndDate:           campaign.EndDate,
+		DailyCap:          campaign.DailyCap,
+		MinSpacingMinutes: campaign.MinSpacingMinutes,
+		Priority:          campaign.Priority,
+		Enabled:           campaign.Enabled,
+		CreatedAt:         campaign.CreatedAt,
+		InFlight:          campaign.InFlight(now.Format("2006-01-02")),
+		Creatives:         []AdCampaignCreativeResponse{},
+	}
+
+	response.Stats, _ = streamer.GetAdCampaignStats(campaign.ID, now)
+
+	creatives, _ := streamer.GetAdCampaignCreatives(campaign.ID)
+	for _, creative := range creatives {
+		filePath, _ := streamer.GetFilePathByID(creative.FileID)
+		response.Creatives = append(response.Creatives, AdCampaignCreativeResponse{
+			ID:       creative.ID,
+			FileID:   creative.FileID,
+			FilePath: filePath,
+		})
+	}
+
+	return response
+}
+
+// parseCampaignID reads the :campaign_id route parameter. On failure the
+// error response has already been written.
+func parseCampaignID(c *gin.Context) (int64, bool) {
+	campaignID, err := strconv.ParseInt(c.Param("campaign_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid campaign_id",
+		})
+		return 0, false
+	}
+	return campaignID, true
+}
+
+// campaignErrorStatus maps streamer errors to HTTP status codes
+func campaignErrorStatus(err error) int {
+	if err.Error() == "campaign not found" {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// handleCampaignCreate creates a new ad campaign
+func handleCampaignCreate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleCampaignCreate",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	var req adCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: name, file_ids and start_date are required",
+		})
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"name":       req.Name,
+		"start_date": req.StartDate,
+		"end_date":   req.EndDate,
+	}).Info("Received request to create ad campaign")
+
+	campaign := req.campaign(channelID)
+	if err := streamer.CreateAdCampaign(campaign, req.FileIDs); err != nil {
+		logger.WithError(err).Error("Failed to create ad campaign")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("campaign_id", campaign.ID).Info("✓ Successfully created ad campaign")
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "Ad campaign created",
+		"campaign": enrichAdCampaign(campaign),
+	})
+}
+
+// handleCampaignList returns all ad campaigns of a channel
+func handleCampaignList(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleCampaignList",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	campaigns, err := streamer.GetAdCampaigns(channelID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get ad campaigns")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	enrichedCampaigns := make([]AdCampaignResponse, len(campaigns))
+	for i, campaign := range campaigns {
+		enrichedCampaigns[i] = enrichAdCampaign(&campaign)
+	}
+
+	logger.WithField("campaign_count", len(campaigns)).Debug("✓ Successfully retrieved ad campaigns")
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"campaigns": enrichedCampaigns,
+		"count":     len(enrichedCampaigns),
+	})
+}
+
+// handleCampaignGet returns a single ad campaign with its delivery
+func handleCampaignGet(c *gin.Context) {
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	campaignID, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := streamer.GetAdCampaign(channelID, campaignID)
+	if err != nil {
+		c.JSON(campaignErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"campaign": enrichAdCampaign(campaign),
+	})
+}
+
+// handleCampaignUpdate replaces the settings and creatives of an ad campaign
+func handleCampaignUpdate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleCampaignUpdate",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	campaignID, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"channel_id":  channelID,
+		"campaign_id": campaignID,
+	})
+
+	var req adCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: name, file_ids and start_date are required",
+		})
+		return
+	}
+
+	logger.Info("Received request to update ad campaign")
+
+	campaign, err := streamer.UpdateAdCampaign(channelID, campaignID, req.campaign(channelID), req.FileIDs)
+	if err != nil {
+		logger.WithError(err).Error("Failed to update ad campaign")
+		c.JSON(campaignErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("✓ Successfully updated ad campaign")
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "Ad campaign updated",
+		"campaign": enrichAdCampaign(campaign),
+	})
+}
+
+// handleCampaignDelete removes an ad campaign
+func handleCampaignDelete(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleCampaignDelete",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	campaignID, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"channel_id":  channelID,
+		"campaign_id": campaignID,
+	})
+
+	logger.Info("Received request to delete ad campaign")
+
+	if err := streamer.DeleteAdCampaign(channelID, campaignID); err != nil {
+		logger.WithError(err).Error("Failed to delete ad campaign")
+		c.JSON(campaignErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("✓ Successfully deleted ad campaign")
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "Ad campaign deleted",
+		"campaign_id": campaignID,
+	})
+}
@@ -0,0 +1,340 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ChannelResponse is a channel together with its runtime state
+type ChannelResponse struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	OutputDir   string `json:"output_dir"`
+	Enabled     int    `json:"enabled"`
+	CreatedAt   int64  `json:"created_at"`
+	Running     bool   `json:"running"`
+	PlaylistURL string `json:"playlist_url"`
+}
+
+func enrichChannel(channel *models.Channel) ChannelResponse {
+	return ChannelResponse{
+		ID:          channel.ID,
+		Name:        channel.Name,
+		OutputDir:   channel.ResolveOutputDir(streamer.ActiveStreamingProfile().OutputDir),
+		Enabled:     channel.Enabled,
+		CreatedAt:   channel.CreatedAt,
+		Running:     streamer.IsChannelRunning(channel.ID),
+		PlaylistURL: "/channels/" + strconv.FormatInt(channel.ID, 10) + "/stream/stream.m3u8",
+	}
+}
+
+// resolveChannelID returns the channel addressed by the :channel_id route
+// parameter. Legacy routes without the parameter use the default channel.
+// On failure the error response has already been written.
+func resolveChannelID(c *gin.Context) (int64, bool) {
+	param := c.Param("channel_id")
+	if param == "" {
+		return models.DefaultChannelID, true
+	}
+
+	channelID, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid channel id",
+		})
+		return 0, false
+	}
+
+	if _, err := streamer.GetChannel(channelID); err != nil {
+		status := http.StatusInternalServerError
+		if err.Error() == "channel not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return 0, false
+	}
+
+	return channelID, true
+}
+
+// handleChannelCreate creates a new channel
+func handleChannelCreate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleChannelCreate",
+		"client_ip": c.ClientIP(),
+	})
+
+	var req struct {
+		Name      string `json:"name" binding:"required"`
+		OutputDir string `json:"output_dir"`
+		Start     bool   `json:"start"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: name is required",
+		})
+		return
+	}
+
+	logger.WithField("name", req.Name).Info("Received request to create channel")
+
+	channel := &models.Channel{
+		Name:      req.Name,
+		OutputDir: req.OutputDir,
+	}
+
+	if err := streamer.CreateChannel(channel); err != nil {
+		logger.WithError(err).Error("Failed to create channel")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if req.Start {
+		if err := streamer.StartChannel(channel.ID); err != nil {
+			logger.WithError(err).Error("Failed to start channel")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+				"channel": enrichChannel(channel),
+			})
+			return
+		}
+	}
+
+	logger.WithField("channel_id", channel.ID).Info("✓ Successfully created channel")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Channel created",
+		"channel": enrichChannel(channel),
+	})
+}
+
+// handleChannelList returns all channels
+func handleChannelList(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleChannelList",
+		"client_ip": c.ClientIP(),
+	})
+
+	logger.Debug("Received request to list channels")
+
+	channels, err := streamer.GetChannels()
+	if err != nil {
+		logger.WithError(err).Error("Failed to get channels")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	enrichedChannels := make([]ChannelResponse, len(channels))
+	for i, channel := range channels {
+		enrichedChannels[i] = enrichChannel(&channel)
+	}
+
+	logger.WithField("channel_count", len(channels)).Info("✓ Successfully retrieved channels")
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"channels": enrichedChannels,
+		"count":    len(enrichedChannels),
+	})
+}
+
+// handleChannelGet returns a single channel with its player status
+func handleChannelGet(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleChannelGet",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	channel, err := streamer.GetChannel(channelID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get channel")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	player, err := streamer.GetChannelPlayer(channelID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get channel player")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("channel_id", channelID).Debug("✓ Successfully retrieved channel")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"channel": enrichChannel(channel),
+		"status":  player.GetStatus(),
+	})
+}
+
+// handleChannelDelete stops and removes a channel with all its programming
+func handleChannelDelete(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleChannelDelete",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	logger.WithField("channel_id", channelID).Info("Received request to delete channel")
+
+	if err := streamer.DeleteChannel(channelID); err != nil {
+		logger.WithError(err).Error("Failed to delete channel")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("channel_id", channelID).Info("✓ Successfully deleted channel")
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "Channel deleted",
+		"channel_id": channelID,
+	})
+}
+
+// handleChannelStart starts the streaming pipeline of a channel
+func handleChannelStart(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleChannelStart",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	logger.WithField("channel_id", channelID).Info("Received request to start channel")
+
+	if err := streamer.StartChannel(channelID); err != nil {
+		logger.WithError(err).Error("Failed to start channel")
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("channel_id", channelID).Info("✓ Successfully started channel")
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "Channel started",
+		"channel_id": channelID,
+	})
+}
+
+// handleChannelStop stops the streaming pipeline of a channel
+func handleChannelStop(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleChannelStop",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	logger.WithField("channel_id", channelID).Info("Received request to stop channel")
+
+	if err := streamer.StopChannel(channelID); err != nil {
+		logger.WithError(err).Error("Failed to stop channel")
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("channel_id", channelID).Info("✓ Successfully stopped channel")
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"message":    "Channel stopped",
+		"channel_id": channelID,
+	})
+}
+
+// handleChannelHLS serves the HLS playlists and segments of a channel. Media
+// playlists are decorated with the ad cue markers of the channel.
+func handleChannelHLS(c *gin.Context) {
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+
+	player, err := streamer.GetChannelPlayer(channelID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	filepath := path.Clean(c.Param("filepath"))
+	if path.Ext(filepath) != ".m3u8" {
+		c.FileFromFS(filepath, http.Dir(player.OutputDir()))
+		return
+	}
+
+	// Playlists are read per request and get the ad cue markers inserted
+	file, err := http.Dir(player.OutputDir()).Open(filepath)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	playlist, err := io.ReadAll(file)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", player.DecoratePlaylist(playlist))
+}
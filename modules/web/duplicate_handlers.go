@@ -0,0 +1,141 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// MergeDuplicatesRequest selects the duplicate files to merge: the files of
+// keep, or every duplicate group with all
+type MergeDuplicatesRequest struct {
+	Keep        string   `json:"keep"`
+	Files       []string `json:"files"`
+	All         bool     `json:"all"`
+	DeleteFiles bool     `json:"delete_files"`
+}
+
+// handleFileDuplicates returns the library files with the same content
+func handleFileDuplicates(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleFileDuplicates",
+		"client_ip": c.ClientIP(),
+	})
+
+	groups, err := streamer.FindDuplicateFiles()
+	if err != nil {
+		logger.WithError(err).Error("Failed to find duplicate files")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("group_count", len(groups)).Debug("✓ Successfully retrieved duplicate files")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"groups":  groups,
+		"count":   len(groups),
+	})
+}
+
+// handleFileMergeDuplicates merges duplicate files into the file that is kept
+func handleFileMergeDuplicates(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleFileMergeDuplicates",
+		"client_ip": c.ClientIP(),
+	})
+
+	var req MergeDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Keep == "" && !req.All {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Either 'keep' or 'all' is required",
+		})
+		return
+	}
+	if req.Keep != "" && req.All {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "'keep' and 'all' can't be combined",
+		})
+		return
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"keep":         req.Keep,
+		"all":          req.All,
+		"delete_files": req.DeleteFiles,
+	})
+	logger.Info("Received request to merge duplicate files")
+
+	results := []streamer.MergeResult{}
+	if req.All {
+		groups, err := streamer.FindDuplicateFiles()
+		if err != nil {
+			logger.WithError(err).Error("Failed to find duplicate files")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		for _, group := range groups {
+			result, err := streamer.MergeDuplicateFiles(group.Keep, nil, req.DeleteFiles)
+			if err != nil {
+				logger.WithError(err).WithField("keep", group.Keep).Error("Failed to merge duplicate files")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"error":   err.Error(),
+					"merged":  results,
+				})
+				return
+			}
+			results = append(results, *result)
+		}
+	} else {
+		result, err := streamer.MergeDuplicateFiles(req.Keep, req.Files, req.DeleteFiles)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to merge duplicate files")
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, streamer.ErrFileNotFound):
+				status = http.StatusNotFound
+			case strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "fingerprint"):
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		results = append(results, *result)
+	}
+
+	logger.WithField("merged_groups", len(results)).Info("✓ Successfully merged duplicate files")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Duplicate files merged",
+		"merged":  results,
+		"count":   len(results),
+	})
+}
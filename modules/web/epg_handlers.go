@@ -0,0 +1,82 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// handleEPGExport returns the program guide as an XMLTV document
+func handleEPGExport(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleEPGExport",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	cfg := helpers.GetConfig()
+
+	horizonHours := cfg.EPG.HorizonHours
+	if horizonHours <= 0 {
+		horizonHours = 24
+	}
+	if hours, err := strconv.Atoi(c.Query("hours")); err == nil && hours > 0 {
+		horizonHours = hours
+	}
+
+	xmltvID := cfg.EPG.ChannelID
+	if xmltvID == "" {
+		xmltvID = "tv_streamer"
+	}
+	channelName := cfg.EPG.ChannelName
+	if channelName == "" {
+		channelName = "TV Streamer"
+	}
+
+	// Additional channels get their own XMLTV id and display name
+	if channelID != models.DefaultChannelID {
+		if channel, err := streamer.GetChannel(channelID); err == nil {
+			xmltvID = fmt.Sprintf("%s.%d", xmltvID, channel.ID)
+			channelName = channel.Name
+		}
+	}
+
+	logger.WithField("horizon_hours", horizonHours).Debug("Received request to export EPG")
+
+	programmes, err := streamer.BuildEPG(channelID, time.Now(), time.Duration(horizonHours)*time.Hour)
+	if err != nil {
+		logger.WithError(err).Error("Failed to build EPG")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	output, err := streamer.RenderXMLTV(programmes, xmltvID, channelName)
+	if err != nil {
+		logger.WithError(err).Error("Failed to render XMLTV")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("programmes", len(programmes)).Info("✓ Successfully exported EPG")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", output)
+}
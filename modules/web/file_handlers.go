@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"tv_streamer/helpers"
 	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
 	"tv_streamer/modules/streamer/models"
 
 	"github.com/gin-gonic/gin"
@@ -302,6 +303,9 @@ func handleFileDelete(c *gin.Context) {
 		logger.WithError(err).Warn("Failed to remove file from schedule")
 	}
 
+	// Drop any normalized copies of the file
+	streamer.RemoveNormalizedCache(fileID)
+
 	// Delete from database (after cleaning up related records)
 	_, err = db.Where("file_id = ?", fileID).Delete(&models.AvailableFiles{})
 	if err != nil {
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// FileReviewResponse is the review state of a file
+type FileReviewResponse struct {
+	FileID       string `json:"file_id"`
+	FilePath     string `json:"filepath"`
+	FileSize     int64  `json:"file_size"`
+	VideoLength  int64  `json:"video_length"`
+	AddedTime    int64  `json:"added_time"`
+	IsActive     int    `json:"is_active"`
+	ReviewStatus string `json:"review_status"`
+	ReviewedBy   string `json:"reviewed_by"`
+	ReviewedAt   int64  `json:"reviewed_at"`
+	ReviewNote   string `json:"review_note"`
+
+	// Upload policy result, reviewers see the warnings and conformed rules
+	ValidationStatus string          `json:"validation_status"`
+	ValidationReport json.RawMessage `json:"validation_report,omitempty"`
+}
+
+func enrichFileReview(file *models.AvailableFiles) FileReviewResponse {
+	var report json.RawMessage
+	if file.ValidationReport != "" {
+		report = json.RawMessage(file.ValidationReport)
+	}
+
+	return FileReviewResponse{
+		FileID:       file.FileID,
+		FilePath:     file.FilePath,
+		FileSize:     file.FileSize,
+		VideoLength:  file.VideoLength,
+		AddedTime:    file.AddedTime,
+		IsActive:     file.IsActive,
+		ReviewStatus: file.ReviewStatus,
+		ReviewedBy:   file.ReviewedBy,
+		ReviewedAt:   file.ReviewedAt,
+		ReviewNote:   file.ReviewNote,
+
+		ValidationStatus: file.ValidationStatus,
+		ValidationReport: report,
+	}
+}
+
+// ReviewRequest is the body of an approval or rejection
+type ReviewRequest struct {
+	Reviewer string `json:"reviewer"`
+	Note     string `json:"note"`
+}
+
+// handleFileReviewQueue returns the files waiting for review, or the files
+// with the review status given by status
+func handleFileReviewQueue(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleFileReviewQueue",
+		"client_ip": c.ClientIP(),
+	})
+
+	status := c.DefaultQuery("status", models.ReviewStatusPending)
+
+	files, err := streamer.GetFilesForReview(status)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get files for review")
+		httpStatus := http.StatusInternalServerError
+		if strings.HasPrefix(err.Error(), "invalid review status") {
+			httpStatus = http.StatusBadRequest
+		}
+		c.JSON(httpStatus, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	enrichedFiles := make([]FileReviewResponse, len(files))
+	for i := range files {
+		enrichedFiles[i] = enrichFileReview(&files[i])
+	}
+
+	logger.WithField("file_count", len(files)).Debug("✓ Successfully retrieved review queue")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"status":  status,
+		"files":   enrichedFiles,
+		"count":   len(enrichedFiles),
+	})
+}
+
+// handleFileApprove approves a file for airing
+func handleFileApprove(c *gin.Context) {
+	handleFileReview(c, "handleFileApprove", models.ReviewStatusApproved)
+}
+
+// handleFileReject rejects a file and takes it off air
+func handleFileReject(c *gin.Context) {
+	handleFileReview(c, "handleFileReject", models.ReviewStatusRejected)
+}
+
+// handleFileReview records an approval or rejection with reviewer and note
+func handleFileReview(c *gin.Context, handler, status string) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   handler,
+		"client_ip": c.ClientIP(),
+	})
+
+	fileID := c.Param("file_id")
+
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"file_id":  fileID,
+		"reviewer": req.Reviewer,
+	})
+	logger.Info("Received request to review file")
+
+	var (
+		file    *models.AvailableFiles
+		removed int64
+		err     error
+	)
+	if status == models.ReviewStatusApproved {
+		file, err = streamer.ApproveFile(fileID, req.Reviewer, req.Note)
+	} else {
+		file, removed, err = streamer.RejectFile(fileID, req.Reviewer, req.Note)
+	}
+	if err != nil && file == nil {
+		logger.WithError(err).Warn("Failed to review file")
+		httpStatus := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, streamer.ErrFileNotFound):
+			httpStatus = http.StatusNotFound
+		case errors.Is(err, streamer.ErrFileValidating), errors.Is(err, streamer.ErrFileFailedValidation):
+			httpStatus = http.StatusConflict
+		case strings.HasPrefix(err.Error(), "reviewer is required"), strings.HasPrefix(err.Error(), "review note"):
+			httpStatus = http.StatusBadRequest
+		}
+		c.JSON(httpStatus, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("File reviewed, but failed to take it off air")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"file":    enrichFileReview(file),
+		})
+		return
+	}
+
+	message := "File approved"
+	if status == models.ReviewStatusRejected {
+		message = "File rejected"
+	}
+
+	logger.WithField("status", status).Info("✓ Successfully reviewed file")
+	response := gin.H{
+		"success": true,
+		"message": message,
+		"file":    enrichFileReview(file),
+	}
+	if status == models.ReviewStatusRejected {
+		response["queue_removed"] = removed
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// airingErrorStatus returns the HTTP status for a failure to put a file on
+// air: 409 if the file is not approved, 500 otherwise
+func airingErrorStatus(err error) int {
+	if strings.HasPrefix(err.Error(), "file is not approved") {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
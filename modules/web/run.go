@@ -1,10 +1,15 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 	"tv_streamer/helpers"
 	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth"
 	"tv_streamer/modules/streamer"
 
 	"github.com/gin-contrib/cors"
@@ -12,12 +17,27 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	server   *http.Server
+	serverMu sync.Mutex
+)
+
+// Run starts the web server and blocks until it is shut down
 func Run() {
 	logger := logs.GetLogger().WithField("module", "web")
 	logger.Info("========================================")
 	logger.Info("Starting Web Server...")
 	logger.Info("========================================")
 
+	// Create the first admin before the logs are broadcast, its key is only logged
+	if helpers.GetConfig().Auth.Enabled {
+		if err := auth.EnsureAdmin(); err != nil {
+			logger.WithError(err).Error("Failed to create the first admin")
+		}
+	} else {
+		logger.Warn("Authentication is disabled (auth.enabled), the API is open to everyone")
+	}
+
 	// Initialize WebSocket Hub
 	wsHub := GetWebSocketHub()
 
@@ -27,81 +47,77 @@ func Run() {
 	// Set broadcaster for streamer module to send currently_playing events
 	streamer.SetBroadcaster(wsHub)
 
-	router := gin.Default()
+	// gin.Default() without its logger, which writes access tokens of the URL
+	router := gin.New()
+	router.Use(requestLogger(), gin.Recovery())
 
-	// Configure and use CORS middleware
+	// Configure and use CORS middleware, browsers may only call the API from
+	// the origins listed in app.allowed_origins
 	config := cors.Config{
-		AllowOrigins:     []string{"*"}, // Allow all origins, adjust as needed
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowOriginFunc:  isAllowedOrigin,
+		AllowMethods:     []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     append([]string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"}, tusRequestHeaders...),
+		ExposeHeaders:    append([]string{"Content-Length"}, tusResponseHeaders...),
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
 
 	router.Use(cors.New(config))
 
-	// API routes
-	api := router.Group("/api")
-	{
-		// Health check
-		api.GET("/health", func(c *gin.Context) {
-			logger.Debug("Health check requested")
-			c.JSON(200, gin.H{
-				"status":  true,
-				"service": "tv_streamer",
-				"version": "1.0.0",
-			})
+	// Health check, open for load balancers and monitoring
+	router.GET("/api/health", func(c *gin.Context) {
+		logger.Debug("Health check requested")
+		c.JSON(200, gin.H{
+			"status":  true,
+			"service": "tv_streamer",
+			"version": "1.0.0",
 		})
+	})
 
-		// WebSocket endpoint for debug messages
-		api.GET("/ws", handleWebSocket)
-
-		// Stream control endpoints
-		stream := api.Group("/stream")
-		{
-			stream.POST("/next", handleStreamNext)
-			stream.POST("/add", handleStreamAdd)
-			stream.GET("/queue", handleStreamQueue)
-			stream.GET("/status", handleStreamStatus)
-			stream.POST("/inject-ad", handleInjectAd)
-			stream.GET("/history", handleStreamHistory)
-			stream.POST("/scan", handleScanVideos)
-			stream.POST("/clear-played", handleClearPlayed)
-		}
-
-		// Files endpoint
-		api.GET("/files", handleGetAvailableFiles)
-
-		// Schedule management endpoints
-		schedule := api.Group("/schedule")
-		{
-			schedule.POST("/add", handleScheduleAdd)
-			schedule.GET("/", handleScheduleGet)
-			schedule.DELETE("/remove", handleScheduleRemove)
-			schedule.POST("/clear", handleScheduleClear)
-			schedule.POST("/reset", handleScheduleReset)
-		}
+	// API routes, every one needs an API key or access token
+	registerAPIRoutes(router.Group("/api", authenticate()))
 
-		// File management endpoints
-		files := api.Group("/files")
-		{
-			files.GET("/", handleFilesList)
-			files.GET("/:file_id", handleFileInfo)
-			files.PUT("/:file_id/rename", handleFileRename)
-			files.PUT("/:file_id/description", handleFileUpdateDescription)
-			files.DELETE("/:file_id", handleFileDelete)
-		}
-	}
+	// Serve HLS files (default channel), resolved per request so a new
+	// streaming profile with another output directory applies immediately
+	router.GET("/stream/*filepath", handleChannelHLS)
+	router.HEAD("/stream/*filepath", handleChannelHLS)
 
-	// Serve HLS files
-	router.Static("/stream", "./out")
+	// Serve HLS files of every channel
+	router.GET("/channels/:channel_id/stream/*filepath", handleChannelHLS)
+	router.HEAD("/channels/:channel_id/stream/*filepath", handleChannelHLS)
 
 	// Log available endpoints
 	logger.Info("API Endpoints:")
 	logger.Info("  GET  /api/health               - Health check")
-	logger.Info("  GET  /api/ws                   - WebSocket debug API")
+	logger.Info("  GET  /api/ws                   - WebSocket debug API (?access_token=...)")
 	logger.Info("  GET  /api/files                - List all available files with ffprobe data")
+	logger.Info("  GET  /api/epg.xml?hours=24     - XMLTV electronic program guide")
+	logger.Info("")
+	logger.Info("Uploads:")
+	logger.Info("  GET    /api/uploads                    - Upload sessions of the user")
+	logger.Info("  GET    /api/uploads/:session_id        - Received offset and missing ranges")
+	logger.Info("  DELETE /api/uploads/:session_id        - Abort upload session")
+	logger.Info("  POST   /api/uploads/tus                - Start tus upload (Upload-Length, Upload-Metadata)")
+	logger.Info("  HEAD   /api/uploads/tus/:session_id    - Offset to resume a tus upload at")
+	logger.Info("  PATCH  /api/uploads/tus/:session_id    - Send tus upload data at Upload-Offset")
+	logger.Info("  DELETE /api/uploads/tus/:session_id    - Abort tus upload")
+	logger.Info("")
+	logger.Info("Authentication (API key or access token, roles viewer < operator < admin):")
+	logger.Info("  GET    /api/auth/me                    - Identity of the request")
+	logger.Info("  POST   /api/auth/token                 - Issue access token for an API key")
+	logger.Info("  GET    /api/auth/users                 - List users")
+	logger.Info("  POST   /api/auth/users                 - Create user")
+	logger.Info("  PUT    /api/auth/users/:user_id        - Change role or disable user")
+	logger.Info("  DELETE /api/auth/users/:user_id        - Delete user")
+	logger.Info("  GET    /api/auth/users/:user_id/keys   - List API keys of a user")
+	logger.Info("  POST   /api/auth/users/:user_id/keys   - Create API key")
+	logger.Info("  DELETE /api/auth/keys/:key_id          - Revoke API key")
+	logger.Info("")
+	logger.Info("Administration:")
+	logger.Info("  GET  /api/admin/streaming      - Get active streaming profile")
+	logger.Info("  PUT  /api/admin/streaming      - Apply streaming profile and restart pipelines")
+	logger.Info("  GET  /api/admin/consistency    - Report of the last library consistency check")
+	logger.Info("  POST /api/admin/consistency/check?fix=true - Check library consistency, optionally repair")
 	logger.Info("")
 	logger.Info("Stream Control:")
 	logger.Info("  POST /api/stream/next          - Skip to next video")
@@ -109,6 +125,8 @@ func Run() {
 	logger.Info("  GET  /api/stream/queue         - Get current queue")
 	logger.Info("  GET  /api/stream/status        - Get player status")
 	logger.Info("  POST /api/stream/inject-ad?file=... - Inject ad")
+	logger.Info("  POST /api/stream/ad-break      - Queue ad break (ad pod)")
+	logger.Info("  GET  /api/stream/ad-pods?limit=50 - Get ad pods")
 	logger.Info("  GET  /api/stream/history?limit=50 - Get play history")
 	logger.Info("  POST /api/stream/scan?directory=... - Scan directory")
 	logger.Info("  POST /api/stream/clear-played  - Clear played items")
@@ -120,15 +138,57 @@ func Run() {
 	logger.Info("  POST   /api/schedule/clear     - Clear schedule")
 	logger.Info("  POST   /api/schedule/reset     - Reset schedule position")
 	logger.Info("")
+	logger.Info("Programming Grid (Time Slots):")
+	logger.Info("  POST   /api/schedule/slots     - Create time slot")
+	logger.Info("  GET    /api/schedule/slots     - List time slots")
+	logger.Info("  DELETE /api/schedule/slots/:slot_id - Delete time slot")
+	logger.Info("")
+	logger.Info("Ad Campaigns (automatic ad breaks):")
+	logger.Info("  POST   /api/campaigns/         - Create campaign")
+	logger.Info("  GET    /api/campaigns/         - List campaigns with delivery")
+	logger.Info("  GET    /api/campaigns/:campaign_id - Get campaign")
+	logger.Info("  PUT    /api/campaigns/:campaign_id - Update campaign")
+	logger.Info("  DELETE /api/campaigns/:campaign_id - Delete campaign")
+	logger.Info("")
+	logger.Info("As-Run Log:")
+	logger.Info("  GET    /api/as-run?from=...&to=...&format=csv - What went on air")
+	logger.Info("  GET    /api/as-run/daily?from=...&to=...      - Airtime per file and day")
+	logger.Info("")
+	logger.Info("Playback Analytics:")
+	logger.Info("  GET    /api/analytics/summary?from=...&to=... - Totals, skip rate and ad/content ratio")
+	logger.Info("  GET    /api/analytics/daily?from=...&to=...   - Airtime and skips per file and day")
+	logger.Info("  GET    /api/analytics/files?sort=skip_rate    - Per-file stats (airtime, plays, skip_rate)")
+	logger.Info("  GET    /api/analytics/top?limit=10            - Most-aired files")
+	logger.Info("")
+	logger.Info("Channel Management:")
+	logger.Info("  GET    /api/channels/                  - List channels")
+	logger.Info("  POST   /api/channels/                  - Create channel")
+	logger.Info("  GET    /api/channels/:channel_id       - Get channel with player status")
+	logger.Info("  DELETE /api/channels/:channel_id       - Delete channel")
+	logger.Info("  POST   /api/channels/:channel_id/start - Start channel")
+	logger.Info("  POST   /api/channels/:channel_id/stop  - Stop channel")
+	logger.Info("  *      /api/channels/:channel_id/stream/...   - Stream control of a channel")
+	logger.Info("  *      /api/channels/:channel_id/schedule/... - Schedule of a channel")
+	logger.Info("  *      /api/channels/:channel_id/campaigns/... - Ad campaigns of a channel")
+	logger.Info("  *      /api/channels/:channel_id/as-run/...   - As-run log of a channel")
+	logger.Info("  *      /api/channels/:channel_id/analytics/... - Playback analytics of a channel")
+	logger.Info("  GET    /api/channels/:channel_id/epg.xml      - XMLTV guide of a channel")
+	logger.Info("")
 	logger.Info("File Management:")
 	logger.Info("  GET    /api/files/                      - List all available files")
+	logger.Info("  GET    /api/files/duplicates            - List files with the same content")
+	logger.Info("  POST   /api/files/duplicates/merge      - Merge duplicate files")
+	logger.Info("  GET    /api/files/review?status=pending - Files waiting for review")
 	logger.Info("  GET    /api/files/:file_id              - Get detailed file info")
 	logger.Info("  PUT    /api/files/:file_id/rename       - Rename file")
 	logger.Info("  PUT    /api/files/:file_id/description  - Update file description")
+	logger.Info("  POST   /api/files/:file_id/approve      - Approve file for airing")
+	logger.Info("  POST   /api/files/:file_id/reject       - Reject file and take it off air")
 	logger.Info("  DELETE /api/files/:file_id              - Delete file")
 	logger.Info("")
 	logger.Info("HLS Stream:")
-	logger.Info("  GET  /stream/stream.m3u8       - HLS playlist")
+	logger.Info("  GET  /stream/stream.m3u8       - HLS playlist (default channel)")
+	logger.Info("  GET  /channels/:channel_id/stream/stream.m3u8 - HLS playlist of a channel")
 	logger.Info("")
 
 	cfg := helpers.GetConfig()
@@ -143,5 +203,206 @@ func Run() {
 	logger.WithField("url", fmt.Sprintf("http://localhost%s/stream/stream.m3u8", port)).Info("Stream URL available at:")
 	logger.WithField("url", fmt.Sprintf("http://localhost%s/api/health", port)).Info("API available at:")
 
-	router.Run(port)
+	serverMu.Lock()
+	server = &http.Server{
+		Addr:    port,
+		Handler: router,
+	}
+	srv := server
+	serverMu.Unlock()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.WithError(err).Error("Web server failed")
+		return
+	}
+
+	logger.Info("✓ Web Server stopped")
+}
+
+// registerAPIRoutes adds the API endpoints with the role each one requires
+func registerAPIRoutes(api *gin.RouterGroup) {
+	// Authentication endpoints
+	authGroup := api.Group("/auth")
+	{
+		authGroup.GET("/me", allowViewer, handleAuthMe)
+		authGroup.POST("/token", allowViewer, handleAuthToken)
+		authGroup.GET("/users", allowAdmin, handleUserList)
+		authGroup.POST("/users", allowAdmin, handleUserCreate)
+		authGroup.PUT("/users/:user_id", allowAdmin, handleUserUpdate)
+		authGroup.DELETE("/users/:user_id", allowAdmin, handleUserDelete)
+		authGroup.GET("/users/:user_id/keys", allowAdmin, handleAPIKeyList)
+		authGroup.POST("/users/:user_id/keys", allowAdmin, handleAPIKeyCreate)
+		authGroup.DELETE("/keys/:key_id", allowAdmin, handleAPIKeyRevoke)
+	}
+
+	// WebSocket endpoint for debug messages, uploads need the operator role
+	api.GET("/ws", allowViewer, handleWebSocket)
+
+	// Upload sessions, uploads are sent over the WebSocket or with tus
+	uploads := api.Group("/uploads", allowOperator)
+	{
+		uploads.GET("", handleUploadSessionList)
+		uploads.GET("/:session_id", handleUploadSessionGet)
+		uploads.DELETE("/:session_id", handleUploadSessionDelete)
+
+		tus := uploads.Group("/tus", tusResumable())
+		tus.OPTIONS("", handleTusOptions)
+		tus.POST("", handleTusCreate)
+		tus.HEAD("/:session_id", handleTusHead)
+		tus.PATCH("/:session_id", handleTusPatch)
+		tus.DELETE("/:session_id", handleTusDelete)
+	}
+
+	// Stream control endpoints (default channel)
+	registerStreamRoutes(api.Group("/stream"))
+
+	// Files endpoint
+	api.GET("/files", allowViewer, handleGetAvailableFiles)
+
+	// Administration endpoints
+	admin := api.Group("/admin", allowAdmin)
+	{
+		admin.GET("/streaming", handleStreamingProfileGet)
+		admin.PUT("/streaming", handleStreamingProfileApply)
+		admin.GET("/consistency", handleConsistencyReport)
+		admin.POST("/consistency/check", handleConsistencyCheck)
+	}
+
+	// Electronic program guide (XMLTV)
+	api.GET("/epg.xml", allowViewer, handleEPGExport)
+
+	// Schedule management endpoints (default channel)
+	registerScheduleRoutes(api.Group("/schedule"))
+
+	// Ad campaign endpoints (default channel)
+	registerCampaignRoutes(api.Group("/campaigns"))
+
+	// As-run log endpoints (default channel)
+	registerAsRunRoutes(api.Group("/as-run"))
+
+	// Playback analytics endpoints (default channel)
+	registerAnalyticsRoutes(api.Group("/analytics"))
+
+	// Channel management endpoints
+	channels := api.Group("/channels")
+	{
+		channels.GET("/", allowViewer, handleChannelList)
+		channels.POST("/", allowAdmin, handleChannelCreate)
+		channels.GET("/:channel_id", allowViewer, handleChannelGet)
+		channels.DELETE("/:channel_id", allowAdmin, handleChannelDelete)
+		channels.POST("/:channel_id/start", allowOperator, handleChannelStart)
+		channels.POST("/:channel_id/stop", allowOperator, handleChannelStop)
+
+		// Per-channel stream control, schedule, campaigns, reports and program guide
+		registerStreamRoutes(channels.Group("/:channel_id/stream"))
+		registerScheduleRoutes(channels.Group("/:channel_id/schedule"))
+		registerCampaignRoutes(channels.Group("/:channel_id/campaigns"))
+		registerAsRunRoutes(channels.Group("/:channel_id/as-run"))
+		registerAnalyticsRoutes(channels.Group("/:channel_id/analytics"))
+		channels.GET("/:channel_id/epg.xml", allowViewer, handleEPGExport)
+	}
+
+	// File management endpoints
+	files := api.Group("/files")
+	{
+		files.GET("/", allowViewer, handleFilesList)
+		files.GET("/duplicates", allowViewer, handleFileDuplicates)
+		files.POST("/duplicates/merge", allowAdmin, handleFileMergeDuplicates)
+		files.GET("/review", allowViewer, handleFileReviewQueue)
+		files.GET("/:file_id", allowViewer, handleFileInfo)
+		files.PUT("/:file_id/rename", allowOperator, handleFileRename)
+		files.PUT("/:file_id/description", allowOperator, handleFileUpdateDescription)
+		files.POST("/:file_id/approve", allowOperator, handleFileApprove)
+		files.POST("/:file_id/reject", allowOperator, handleFileReject)
+		files.DELETE("/:file_id", allowAdmin, handleFileDelete)
+	}
+}
+
+// Shutdown stops accepting connections, closes the WebSocket clients and
+// waits for in-flight requests until the context expires
+func Shutdown(ctx context.Context) error {
+	logger := logs.GetLogger().WithField("module", "web")
+	logger.Info("Shutting down Web Server...")
+
+	serverMu.Lock()
+	srv := server
+	serverMu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	var drainErr error
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.WithError(err).Warn("In-flight requests did not finish in time, closing connections")
+		srv.Close()
+		drainErr = fmt.Errorf("failed to drain web server: %w", err)
+	}
+
+	// Hijacked WebSocket connections aren't tracked by http.Server
+	restart := streamer.ActiveStreamingProfile().ShutdownMarker == streamer.ShutdownMarkerRestart
+	GetWebSocketHub().Shutdown(ctx, restart)
+
+	if drainErr != nil {
+		return drainErr
+	}
+
+	logger.Info("✓ Web Server drained")
+	return nil
+}
+
+// registerStreamRoutes registers the stream control endpoints on a route group.
+// Groups without a :channel_id parameter address the default channel.
+func registerStreamRoutes(stream *gin.RouterGroup) {
+	stream.POST("/next", allowOperator, handleStreamNext)
+	stream.POST("/add", allowOperator, handleStreamAdd)
+	stream.GET("/queue", allowViewer, handleStreamQueue)
+	stream.GET("/status", allowViewer, handleStreamStatus)
+	stream.POST("/inject-ad", allowOperator, handleInjectAd)
+	stream.POST("/ad-break", allowOperator, handleAdBreak)
+	stream.GET("/ad-pods", allowViewer, handleAdPodList)
+	stream.GET("/history", allowViewer, handleStreamHistory)
+	stream.POST("/scan", allowOperator, handleScanVideos)
+	stream.POST("/clear-played", allowOperator, handleClearPlayed)
+}
+
+// registerScheduleRoutes registers the schedule management endpoints on a route group.
+// Groups without a :channel_id parameter address the default channel.
+func registerScheduleRoutes(schedule *gin.RouterGroup) {
+	schedule.POST("/add", allowOperator, handleScheduleAdd)
+	schedule.GET("/", allowViewer, handleScheduleGet)
+	schedule.DELETE("/remove", allowOperator, handleScheduleRemove)
+	schedule.POST("/clear", allowOperator, handleScheduleClear)
+	schedule.POST("/reset", allowOperator, handleScheduleReset)
+
+	// Time-slot programming grid (consulted before the endless loop)
+	schedule.POST("/slots", allowOperator, handleSlotCreate)
+	schedule.GET("/slots", allowViewer, handleSlotList)
+	schedule.DELETE("/slots/:slot_id", allowOperator, handleSlotDelete)
+}
+
+// registerCampaignRoutes registers the ad campaign endpoints on a route group.
+// Groups without a :channel_id parameter address the default channel.
+func registerCampaignRoutes(campaigns *gin.RouterGroup) {
+	campaigns.POST("/", allowOperator, handleCampaignCreate)
+	campaigns.GET("/", allowViewer, handleCampaignList)
+	campaigns.GET("/:campaign_id", allowViewer, handleCampaignGet)
+	campaigns.PUT("/:campaign_id", allowOperator, handleCampaignUpdate)
+	campaigns.DELETE("/:campaign_id", allowOperator, handleCampaignDelete)
+}
+
+// registerAsRunRoutes registers the as-run log endpoints on a route group.
+// Groups without a :channel_id parameter address the default channel.
+func registerAsRunRoutes(asRun *gin.RouterGroup) {
+	asRun.GET("", allowViewer, handleAsRunLog)
+	asRun.GET("/daily", allowViewer, handleAsRunDaily)
+}
+
+// registerAnalyticsRoutes registers the playback analytics endpoints on a route group.
+// Groups without a :channel_id parameter address the default channel.
+func registerAnalyticsRoutes(analytics *gin.RouterGroup) {
+	analytics.GET("/summary", allowViewer, handleAnalyticsSummary)
+	analytics.GET("/daily", allowViewer, handleAnalyticsDaily)
+	analytics.GET("/files", allowViewer, handleAnalyticsFiles)
+	analytics.GET("/top", allowViewer, handleAnalyticsTop)
 }
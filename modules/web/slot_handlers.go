@@ -0,0 +1,213 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ScheduleSlotItemResponse is a slot block playlist entry enriched with filepath
+type ScheduleSlotItemResponse struct {
+	ID       int64  `json:"id"`
+	FileID   string `json:"file_id"`
+	FilePath string `json:"filepath"`
+	Position int    `json:"position"`
+}
+
+// ScheduleSlotResponse is a time slot together with its block playlist
+type ScheduleSlotResponse struct {
+	ID          int64                      `json:"id"`
+	Name        string                     `json:"name"`
+	StartTime   string                     `json:"start_time"`
+	EndTime     string                     `json:"end_time"`
+	Days        string                     `json:"days"`
+	Priority    int                        `json:"priority"`
+	Enabled     int                        `json:"enabled"`
+	LastRunDate string                     `json:"last_run_date"`
+	CreatedAt   int64                      `json:"created_at"`
+	Items       []ScheduleSlotItemResponse `json:"items"`
+}
+
+func enrichScheduleSlot(slot *models.ScheduleSlot) ScheduleSlotResponse {
+	response := ScheduleSlotResponse{
+		ID:          slot.ID,
+		Name:        slot.Name,
+		StartTime:   slot.StartTime,
+		EndTime:     slot.EndTime,
+		Days:        slot.Days,
+		Priority:    slot.Priority,
+		Enabled:     slot.Enabled,
+		LastRunDate: slot.LastRunDate,
+		CreatedAt:   slot.CreatedAt,
+		Items:       []ScheduleSlotItemResponse{},
+	}
+
+	items, _ := streamer.GetScheduleSlotItems(slot.ID)
+	for _, item := range items {
+		filePath, _ := streamer.GetFilePathByID(item.FileID)
+		response.Items = append(response.Items, ScheduleSlotItemResponse{
+			ID:       item.ID,
+			FileID:   item.FileID,
+			FilePath: filePath,
+			Position: item.Position,
+		})
+	}
+
+	return response
+}
+
+// handleSlotCreate creates a new time slot in the programming grid
+func handleSlotCreate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleSlotCreate",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	var req struct {
+		Name      string   `json:"name" binding:"required"`
+		StartTime string   `json:"start_time" binding:"required"`
+		EndTime   string   `json:"end_time"`
+		Days      string   `json:"days" binding:"required"`
+		Priority  int      `json:"priority"`
+		FileIDs   []string `json:"file_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request body: name, start_time, days and file_ids are required",
+		})
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"name":       req.Name,
+		"start_time": req.StartTime,
+		"end_time":   req.EndTime,
+		"days":       req.Days,
+	}).Info("Received request to create schedule slot")
+
+	slot := &models.ScheduleSlot{
+		Name:      req.Name,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Days:      req.Days,
+		Priority:  req.Priority,
+		ChannelID: channelID,
+	}
+
+	if err := streamer.CreateScheduleSlot(slot, req.FileIDs); err != nil {
+		logger.WithError(err).Error("Failed to create schedule slot")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("slot_id", slot.ID).Info("✓ Successfully created schedule slot")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Schedule slot created",
+		"slot":    enrichScheduleSlot(slot),
+	})
+}
+
+// handleSlotList returns all time slots with their block playlists
+func handleSlotList(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleSlotList",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	logger.Debug("Received request to list schedule slots")
+
+	slots, err := streamer.GetScheduleSlots(channelID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get schedule slots")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	enrichedSlots := make([]ScheduleSlotResponse, len(slots))
+	for i, slot := range slots {
+		enrichedSlots[i] = enrichScheduleSlot(&slot)
+	}
+
+	logger.WithField("slot_count", len(slots)).Info("✓ Successfully retrieved schedule slots")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"slots":   enrichedSlots,
+		"count":   len(enrichedSlots),
+	})
+}
+
+// handleSlotDelete removes a time slot from the programming grid
+func handleSlotDelete(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleSlotDelete",
+		"client_ip": c.ClientIP(),
+	})
+
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
+	slotID, err := strconv.ParseInt(c.Param("slot_id"), 10, 64)
+	if err != nil {
+		logger.Warn("Invalid slot id in request")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid slot id",
+		})
+		return
+	}
+
+	logger.WithField("slot_id", slotID).Info("Received request to delete schedule slot")
+
+	if err := streamer.DeleteScheduleSlot(channelID, slotID); err != nil {
+		logger.WithError(err).Error("Failed to delete schedule slot")
+		status := http.StatusInternalServerError
+		if err.Error() == "slot not found" {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("slot_id", slotID).Info("✓ Successfully deleted schedule slot")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Schedule slot deleted",
+		"slot_id": slotID,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"tv_streamer/helpers/logs"
 	"tv_streamer/modules/streamer"
 	"tv_streamer/modules/streamer/models"
@@ -14,14 +15,15 @@ import (
 
 // Response DTOs to maintain API compatibility with filepath
 type QueueItemResponse struct {
-	ID            int64  `json:"id"`
-	FileID        string `json:"file_id"`
-	FilePath      string `json:"filepath"`
-	AddedAt       int64  `json:"added_at"`
-	Played        int    `json:"played"`
-	PlayedAt      int64  `json:"played_at"`
-	QueuePosition int    `json:"queue_position"`
-	IsAd          int    `json:"is_ad"`
+	ID                    int64  `json:"id"`
+	FileID                string `json:"file_id"`
+	FilePath              string `json:"filepath"`
+	AddedAt               int64  `json:"added_at"`
+	Played                int    `json:"played"`
+	PlayedAt              int64  `json:"played_at"`
+	QueuePosition         int    `json:"queue_position"`
+	IsAd                  int    `json:"is_ad"`
+	ResumePositionSeconds int64  `json:"resume_position_seconds"` // 0 unless the video was interrupted
 }
 
 type ScheduleItemResponse struct {
@@ -43,20 +45,22 @@ type PlayHistoryResponse struct {
 	DurationSeconds int64  `json:"duration_seconds"`
 	IsAd            int    `json:"is_ad"`
 	SkipRequested   int    `json:"skip_requested"`
+	Interrupted     int    `json:"interrupted"`
 }
 
 // Helper functions to enrich models with filepath
 func enrichQueueItem(item *models.VideoQueue) QueueItemResponse {
 	filePath, _ := streamer.GetFilePathByID(item.FileID)
 	return QueueItemResponse{
-		ID:            item.ID,
-		FileID:        item.FileID,
-		FilePath:      filePath,
-		AddedAt:       item.AddedAt,
-		Played:        item.Played,
-		PlayedAt:      item.PlayedAt,
-		QueuePosition: item.QueuePosition,
-		IsAd:          item.IsAd,
+		ID:                    item.ID,
+		FileID:                item.FileID,
+		FilePath:              filePath,
+		AddedAt:               item.AddedAt,
+		Played:                item.Played,
+		PlayedAt:              item.PlayedAt,
+		QueuePosition:         item.QueuePosition,
+		IsAd:                  item.IsAd,
+		ResumePositionSeconds: item.ResumePositionSeconds,
 	}
 }
 
@@ -85,6 +89,7 @@ func enrichPlayHistory(item *models.PlayHistory) PlayHistoryResponse {
 		DurationSeconds: item.DurationSeconds,
 		IsAd:            item.IsAd,
 		SkipRequested:   item.SkipRequested,
+		Interrupted:     item.Interrupted,
 	}
 }
 
@@ -96,9 +101,24 @@ func handleStreamNext(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	logger.Info("Received request to skip to next video")
 
-	player := streamer.GetPersistentPlayer()
+	player, err := streamer.GetChannelPlayer(channelID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get channel player")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	if err := player.Skip(); err != nil {
 		logger.WithError(err).Error("Failed to skip to next video")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -123,6 +143,12 @@ func handleStreamAdd(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	filepath := c.Query("file")
 	if filepath == "" {
 		logger.Warn("Missing 'file' parameter in request")
@@ -135,9 +161,9 @@ func handleStreamAdd(c *gin.Context) {
 
 	logger.WithField("filepath", filepath).Info("Received request to add video to queue")
 
-	if err := streamer.AddToQueue(filepath, false); err != nil {
+	if err := streamer.AddToQueue(channelID, filepath, false); err != nil {
 		logger.WithError(err).Error("Failed to add video to queue")
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(airingErrorStatus(err), gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -160,9 +186,15 @@ func handleStreamQueue(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	logger.Debug("Received request to get queue")
 
-	queue, err := streamer.GetQueue()
+	queue, err := streamer.GetQueue(channelID)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get queue")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -194,9 +226,24 @@ func handleStreamStatus(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	logger.Debug("Received request to get player status")
 
-	player := streamer.GetPersistentPlayer()
+	player, err := streamer.GetChannelPlayer(channelID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get channel player")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	status := player.GetStatus()
 
 	logger.WithField("running", status["running"]).Info("✓ Successfully retrieved player status")
@@ -214,6 +261,12 @@ func handleInjectAd(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	filepath := c.Query("file")
 	if filepath == "" {
 		logger.Warn("Missing 'file' parameter in request")
@@ -226,9 +279,10 @@ func handleInjectAd(c *gin.Context) {
 
 	logger.WithField("filepath", filepath).Info("Received request to inject ad")
 
-	if err := streamer.InjectAd(filepath); err != nil {
+	pod, err := streamer.InjectAd(channelID, filepath)
+	if err != nil {
 		logger.WithError(err).Error("Failed to inject ad")
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(airingErrorStatus(err), gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -240,6 +294,7 @@ func handleInjectAd(c *gin.Context) {
 		"success": true,
 		"message": "Ad injected successfully",
 		"file":    filepath,
+		"pod_id":  pod.ID,
 	})
 }
 
@@ -251,6 +306,12 @@ func handleStreamHistory(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	limitStr := c.DefaultQuery("limit", "50")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 {
@@ -259,7 +320,7 @@ func handleStreamHistory(c *gin.Context) {
 
 	logger.WithField("limit", limit).Debug("Received request to get play history")
 
-	history, err := streamer.GetPlayHistory(limit)
+	history, err := streamer.GetPlayHistory(channelID, limit)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get play history")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -291,6 +352,12 @@ func handleScanVideos(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	directory := c.Query("directory")
 	if directory == "" {
 		logger.Warn("Missing 'directory' parameter in request")
@@ -301,31 +368,82 @@ func handleScanVideos(c *gin.Context) {
 		return
 	}
 
-	logger.WithField("directory", directory).Info("Received request to scan directory")
+	options := streamer.ScanOptions{
+		Mode:     c.DefaultQuery("mode", streamer.ScanModeQueue),
+		Include:  splitQueryList(c.QueryArray("include")),
+		Exclude:  splitQueryList(c.QueryArray("exclude")),
+		MaxDepth: -1,
+		DryRun:   c.Query("dry_run") == "true" || c.Query("dry_run") == "1",
+	}
+	if depth := c.Query("depth"); depth != "" {
+		maxDepth, err := strconv.Atoi(depth)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid depth",
+			})
+			return
+		}
+		options.MaxDepth = maxDepth
+	}
+
+	logger.WithFields(logrus.Fields{
+		"directory": directory,
+		"mode":      options.Mode,
+		"dry_run":   options.DryRun,
+	}).Info("Received request to scan directory")
 
-	count, err := streamer.ScanAndAddVideos(directory, nil)
+	result, err := streamer.ScanVideos(channelID, directory, options)
 	if err != nil {
 		logger.WithError(err).Error("Failed to scan directory")
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	// Files that went where the mode puts them
+	videosAdded := result.LibraryAdded
+	switch result.Mode {
+	case streamer.ScanModeQueue:
+		videosAdded = result.Queued
+	case streamer.ScanModeSchedule:
+		videosAdded = result.Scheduled
+	}
+
 	logger.WithFields(logrus.Fields{
 		"directory":    directory,
-		"videos_added": count,
+		"videos_added": videosAdded,
 	}).Info("✓ Successfully scanned directory")
 
+	message := "Directory scanned successfully"
+	if result.DryRun {
+		message = "Dry run, nothing was changed"
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":      true,
-		"message":      "Directory scanned successfully",
-		"videos_added": count,
+		"message":      message,
+		"videos_added": videosAdded,
 		"directory":    directory,
+		"scan":         result,
 	})
 }
 
+// splitQueryList flattens repeated and comma-separated query values
+func splitQueryList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
 // handleClearPlayed removes all played items from the queue
 func handleClearPlayed(c *gin.Context) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
@@ -334,9 +452,15 @@ func handleClearPlayed(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	logger.Info("Received request to clear played items from queue")
 
-	deletedCount, err := streamer.ClearPlayedFromQueue()
+	deletedCount, err := streamer.ClearPlayedFromQueue(channelID)
 	if err != nil {
 		logger.WithError(err).Error("Failed to clear played items")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -362,6 +486,12 @@ func handleScheduleAdd(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	filepath := c.Query("file")
 	if filepath == "" {
 		logger.Warn("Missing 'file' parameter in request")
@@ -374,9 +504,9 @@ func handleScheduleAdd(c *gin.Context) {
 
 	logger.WithField("filepath", filepath).Info("Received request to add video to schedule")
 
-	if err := streamer.AddToSchedule(filepath); err != nil {
+	if err := streamer.AddToSchedule(channelID, filepath); err != nil {
 		logger.WithError(err).Error("Failed to add video to schedule")
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(airingErrorStatus(err), gin.H{
 			"success": false,
 			"error":   err.Error(),
 		})
@@ -399,9 +529,15 @@ func handleScheduleGet(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	logger.Debug("Received request to get schedule")
 
-	schedule, err := streamer.GetSchedule()
+	schedule, err := streamer.GetSchedule(channelID)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get schedule")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -433,6 +569,12 @@ func handleScheduleRemove(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	fileID := c.Query("file_id")
 	if fileID == "" {
 		logger.Warn("Missing 'file_id' parameter in request")
@@ -445,7 +587,7 @@ func handleScheduleRemove(c *gin.Context) {
 
 	logger.WithField("file_id", fileID).Info("Received request to remove video from schedule")
 
-	if err := streamer.RemoveFromSchedule(fileID); err != nil {
+	if err := streamer.RemoveFromSchedule(channelID, fileID); err != nil {
 		logger.WithError(err).Error("Failed to remove video from schedule")
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -470,9 +612,15 @@ func handleScheduleClear(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	logger.Info("Received request to clear schedule")
 
-	deletedCount, err := streamer.ClearSchedule()
+	deletedCount, err := streamer.ClearSchedule(channelID)
 	if err != nil {
 		logger.WithError(err).Error("Failed to clear schedule")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -498,9 +646,15 @@ func handleScheduleReset(c *gin.Context) {
 		"client_ip": c.ClientIP(),
 	})
 
+	channelID, ok := resolveChannelID(c)
+	if !ok {
+		return
+	}
+	logger = logger.WithField("channel_id", channelID)
+
 	logger.Info("Received request to reset schedule position")
 
-	if err := streamer.ResetSchedulePosition(); err != nil {
+	if err := streamer.ResetSchedulePosition(channelID); err != nil {
 		logger.WithError(err).Error("Failed to reset schedule position")
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
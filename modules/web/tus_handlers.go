@@ -0,0 +1,304 @@
+package web
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"tv_streamer/helpers"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// tus resumable upload protocol, https://tus.io/protocols/resumable-upload
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// tusHeaders are the request and response headers of the tus protocol,
+// browsers need them allowed and exposed by CORS
+var (
+	tusRequestHeaders  = []string{"Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset"}
+	tusResponseHeaders = []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-File-Id", "Upload-Validation-Status"}
+)
+
+// tusResumable sets the protocol version on every response and rejects
+// requests of other versions, OPTIONS is used to discover the version
+func tusResumable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+			c.Header("Tus-Version", tusVersion)
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Tus-Resumable %s is required", tusVersion),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// tusMaxSize returns upload.max_file_size_mb in bytes
+func tusMaxSize() int64 {
+	return int64(helpers.GetConfig().Upload.MaxFileSizeMB) * 1024 * 1024
+}
+
+// parseTusMetadata decodes Upload-Metadata, comma separated pairs of a key
+// and a base64 value
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value of %s", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// setTusOffset sets the state of a session on the response
+func setTusOffset(c *gin.Context, session *models.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.ReceivedSize, 10))
+	c.Header("Upload-Expires", streamer.UploadSessionExpiry(session).UTC().Format(http.TimeFormat))
+}
+
+// handleTusOptions reports the supported protocol version and extensions
+func handleTusOptions(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(tusMaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// handleTusCreate starts an upload session, the file name is taken from the
+// filename (or name) metadata
+func handleTusCreate(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleTusCreate",
+		"client_ip": c.ClientIP(),
+	})
+
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Upload-Length must be a positive number of bytes",
+		})
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Upload-Metadata must contain the filename",
+		})
+		return
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"filename": filename,
+		"size":     size,
+	})
+
+	if err := streamer.ValidateUpload(filename, size); err != nil {
+		status := http.StatusBadRequest
+		if size > tusMaxSize() {
+			status = http.StatusRequestEntityTooLarge
+		}
+		logger.WithError(err).Warn("Upload rejected")
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	session, err := streamer.CreateUploadSession(currentIdentity(c).UserID, filename, size)
+	if err != nil {
+		logger.WithError(err).Error("Failed to create upload session")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.WithField("session_id", session.SessionID).Info("Upload session initialized")
+
+	setTusOffset(c, session)
+	c.Header("Location", "/api/uploads/tus/"+session.SessionID)
+	c.JSON(http.StatusCreated, gin.H{
+		"success":    true,
+		"session_id": session.SessionID,
+	})
+}
+
+// handleTusHead returns the offset to resume an upload at
+func handleTusHead(c *gin.Context) {
+	session, err := findUploadSession(currentIdentity(c), c.Param("session_id"))
+	if err != nil {
+		c.Status(uploadErrorStatus(err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Length", strconv.FormatInt(session.TotalSize, 10))
+	setTusOffset(c, session)
+	c.Status(http.StatusOK)
+}
+
+// handleTusPatch appends the request body at Upload-Offset. When the last
+// byte arrived the file is stored like a WebSocket upload and validated in
+// the background, its file ID is returned in Upload-File-Id and
+// Upload-Validation-Status is processing.
+func handleTusPatch(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "web",
+		"handler":    "handleTusPatch",
+		"client_ip":  c.ClientIP(),
+		"session_id": c.Param("session_id"),
+	})
+
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Content-Type must be %s", tusContentType),
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Upload-Offset must be a number of bytes",
+		})
+		return
+	}
+
+	session, err := findUploadSession(currentIdentity(c), c.Param("session_id"))
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if offset != session.ReceivedSize {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Upload-Offset %d doesn't match the received size of %d bytes", offset, session.ReceivedSize),
+		})
+		return
+	}
+
+	written, err := streamer.WriteUploadStream(session.SessionID, offset, c.Request.Body)
+	if written != nil {
+		logger = logger.WithFields(logrus.Fields{
+			"received_bytes": written.ReceivedSize,
+			"total_bytes":    written.TotalSize,
+		})
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Failed to write upload")
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Debug("Upload data received and written")
+
+	setTusOffset(c, written)
+	if !written.IsComplete() {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	session, err = streamer.CompleteUploadSession(session.SessionID)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to complete upload")
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	fileID, err := storeUploadedFile(session)
+	if err != nil {
+		logger.WithError(err).Error("Failed to store uploaded file")
+		os.Remove(session.TempPath)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Failed to store file: %s", err.Error()),
+		})
+		return
+	}
+
+	streamer.QueueUploadValidation(session.SessionID, fileID, session.Filename)
+
+	logger.WithField("file_id", fileID).Info("✓ Upload completed, validating in the background")
+	c.Header("Upload-File-Id", fileID)
+	c.Header("Upload-Validation-Status", streamer.ValidationProcessing)
+	c.Status(http.StatusNoContent)
+}
+
+// handleTusDelete aborts an upload and removes what was received
+func handleTusDelete(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "web",
+		"handler":    "handleTusDelete",
+		"client_ip":  c.ClientIP(),
+		"session_id": c.Param("session_id"),
+	})
+
+	if _, err := findUploadSession(currentIdentity(c), c.Param("session_id")); err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if err := streamer.DeleteUploadSession(c.Param("session_id")); err != nil {
+		logger.WithError(err).Error("Failed to abort upload session")
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("✓ Upload session aborted")
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth"
+	"tv_streamer/modules/streamer"
+	"tv_streamer/modules/streamer/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UploadSessionResponse is the state of an upload session
+type UploadSessionResponse struct {
+	SessionID    string               `json:"session_id"`
+	UserID       int64                `json:"user_id"`
+	Filename     string               `json:"filename"`
+	TotalSize    int64                `json:"total_size"`
+	ReceivedSize int64                `json:"received_size"`
+	Missing      []streamer.ByteRange `json:"missing"`
+	CreatedAt    int64                `json:"created_at"`
+	UpdatedAt    int64                `json:"updated_at"`
+}
+
+func enrichUploadSession(session *models.UploadSession) (UploadSessionResponse, error) {
+	missing, err := streamer.MissingUploadRanges(session)
+	if err != nil {
+		return UploadSessionResponse{}, err
+	}
+	return UploadSessionResponse{
+		SessionID:    session.SessionID,
+		UserID:       session.UserID,
+		Filename:     session.Filename,
+		TotalSize:    session.TotalSize,
+		ReceivedSize: session.ReceivedSize,
+		Missing:      missing,
+		CreatedAt:    session.CreatedAt,
+		UpdatedAt:    session.UpdatedAt,
+	}, nil
+}
+
+// uploadErrorStatus maps upload session errors to HTTP status codes
+func uploadErrorStatus(err error) int {
+	if errors.Is(err, streamer.ErrUploadSessionNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, streamer.ErrUploadOutOfRange) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// handleUploadSessionList returns the upload sessions of the user, admins
+// see every session
+func handleUploadSessionList(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":    "web",
+		"handler":   "handleUploadSessionList",
+		"client_ip": c.ClientIP(),
+	})
+
+	identity := currentIdentity(c)
+	sessions, err := streamer.GetUploadSessions(identity.UserID, identity.HasRole(auth.RoleAdmin))
+	if err != nil {
+		logger.WithError(err).Error("Failed to get upload sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response := make([]UploadSessionResponse, 0, len(sessions))
+	for i := range sessions {
+		enriched, err := enrichUploadSession(&sessions[i])
+		if err != nil {
+			logger.WithError(err).WithField("session_id", sessions[i].SessionID).Warn("Skipping upload session")
+			continue
+		}
+		response = append(response, enriched)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"sessions": response,
+		"count":    len(response),
+	})
+}
+
+// handleUploadSessionGet returns the received offset and missing ranges of
+// an upload session
+func handleUploadSessionGet(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "web",
+		"handler":    "handleUploadSessionGet",
+		"client_ip":  c.ClientIP(),
+		"session_id": c.Param("session_id"),
+	})
+
+	session, err := findUploadSession(currentIdentity(c), c.Param("session_id"))
+	if err != nil {
+		logger.WithError(err).Debug("Upload session not available")
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	response, err := enrichUploadSession(session)
+	if err != nil {
+		logger.WithError(err).Error("Failed to read received ranges")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"session": response,
+	})
+}
+
+// handleUploadSessionDelete aborts an upload session
+func handleUploadSessionDelete(c *gin.Context) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "web",
+		"handler":    "handleUploadSessionDelete",
+		"client_ip":  c.ClientIP(),
+		"session_id": c.Param("session_id"),
+	})
+
+	logger.Info("Received request to abort upload session")
+
+	if _, err := findUploadSession(currentIdentity(c), c.Param("session_id")); err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if err := streamer.DeleteUploadSession(c.Param("session_id")); err != nil {
+		logger.WithError(err).Error("Failed to abort upload session")
+		c.JSON(uploadErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	logger.Info("✓ Successfully aborted upload session")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Upload session aborted",
+	})
+}
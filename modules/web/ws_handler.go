@@ -2,9 +2,10 @@ package web
 
 import (
 	"encoding/json"
-	"net/http"
+	"strings"
 	"time"
 	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -14,10 +15,7 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// Allow all origins for now (adjust in production)
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	CheckOrigin:     checkWebSocketOrigin,
 }
 
 // handleWebSocket handles WebSocket connections at /api/ws
@@ -44,6 +42,7 @@ func handleWebSocket(c *gin.Context) {
 
 	// Create and register the client (this also starts the write pump)
 	client := hub.NewClient(conn)
+	client.identity = currentIdentity(c)
 
 	// Send welcome message through the send channel
 	welcomeMsg := map[string]interface{}{
@@ -63,7 +62,7 @@ func handleWebSocket(c *gin.Context) {
 
 	// Configure connection for reading
 	conn.SetReadDeadline(time.Now().Add(pongWait))
-	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadLimit(wsReadLimit())
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
@@ -90,10 +89,29 @@ func handleWebSocket(c *gin.Context) {
 
 			// Parse message to determine type
 			handleClientMessage(client, message, logger)
+		} else if len(message) > 0 && messageType == websocket.BinaryMessage {
+			// Binary frames carry upload chunks
+			if canUpload(client, "upload_binary_chunk", logger) {
+				handleUploadBinaryChunk(client, message)
+			}
 		}
 	}
 }
 
+// canUpload returns true if the client may upload, others get an error
+// message. Uploads need the operator role, viewers may only follow the logs.
+func canUpload(client *Client, messageType string, logger *logrus.Entry) bool {
+	if client.identity.HasRole(auth.RoleOperator) {
+		return true
+	}
+	logger.WithField("message_type", messageType).Warn("Upload rejected, operator role required")
+	client.SendJSON(map[string]interface{}{
+		"type":    "error",
+		"message": "Uploads require the operator role",
+	})
+	return false
+}
+
 // handleClientMessage routes incoming WebSocket messages to appropriate handlers
 func handleClientMessage(client *Client, message []byte, logger *logrus.Entry) {
 	// Parse the message to determine its type
@@ -110,6 +128,10 @@ func handleClientMessage(client *Client, message []byte, logger *logrus.Entry) {
 		return
 	}
 
+	if strings.HasPrefix(baseMsg.Type, "upload_") && !canUpload(client, baseMsg.Type, logger) {
+		return
+	}
+
 	// Route message based on type
 	switch baseMsg.Type {
 	case "upload_init":
@@ -136,6 +158,22 @@ func handleClientMessage(client *Client, message []byte, logger *logrus.Entry) {
 		}
 		handleUploadComplete(client, msg)
 
+	case "upload_status":
+		var msg WSUploadStatusRequest
+		if err := json.Unmarshal(message, &msg); err != nil {
+			logger.WithError(err).Warn("Failed to parse upload_status message")
+			return
+		}
+		handleUploadStatus(client, msg)
+
+	case "upload_abort":
+		var msg WSUploadAbortMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
+			logger.WithError(err).Warn("Failed to parse upload_abort message")
+			return
+		}
+		handleUploadAbort(client, msg)
+
 	default:
 		logger.WithField("message_type", baseMsg.Type).Debug("Unknown message type")
 	}
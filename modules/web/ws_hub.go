@@ -1,10 +1,13 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"sync"
 	"time"
 	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth"
+	"tv_streamer/modules/streamer"
 
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
@@ -34,15 +37,23 @@ type WSMessage struct {
 
 type WSCurrentlyPlayingMessage struct {
 	Type        string `json:"type"`
+	ChannelID   int64  `json:"channel_id"`
 	FileID      string `json:"file_id"`
 	StartedTime int64  `json:"started_time"`
 }
 
 // Client represents a WebSocket client with its own send channel
 type Client struct {
-	hub  *WebSocketHub
-	conn *websocket.Conn
-	send chan []byte
+	hub      *WebSocketHub
+	conn     *websocket.Conn
+	send     chan []byte
+	identity *auth.Identity
+
+	// Upload acknowledgments have their own channel so log broadcasts can't
+	// crowd them out, pendingAcks counts those not written yet per session
+	acks        chan uploadAck
+	acksMu      sync.Mutex
+	pendingAcks map[string]int
 }
 
 // WebSocketHub manages WebSocket connections
@@ -99,6 +110,15 @@ func (c *Client) writePump() {
 				return
 			}
 
+		case ack := <-c.acks:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err := c.conn.WriteMessage(websocket.TextMessage, ack.data)
+			c.ackWritten(ack.sessionID)
+			if err != nil {
+				c.hub.logger.WithError(err).Warn("Failed to write upload acknowledgment to WebSocket client")
+				return
+			}
+
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -172,9 +192,11 @@ func (h *WebSocketHub) run() {
 // NewClient creates a new Client and starts its write pump
 func (h *WebSocketHub) NewClient(conn *websocket.Conn) *Client {
 	client := &Client{
-		hub:  h,
-		conn: conn,
-		send: make(chan []byte, sendBufferSize),
+		hub:         h,
+		conn:        conn,
+		send:        make(chan []byte, sendBufferSize),
+		acks:        make(chan uploadAck, uploadAckBufferSize),
+		pendingAcks: make(map[string]int),
 	}
 
 	// Register the client with the hub
@@ -209,9 +231,10 @@ func (h *WebSocketHub) BroadcastStructuredLog(logData *logs.StructuredLogMessage
 }
 
 // BroadcastCurrentlyPlaying sends currently playing info to all connected clients
-func (h *WebSocketHub) BroadcastCurrentlyPlaying(fileID string, startedTime int64) {
+func (h *WebSocketHub) BroadcastCurrentlyPlaying(channelID int64, fileID string, startedTime int64) {
 	msg := WSCurrentlyPlayingMessage{
 		Type:        "currently_playing",
+		ChannelID:   channelID,
 		FileID:      fileID,
 		StartedTime: startedTime,
 	}
@@ -225,6 +248,7 @@ func (h *WebSocketHub) BroadcastCurrentlyPlaying(fileID string, startedTime int6
 	select {
 	case h.broadcast <- data:
 		h.logger.WithFields(logrus.Fields{
+			"channel_id":   channelID,
 			"file_id":      fileID,
 			"started_time": startedTime,
 		}).Debug("Broadcasting currently_playing event")
@@ -240,3 +264,118 @@ func (h *WebSocketHub) GetClientCount() int {
 	defer h.mu.RUnlock()
 	return len(h.clients)
 }
+
+// Shutdown tells every client that the service is going away and closes the
+// connections. Clients that don't complete the close handshake before the
+// context expires are disconnected.
+func (h *WebSocketHub) Shutdown(ctx context.Context, restart bool) {
+	code := websocket.CloseGoingAway
+	reason := "server shutting down"
+	if restart {
+		code = websocket.CloseServiceRestart
+		reason = "server restarting"
+	}
+
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	h.logger.WithFields(logrus.Fields{
+		"clients": len(clients),
+		"restart": restart,
+	}).Info("Closing WebSocket connections...")
+
+	closeMsg := websocket.FormatCloseMessage(code, reason)
+	for _, client := range clients {
+		// WriteControl is safe to call concurrently with the write pump
+		if err := client.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(writeWait)); err != nil {
+			h.logger.WithError(err).Debug("Failed to send close frame to WebSocket client")
+		}
+	}
+
+	// The read loop of each connection unregisters the client once the peer
+	// answers the close frame
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for h.GetClientCount() > 0 {
+		select {
+		case <-ctx.Done():
+			h.logger.WithField("clients", h.GetClientCount()).Warn("WebSocket clients did not close in time, disconnecting")
+			for _, client := range clients {
+				client.conn.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+
+	h.logger.Info("✓ WebSocket connections closed")
+}
+
+// BroadcastAdPod sends an ad pod start or end event to all connected clients
+func (h *WebSocketHub) BroadcastAdPod(event streamer.AdPodEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to marshal ad pod message")
+		return
+	}
+
+	select {
+	case h.broadcast <- data:
+		h.logger.WithFields(logrus.Fields{
+			"type":       event.Type,
+			"channel_id": event.ChannelID,
+			"pod_id":     event.PodID,
+		}).Debug("Broadcasting ad pod event")
+	default:
+		// Broadcast channel is full, log warning
+		h.logger.Warn("Broadcast channel full, dropping ad pod message")
+	}
+}
+
+// BroadcastIngest sends a library ingest event to all connected clients
+func (h *WebSocketHub) BroadcastIngest(event streamer.IngestEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to marshal ingest message")
+		return
+	}
+
+	select {
+	case h.broadcast <- data:
+		h.logger.WithFields(logrus.Fields{
+			"type":     event.Type,
+			"file_id":  event.FileID,
+			"filepath": event.FilePath,
+		}).Debug("Broadcasting ingest event")
+	default:
+		// Broadcast channel is full, log warning
+		h.logger.Warn("Broadcast channel full, dropping ingest message")
+	}
+}
+
+// BroadcastUploadValidated sends the validation result of an upload to all
+// connected clients
+func (h *WebSocketHub) BroadcastUploadValidated(event streamer.UploadValidatedEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to marshal upload validation message")
+		return
+	}
+
+	select {
+	case h.broadcast <- data:
+		h.logger.WithFields(logrus.Fields{
+			"session_id": event.SessionID,
+			"file_id":    event.FileID,
+			"success":    event.Success,
+		}).Debug("Broadcasting upload validation event")
+	default:
+		// Broadcast channel is full, log warning
+		h.logger.Warn("Broadcast channel full, dropping upload validation message")
+	}
+}
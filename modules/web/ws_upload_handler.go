@@ -2,35 +2,43 @@ package web
 
 import (
 	"crypto/md5"
-	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
 	"tv_streamer/helpers"
 	"tv_streamer/helpers/logs"
+	"tv_streamer/modules/auth"
+	"tv_streamer/modules/streamer"
 	"tv_streamer/modules/streamer/models"
 
 	"github.com/sirupsen/logrus"
 )
 
-// UploadSession represents an active file upload session
-type UploadSession struct {
-	SessionID       string
-	Filename        string
-	TotalSize       int64
-	ReceivedSize    int64
-	File            *os.File
-	TempFilePath    string
-	StartTime       time.Time
-	LastChunkTime   time.Time
-}
+const (
+	// defaultUploadChunkSize is used when upload.chunk_size_bytes is not set
+	defaultUploadChunkSize = 256 * 1024
+
+	// defaultUploadChunkWindow is used when upload.chunk_window is not set
+	defaultUploadChunkWindow = 8
+
+	// uploadAckBufferSize is the size of the acknowledgment channel of a
+	// client and the largest chunk window
+	uploadAckBufferSize = 128
+
+	// binaryChunkVersion is the version of the binary chunk frame
+	binaryChunkVersion = 1
+
+	// binaryChunkHeaderSize is the size of the binary chunk frame header:
+	// version (1 byte), session ID (32 ASCII characters), offset (uint64)
+	// and length (uint32), big-endian
+	binaryChunkHeaderSize = 1 + 32 + 8 + 4
+)
 
 // WebSocket message types for file upload
 type WSUploadInitMessage struct {
@@ -39,11 +47,16 @@ type WSUploadInitMessage struct {
 	FileSize int64  `json:"file_size"`
 }
 
+// WSUploadChunkMessage carries a chunk. Offset places it in the file, chunks
+// may arrive in any order; without it the chunk is appended. Checksum is the
+// hex SHA-256 of the decoded chunk.
 type WSUploadChunkMessage struct {
 	Type      string `json:"type"`
 	SessionID string `json:"session_id"`
 	ChunkData string `json:"chunk_data"` // base64 encoded
 	ChunkNum  int    `json:"chunk_num"`
+	Offset    *int64 `json:"offset"`
+	Checksum  string `json:"checksum"`
 }
 
 type WSUploadCompleteMessage struct {
@@ -51,112 +64,243 @@ type WSUploadCompleteMessage struct {
 	SessionID string `json:"session_id"`
 }
 
+// WSUploadStatusRequest asks for the state of a session to resume it, also
+// after a reconnect
+type WSUploadStatusRequest struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+}
+
+// WSUploadAbortMessage cancels a session
+type WSUploadAbortMessage struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+}
+
+// WSUploadResponseMessage answers the session messages. Window and
+// MaxChunkSize tell new sessions how many chunks may be sent before waiting
+// for acknowledgments and how large a chunk may be. ValidationStatus is
+// processing for a completed upload, the upload policy result follows in an
+// upload_validated message.
 type WSUploadResponseMessage struct {
+	Type             string `json:"type"`
+	Success          bool   `json:"success"`
+	SessionID        string `json:"session_id,omitempty"`
+	Message          string `json:"message,omitempty"`
+	Error            string `json:"error,omitempty"`
+	FileID           string `json:"file_id,omitempty"`
+	Window           int    `json:"window,omitempty"`
+	MaxChunkSize     int    `json:"max_chunk_size,omitempty"`
+	ValidationStatus string `json:"validation_status,omitempty"`
+}
+
+// WSUploadChunkErrorMessage reports a chunk that was not written, binary
+// chunks have no number and are identified by their offset
+type WSUploadChunkErrorMessage struct {
 	Type      string `json:"type"`
 	Success   bool   `json:"success"`
-	SessionID string `json:"session_id,omitempty"`
-	Message   string `json:"message,omitempty"`
-	Error     string `json:"error,omitempty"`
-	FileID    string `json:"file_id,omitempty"`
+	SessionID string `json:"session_id"`
+	Error     string `json:"error"`
+	ChunkNum  *int   `json:"chunk_num,omitempty"`
+	Offset    *int64 `json:"offset,omitempty"`
 }
 
-// Active upload sessions (in production, use a more robust storage)
-var uploadSessions = make(map[string]*UploadSession)
+// WSUploadChunkAckMessage confirms a chunk. ReceivedSize is the offset the
+// upload continues at.
+type WSUploadChunkAckMessage struct {
+	Type         string `json:"type"`
+	Success      bool   `json:"success"`
+	SessionID    string `json:"session_id"`
+	Message      string `json:"message"`
+	ChunkNum     *int   `json:"chunk_num,omitempty"`
+	Offset       int64  `json:"offset"`
+	Length       int    `json:"length"`
+	ReceivedSize int64  `json:"received_size"`
+	TotalSize    int64  `json:"total_size"`
+}
 
-// handleUploadInit initializes a new file upload session
-func handleUploadInit(client *Client, msg WSUploadInitMessage) {
-	logger := logs.GetLogger().WithFields(logrus.Fields{
-		"module":   "web",
-		"handler":  "handleUploadInit",
-		"filename": msg.Filename,
-		"size":     msg.FileSize,
-	})
+// WSUploadStatusMessage is the state of a session: the offset to resume at
+// and the byte ranges still missing
+type WSUploadStatusMessage struct {
+	Type         string               `json:"type"`
+	Success      bool                 `json:"success"`
+	SessionID    string               `json:"session_id"`
+	Filename     string               `json:"filename"`
+	TotalSize    int64                `json:"total_size"`
+	ReceivedSize int64                `json:"received_size"`
+	Missing      []streamer.ByteRange `json:"missing"`
+	UpdatedAt    int64                `json:"updated_at"`
+	Window       int                  `json:"window"`
+	MaxChunkSize int                  `json:"max_chunk_size"`
+}
 
-	config := helpers.GetConfig()
+// errUploadAckTimeout is returned when an acknowledgment can't be queued in
+// time, the connection is closed then
+var errUploadAckTimeout = errors.New("upload acknowledgment could not be delivered")
 
-	// Validate file size
-	maxSize := int64(config.Upload.MaxFileSizeMB) * 1024 * 1024
-	if msg.FileSize > maxSize {
-		logger.Warn("File size exceeds maximum allowed")
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   fmt.Sprintf("File size exceeds maximum allowed size of %d MB", config.Upload.MaxFileSizeMB),
-		})
-		return
+// uploadAck is a chunk acknowledgment or chunk error queued for a session
+type uploadAck struct {
+	sessionID string
+	data      []byte
+}
+
+// sendUploadAck queues a chunk acknowledgment or chunk error. It waits up to
+// writeWait for room in the acknowledgment channel and closes the connection
+// if there is none, a client must never miss an answer to a chunk.
+func (c *Client) sendUploadAck(sessionID string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
 	}
 
-	// Validate file extension
-	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(msg.Filename), "."))
-	allowed := false
-	for _, allowedExt := range config.Upload.AllowedFormats {
-		if ext == allowedExt {
-			allowed = true
-			break
-		}
+	c.acksMu.Lock()
+	c.pendingAcks[sessionID]++
+	c.acksMu.Unlock()
+
+	timer := time.NewTimer(writeWait)
+	defer timer.Stop()
+
+	select {
+	case c.acks <- uploadAck{sessionID: sessionID, data: data}:
+		return nil
+	case <-timer.C:
+		c.ackWritten(sessionID)
+		c.conn.Close()
+		return errUploadAckTimeout
 	}
-	if !allowed {
-		logger.Warn("File format not allowed")
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   fmt.Sprintf("File format '%s' not allowed. Allowed formats: %v", ext, config.Upload.AllowedFormats),
-		})
+}
+
+// ackWritten is called once an acknowledgment of the session was written
+func (c *Client) ackWritten(sessionID string) {
+	c.acksMu.Lock()
+	defer c.acksMu.Unlock()
+
+	if c.pendingAcks[sessionID] <= 1 {
+		delete(c.pendingAcks, sessionID)
 		return
 	}
+	c.pendingAcks[sessionID]--
+}
 
-	// Create upload directory if it doesn't exist
-	if err := os.MkdirAll(config.Upload.UploadDir, 0755); err != nil {
-		logger.WithError(err).Error("Failed to create upload directory")
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   "Failed to create upload directory",
-		})
-		return
+// unwrittenAcks returns the number of acknowledgments of the session that are
+// queued but not written yet
+func (c *Client) unwrittenAcks(sessionID string) int {
+	c.acksMu.Lock()
+	defer c.acksMu.Unlock()
+	return c.pendingAcks[sessionID]
+}
+
+// sendUploadError reports a failed upload message to the client
+func sendUploadError(client *Client, sessionID, message string) {
+	client.SendJSON(WSUploadResponseMessage{
+		Type:      "upload_error",
+		Success:   false,
+		SessionID: sessionID,
+		Error:     message,
+	})
+}
+
+// sendUploadChunkError reports a chunk that was not written
+func sendUploadChunkError(client *Client, sessionID string, chunkNum *int, offset int64, message string) {
+	msg := WSUploadChunkErrorMessage{
+		Type:      "upload_error",
+		Success:   false,
+		SessionID: sessionID,
+		Error:     message,
+		ChunkNum:  chunkNum,
+	}
+	if offset >= 0 {
+		msg.Offset = &offset
+	}
+	if err := client.sendUploadAck(sessionID, msg); err != nil {
+		logs.GetLogger().WithFields(logrus.Fields{
+			"module":     "web",
+			"function":   "sendUploadChunkError",
+			"session_id": sessionID,
+		}).WithError(err).Warn("Failed to send chunk error, connection closed")
+	}
+}
+
+// uploadChunkSize returns the largest chunk clients should send
+func uploadChunkSize() int {
+	if size := helpers.GetConfig().Upload.ChunkSizeBytes; size > 0 {
+		return size
 	}
+	return defaultUploadChunkSize
+}
 
-	// Generate session ID
-	sessionID := generateSessionID(msg.Filename)
+// uploadChunkWindow returns how many chunks a client may send before waiting
+// for acknowledgments, at most as many as the acknowledgment channel holds
+func uploadChunkWindow() int {
+	window := helpers.GetConfig().Upload.ChunkWindow
+	if window <= 0 {
+		window = defaultUploadChunkWindow
+	}
+	if window > uploadAckBufferSize {
+		window = uploadAckBufferSize
+	}
+	return window
+}
 
-	// Create temporary file
-	tempFilePath := filepath.Join(config.Upload.UploadDir, fmt.Sprintf("%s.tmp", sessionID))
-	file, err := os.Create(tempFilePath)
+// wsReadLimit returns the largest message accepted from clients, big enough
+// for a chunk of upload.chunk_size_bytes in base64
+func wsReadLimit() int64 {
+	limit := int64(maxMessageSize)
+	if size := int64(uploadChunkSize())*4/3 + 4096; size > limit {
+		limit = size
+	}
+	return limit
+}
+
+// canAccessUploadSession returns true if the session was started by the
+// identity, admins may access every session
+func canAccessUploadSession(identity *auth.Identity, session *models.UploadSession) bool {
+	return session.UserID == identity.UserID || identity.HasRole(auth.RoleAdmin)
+}
+
+// findUploadSession returns the session if it exists and the identity may
+// access it, other sessions are reported as not found
+func findUploadSession(identity *auth.Identity, sessionID string) (*models.UploadSession, error) {
+	session, err := streamer.GetUploadSession(sessionID)
 	if err != nil {
-		logger.WithError(err).Error("Failed to create temporary file")
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   "Failed to create temporary file",
-		})
-		return
+		return nil, err
+	}
+	if !canAccessUploadSession(identity, session) {
+		return nil, streamer.ErrUploadSessionNotFound
 	}
+	return session, nil
+}
 
-	// Create upload session
-	session := &UploadSession{
-		SessionID:     sessionID,
-		Filename:      msg.Filename,
-		TotalSize:     msg.FileSize,
-		ReceivedSize:  0,
-		File:          file,
-		TempFilePath:  tempFilePath,
-		StartTime:     time.Now(),
-		LastChunkTime: time.Now(),
+// handleUploadInit initializes a new file upload session
+func handleUploadInit(client *Client, msg WSUploadInitMessage) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":   "web",
+		"handler":  "handleUploadInit",
+		"filename": msg.Filename,
+		"size":     msg.FileSize,
+	})
+
+	session, err := streamer.CreateUploadSession(client.identity.UserID, msg.Filename, msg.FileSize)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to create upload session")
+		sendUploadError(client, "", err.Error())
+		return
 	}
-	uploadSessions[sessionID] = session
 
-	logger.WithField("session_id", sessionID).Info("Upload session initialized")
+	logger.WithField("session_id", session.SessionID).Info("Upload session initialized")
 
 	// Send success response
 	client.SendJSON(WSUploadResponseMessage{
-		Type:      "upload_init_success",
-		Success:   true,
-		SessionID: sessionID,
-		Message:   "Upload session initialized. Ready to receive chunks.",
+		Type:         "upload_init_success",
+		Success:      true,
+		SessionID:    session.SessionID,
+		Message:      "Upload session initialized. Ready to receive chunks.",
+		Window:       uploadChunkWindow(),
+		MaxChunkSize: uploadChunkSize(),
 	})
 }
 
-// handleUploadChunk processes a file chunk
+// handleUploadChunk processes a base64 file chunk
 func handleUploadChunk(client *Client, msg WSUploadChunkMessage) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
 		"module":     "web",
@@ -165,200 +309,255 @@ func handleUploadChunk(client *Client, msg WSUploadChunkMessage) {
 		"chunk_num":  msg.ChunkNum,
 	})
 
-	// Get upload session
-	session, exists := uploadSessions[msg.SessionID]
-	if !exists {
-		logger.Warn("Upload session not found")
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   "Upload session not found",
-		})
-		return
+	offset := int64(-1)
+	if msg.Offset != nil {
+		offset = *msg.Offset
+		if offset < 0 {
+			sendUploadChunkError(client, msg.SessionID, &msg.ChunkNum, -1, "Chunk offset can't be negative")
+			return
+		}
 	}
 
 	// Decode base64 chunk data
 	chunkData, err := base64Decode(msg.ChunkData)
 	if err != nil {
 		logger.WithError(err).Error("Failed to decode chunk data")
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   "Failed to decode chunk data",
-		})
+		sendUploadChunkError(client, msg.SessionID, &msg.ChunkNum, offset, "Failed to decode chunk data")
 		return
 	}
 
-	// Write chunk to file
-	n, err := session.File.Write(chunkData)
+	receiveUploadChunk(client, logger, msg.SessionID, &msg.ChunkNum, offset, chunkData, msg.Checksum)
+}
+
+// parseBinaryChunk splits a binary chunk frame into the session ID, the
+// offset and the data
+func parseBinaryChunk(frame []byte) (string, int64, []byte, error) {
+	if len(frame) < binaryChunkHeaderSize {
+		return "", -1, nil, fmt.Errorf("binary chunk is shorter than its %d byte header", binaryChunkHeaderSize)
+	}
+	sessionID := string(frame[1:33])
+	if frame[0] != binaryChunkVersion {
+		return sessionID, -1, nil, fmt.Errorf("binary chunk version %d not supported, expected %d", frame[0], binaryChunkVersion)
+	}
+
+	offset := binary.BigEndian.Uint64(frame[33:41])
+	length := binary.BigEndian.Uint32(frame[41:45])
+	data := frame[binaryChunkHeaderSize:]
+
+	if offset > math.MaxInt64 {
+		return sessionID, -1, nil, fmt.Errorf("binary chunk offset %d is too large", offset)
+	}
+	if int(length) != len(data) {
+		return sessionID, int64(offset), nil, fmt.Errorf("binary chunk announces %d bytes but carries %d", length, len(data))
+	}
+	return sessionID, int64(offset), data, nil
+}
+
+// handleUploadBinaryChunk processes a binary file chunk, the raw bytes behind
+// a header with the session ID, offset and length
+func handleUploadBinaryChunk(client *Client, frame []byte) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":  "web",
+		"handler": "handleUploadBinaryChunk",
+	})
+
+	sessionID, offset, data, err := parseBinaryChunk(frame)
 	if err != nil {
-		logger.WithError(err).Error("Failed to write chunk to file")
-		cleanupUploadSession(session)
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   "Failed to write chunk to file",
-		})
+		logger.WithError(err).Warn("Invalid binary chunk")
+		sendUploadChunkError(client, sessionID, nil, offset, err.Error())
+		return
+	}
+
+	receiveUploadChunk(client, logger.WithField("session_id", sessionID), sessionID, nil, offset, data, "")
+}
+
+// receiveUploadChunk writes a chunk of a text or binary frame and
+// acknowledges it. A negative offset appends the chunk.
+//
+// Chunks are handled in order, so when a chunk arrives every earlier one has
+// its acknowledgment queued. A client that waits for acknowledgments can't
+// have a full window of them still unwritten, such a chunk is rejected.
+func receiveUploadChunk(client *Client, logger *logrus.Entry, sessionID string, chunkNum *int, offset int64, data []byte, checksum string) {
+	if window := uploadChunkWindow(); client.unwrittenAcks(sessionID) >= window {
+		logger.WithField("window", window).Warn("Chunk window exceeded")
+		sendUploadChunkError(client, sessionID, chunkNum, offset,
+			fmt.Sprintf("Chunk window of %d exceeded, wait for acknowledgments before sending more chunks", window))
 		return
 	}
 
-	session.ReceivedSize += int64(n)
-	session.LastChunkTime = time.Now()
+	if _, err := findUploadSession(client.identity, sessionID); err != nil {
+		logger.WithError(err).Warn("Upload session not available")
+		sendUploadChunkError(client, sessionID, chunkNum, offset, err.Error())
+		return
+	}
+
+	session, err := streamer.WriteUploadChunk(sessionID, offset, data, checksum)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to write chunk")
+		sendUploadChunkError(client, sessionID, chunkNum, offset, err.Error())
+		return
+	}
+	if offset < 0 {
+		offset = session.ReceivedSize - int64(len(data))
+	}
 
 	logger.WithFields(logrus.Fields{
+		"offset":         offset,
 		"received_bytes": session.ReceivedSize,
 		"total_bytes":    session.TotalSize,
 		"progress_pct":   fmt.Sprintf("%.2f", float64(session.ReceivedSize)/float64(session.TotalSize)*100),
 	}).Debug("Chunk received and written")
 
+	message := fmt.Sprintf("Chunk at offset %d received", offset)
+	if chunkNum != nil {
+		message = fmt.Sprintf("Chunk %d received", *chunkNum)
+	}
+
 	// Send chunk acknowledgment
-	client.SendJSON(WSUploadResponseMessage{
-		Type:      "upload_chunk_ack",
-		Success:   true,
-		SessionID: msg.SessionID,
-		Message:   fmt.Sprintf("Chunk %d received", msg.ChunkNum),
+	err = client.sendUploadAck(sessionID, WSUploadChunkAckMessage{
+		Type:         "upload_chunk_ack",
+		Success:      true,
+		SessionID:    sessionID,
+		Message:      message,
+		ChunkNum:     chunkNum,
+		Offset:       offset,
+		Length:       len(data),
+		ReceivedSize: session.ReceivedSize,
+		TotalSize:    session.TotalSize,
 	})
+	if err != nil {
+		logger.WithError(err).Warn("Failed to send chunk acknowledgment, connection closed")
+	}
 }
 
-// handleUploadComplete finalizes the upload and validates the file
-func handleUploadComplete(client *Client, msg WSUploadCompleteMessage) {
+// handleUploadStatus reports the received offset and missing ranges of a
+// session so an interrupted upload can be resumed
+func handleUploadStatus(client *Client, msg WSUploadStatusRequest) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
 		"module":     "web",
-		"handler":    "handleUploadComplete",
+		"handler":    "handleUploadStatus",
 		"session_id": msg.SessionID,
 	})
 
-	// Get upload session
-	session, exists := uploadSessions[msg.SessionID]
-	if !exists {
-		logger.Warn("Upload session not found")
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   "Upload session not found",
-		})
+	session, err := findUploadSession(client.identity, msg.SessionID)
+	if err != nil {
+		logger.WithError(err).Warn("Upload session not available")
+		sendUploadError(client, msg.SessionID, err.Error())
 		return
 	}
 
-	// Close the file
-	if err := session.File.Close(); err != nil {
-		logger.WithError(err).Error("Failed to close file")
-		cleanupUploadSession(session)
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   "Failed to close file",
-		})
+	missing, err := streamer.MissingUploadRanges(session)
+	if err != nil {
+		logger.WithError(err).Error("Failed to read received ranges")
+		sendUploadError(client, msg.SessionID, err.Error())
 		return
 	}
 
-	// Verify file size
-	if session.ReceivedSize != session.TotalSize {
-		logger.WithFields(logrus.Fields{
-			"expected": session.TotalSize,
-			"received": session.ReceivedSize,
-		}).Warn("File size mismatch")
-		cleanupUploadSession(session)
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   fmt.Sprintf("File size mismatch: expected %d bytes, received %d bytes", session.TotalSize, session.ReceivedSize),
-		})
+	client.SendJSON(WSUploadStatusMessage{
+		Type:         "upload_status",
+		Success:      true,
+		SessionID:    session.SessionID,
+		Filename:     session.Filename,
+		TotalSize:    session.TotalSize,
+		ReceivedSize: session.ReceivedSize,
+		Missing:      missing,
+		UpdatedAt:    session.UpdatedAt,
+		Window:       uploadChunkWindow(),
+		MaxChunkSize: uploadChunkSize(),
+	})
+}
+
+// handleUploadAbort cancels a session and removes what was received
+func handleUploadAbort(client *Client, msg WSUploadAbortMessage) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "web",
+		"handler":    "handleUploadAbort",
+		"session_id": msg.SessionID,
+	})
+
+	if _, err := findUploadSession(client.identity, msg.SessionID); err != nil {
+		sendUploadError(client, msg.SessionID, err.Error())
+		return
+	}
+	if err := streamer.DeleteUploadSession(msg.SessionID); err != nil {
+		logger.WithError(err).Warn("Failed to abort upload session")
+		sendUploadError(client, msg.SessionID, err.Error())
 		return
 	}
 
-	logger.Info("File upload completed, starting validation...")
+	logger.Info("Upload session aborted")
+	client.SendJSON(WSUploadResponseMessage{
+		Type:      "upload_aborted",
+		Success:   true,
+		SessionID: msg.SessionID,
+		Message:   "Upload session aborted",
+	})
+}
+
+// handleUploadComplete finalizes the upload and stores the file, it is
+// validated in the background
+func handleUploadComplete(client *Client, msg WSUploadCompleteMessage) {
+	logger := logs.GetLogger().WithFields(logrus.Fields{
+		"module":     "web",
+		"handler":    "handleUploadComplete",
+		"session_id": msg.SessionID,
+	})
+
+	if _, err := findUploadSession(client.identity, msg.SessionID); err != nil {
+		logger.WithError(err).Warn("Upload session not available")
+		sendUploadError(client, msg.SessionID, err.Error())
+		return
+	}
 
-	// Validate the file
-	fileID, err := validateAndStoreFile(session)
+	// The session stays open while bytes are missing, so the client can send
+	// them and complete again
+	session, err := streamer.CompleteUploadSession(msg.SessionID)
 	if err != nil {
-		logger.WithError(err).Error("File validation failed")
-		cleanupUploadSession(session)
-		client.SendJSON(WSUploadResponseMessage{
-			Type:    "upload_error",
-			Success: false,
-			Error:   fmt.Sprintf("File validation failed: %s", err.Error()),
-		})
+		logger.WithError(err).Warn("Failed to complete upload")
+		sendUploadError(client, msg.SessionID, err.Error())
 		return
 	}
 
-	// Clean up session (but keep the file)
-	delete(uploadSessions, msg.SessionID)
+	fileID, err := storeUploadedFile(session)
+	if err != nil {
+		logger.WithError(err).Error("Failed to store uploaded file")
+		os.Remove(session.TempPath)
+		sendUploadError(client, msg.SessionID, fmt.Sprintf("Failed to store file: %s", err.Error()))
+		return
+	}
 
-	logger.WithField("file_id", fileID).Info("Upload completed successfully")
+	logger.WithField("file_id", fileID).Info("Upload completed, validating in the background...")
 
-	// Send success response
+	// Send success response, the validation result follows as upload_validated
 	client.SendJSON(WSUploadResponseMessage{
-		Type:    "upload_complete",
-		Success: true,
-		FileID:  fileID,
-		Message: "File uploaded and validated successfully. File marked as inactive.",
+		Type:             "upload_complete",
+		Success:          true,
+		SessionID:        msg.SessionID,
+		FileID:           fileID,
+		Message:          "File uploaded. It is validated against the upload policy in the background.",
+		ValidationStatus: streamer.ValidationProcessing,
 	})
+
+	streamer.QueueUploadValidation(session.SessionID, fileID, session.Filename)
 }
 
 // Helper functions
 
-func generateSessionID(filename string) string {
-	data := fmt.Sprintf("%s-%d", filename, time.Now().UnixNano())
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])[:32]
-}
-
 func base64Decode(data string) ([]byte, error) {
 	return base64.StdEncoding.DecodeString(data)
 }
 
-func cleanupUploadSession(session *UploadSession) {
-	if session.File != nil {
-		session.File.Close()
-	}
-	if session.TempFilePath != "" {
-		os.Remove(session.TempFilePath)
-	}
-	delete(uploadSessions, session.SessionID)
-}
-
-// VideoMetadata represents ffprobe output
-type VideoMetadata struct {
-	Width       int
-	Height      int
-	Duration    float64
-	Format      string
-	CodecName   string
-	FFProbeData string
-}
-
-// validateAndStoreFile validates the uploaded file and stores it in the database
-func validateAndStoreFile(session *UploadSession) (string, error) {
+// storeUploadedFile moves a completed upload to the video files and stores
+// it in the database, waiting for validation and approval
+func storeUploadedFile(session *models.UploadSession) (string, error) {
 	logger := logs.GetLogger().WithFields(logrus.Fields{
 		"module":   "web",
-		"function": "validateAndStoreFile",
+		"function": "storeUploadedFile",
 		"filename": session.Filename,
 	})
 
 	config := helpers.GetConfig()
 
-	// Run ffprobe to get video metadata
-	metadata, err := getVideoMetadata(session.TempFilePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to get video metadata: %w", err)
-	}
-
-	logger.WithFields(logrus.Fields{
-		"width":    metadata.Width,
-		"height":   metadata.Height,
-		"duration": metadata.Duration,
-		"format":   metadata.Format,
-		"codec":    metadata.CodecName,
-	}).Info("Video metadata retrieved")
-
-	// Validate video dimensions
-	if metadata.Width != config.Upload.RequiredWidth || metadata.Height != config.Upload.RequiredHeight {
-		return "", fmt.Errorf("invalid video dimensions: expected %dx%d, got %dx%d",
-			config.Upload.RequiredWidth, config.Upload.RequiredHeight,
-			metadata.Width, metadata.Height)
-	}
-
 	// Determine final file path using timestamp-based filename
 	timestamp := time.Now().UnixNano()
 	finalFilename := fmt.Sprintf("%d%s", timestamp, filepath.Ext(session.Filename))
@@ -370,7 +569,7 @@ func validateAndStoreFile(session *UploadSession) (string, error) {
 	}
 
 	// Move file from temp location to final location
-	if err := moveFile(session.TempFilePath, finalFilePath); err != nil {
+	if err := moveFile(session.TempPath, finalFilePath); err != nil {
 		return "", fmt.Errorf("failed to move file to final location: %w", err)
 	}
 
@@ -393,10 +592,13 @@ func validateAndStoreFile(session *UploadSession) (string, error) {
 		FileID:      fileID,
 		FilePath:    normalizedPath,
 		FileSize:    session.TotalSize,
-		VideoLength: int64(metadata.Duration),
 		AddedTime:   time.Now().Unix(),
-		FFProbeData: metadata.FFProbeData,
+		FFProbeData: "{}",
 		IsActive:    0, // Mark as inactive
+		// Uploads go on air only after they were validated and approved,
+		// the validation fills in the probe data, length and fingerprint
+		ReviewStatus:     models.ReviewStatusPending,
+		ValidationStatus: streamer.ValidationProcessing,
 	}
 
 	_, err = db.Insert(file)
@@ -410,69 +612,3 @@ func validateAndStoreFile(session *UploadSession) (string, error) {
 
 	return fileID, nil
 }
-
-// getVideoMetadata uses ffprobe to extract video metadata
-func getVideoMetadata(filePath string) (*VideoMetadata, error) {
-	// Run ffprobe to get video info in JSON format
-	cmd := exec.Command("ffprobe",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_format",
-		"-show_streams",
-		filePath,
-	)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("ffprobe execution failed: %w", err)
-	}
-
-	// Parse JSON output
-	var result struct {
-		Streams []struct {
-			CodecType string `json:"codec_type"`
-			CodecName string `json:"codec_name"`
-			Width     int    `json:"width"`
-			Height    int    `json:"height"`
-		} `json:"streams"`
-		Format struct {
-			Duration   string `json:"duration"`
-			FormatName string `json:"format_name"`
-		} `json:"format"`
-	}
-
-	if err := json.Unmarshal(output, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
-	}
-
-	metadata := &VideoMetadata{
-		FFProbeData: string(output),
-	}
-
-	// Find video stream
-	for _, stream := range result.Streams {
-		if stream.CodecType == "video" {
-			metadata.Width = stream.Width
-			metadata.Height = stream.Height
-			metadata.CodecName = stream.CodecName
-			break
-		}
-	}
-
-	// Parse duration
-	if result.Format.Duration != "" {
-		duration, err := strconv.ParseFloat(result.Format.Duration, 64)
-		if err == nil {
-			metadata.Duration = duration
-		}
-	}
-
-	metadata.Format = result.Format.FormatName
-
-	// Validate that we got video dimensions
-	if metadata.Width == 0 || metadata.Height == 0 {
-		return nil, fmt.Errorf("no video stream found or invalid dimensions")
-	}
-
-	return metadata, nil
-}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// binaryChunkFrame builds a binary chunk frame
+func binaryChunkFrame(version byte, sessionID string, offset uint64, length uint32, data []byte) []byte {
+	frame := make([]byte, binaryChunkHeaderSize, binaryChunkHeaderSize+len(data))
+	frame[0] = version
+	copy(frame[1:33], sessionID)
+	binary.BigEndian.PutUint64(frame[33:41], offset)
+	binary.BigEndian.PutUint32(frame[41:45], length)
+	return append(frame, data...)
+}
+
+func TestParseBinaryChunk(t *testing.T) {
+	const sessionID = "0123456789abcdef0123456789abcdef"
+	data := []byte("chunk data")
+
+	tests := []struct {
+		name          string
+		frame         []byte
+		wantSessionID string
+		wantOffset    int64
+		wantData      []byte
+		wantErr       string
+	}{
+		{
+			name:          "chunk",
+			frame:         binaryChunkFrame(binaryChunkVersion, sessionID, 262144, uint32(len(data)), data),
+			wantSessionID: sessionID,
+			wantOffset:    262144,
+			wantData:      data,
+		},
+		{
+			name:          "empty chunk",
+			frame:         binaryChunkFrame(binaryChunkVersion, sessionID, 0, 0, nil),
+			wantSessionID: sessionID,
+			wantOffset:    0,
+			wantData:      []byte{},
+		},
+		{
+			name:       "shorter than the header",
+			frame:      binaryChunkFrame(binaryChunkVersion, sessionID, 0, 0, nil)[:binaryChunkHeaderSize-1],
+			wantOffset: -1,
+			wantErr:    "shorter than its 45 byte header",
+		},
+		{
+			name:          "unknown version",
+			frame:         binaryChunkFrame(2, sessionID, 0, uint32(len(data)), data),
+			wantSessionID: sessionID,
+			wantOffset:    -1,
+			wantErr:       "version 2 not supported",
+		},
+		{
+			name:          "offset too large",
+			frame:         binaryChunkFrame(binaryChunkVersion, sessionID, 1<<63, uint32(len(data)), data),
+			wantSessionID: sessionID,
+			wantOffset:    -1,
+			wantErr:       "is too large",
+		},
+		{
+			name:          "length larger than the data",
+			frame:         binaryChunkFrame(binaryChunkVersion, sessionID, 100, uint32(len(data)+1), data),
+			wantSessionID: sessionID,
+			wantOffset:    100,
+			wantErr:       "announces 11 bytes but carries 10",
+		},
+		{
+			name:          "length smaller than the data",
+			frame:         binaryChunkFrame(binaryChunkVersion, sessionID, 100, 1, data),
+			wantSessionID: sessionID,
+			wantOffset:    100,
+			wantErr:       "announces 1 bytes but carries 10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionID, offset, data, err := parseBinaryChunk(tt.frame)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseBinaryChunk() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("parseBinaryChunk() error = %v", err)
+			}
+			if sessionID != tt.wantSessionID || offset != tt.wantOffset {
+				t.Errorf("parseBinaryChunk() = (%q, %d), want (%q, %d)", sessionID, offset, tt.wantSessionID, tt.wantOffset)
+			}
+			if !bytes.Equal(data, tt.wantData) {
+				t.Errorf("parseBinaryChunk() data = %q, want %q", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestUploadAckAccounting(t *testing.T) {
+	client := &Client{
+		acks:        make(chan uploadAck, uploadAckBufferSize),
+		pendingAcks: make(map[string]int),
+	}
+
+	tests := []struct {
+		name    string
+		queue   []string
+		written int
+		want    map[string]int
+	}{
+		{name: "nothing queued", want: map[string]int{"a": 0, "b": 0}},
+		{name: "queued per session", queue: []string{"a", "a", "b"}, want: map[string]int{"a": 2, "b": 1}},
+		{name: "written acks are released", queue: []string{"a", "a", "b"}, written: 2, want: map[string]int{"a": 0, "b": 1}},
+		{name: "all written", queue: []string{"a", "b"}, written: 2, want: map[string]int{"a": 0, "b": 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, sessionID := range tt.queue {
+				if err := client.sendUploadAck(sessionID, map[string]string{"type": "upload_chunk_ack"}); err != nil {
+					t.Fatalf("sendUploadAck() error = %v", err)
+				}
+			}
+			// What the write pump does for every acknowledgment it sends
+			for i := 0; i < tt.written; i++ {
+				client.ackWritten((<-client.acks).sessionID)
+			}
+
+			for sessionID, want := range tt.want {
+				if got := client.unwrittenAcks(sessionID); got != want {
+					t.Errorf("unwrittenAcks(%q) = %d, want %d", sessionID, got, want)
+				}
+			}
+
+			// Drain what is left for the next case
+			for len(client.acks) > 0 {
+				client.ackWritten((<-client.acks).sessionID)
+			}
+			if len(client.pendingAcks) != 0 {
+				t.Errorf("pending acknowledgments left behind: %v", client.pendingAcks)
+			}
+		})
+	}
+}